@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.uber.org/zap"
+
+	"example/internal/oas"
+)
+
+var commands = []command{
+	{
+		Name:    "add-pet",
+		Summary: "add a new pet from JSON (addPet)",
+		Flags: func(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+			file := fs.String("f", "-", "file with pet JSON, \"-\" for stdin")
+			var params oas.AddPetParams
+			paramFlags(fs, &params)
+			return func(ctx context.Context, e *env) error {
+				pet, err := readPet(*file, e.Stdin)
+				if err != nil {
+					return &usageError{err: err}
+				}
+				res, err := e.Client.AddPet(ctx, pet, params)
+				if err != nil {
+					return errors.Wrap(err, "add pet")
+				}
+				switch res := res.(type) {
+				case *oas.Pet:
+					return writePets(e.Stdout, e.Output, res)
+				case *oas.AddPetBadRequest:
+					return errors.Wrapf(errRejected, "invalid pet id %d", pet.ID.Value)
+				case *oas.AddPetConflict:
+					return errors.Wrapf(errRejected, "pet %d already exists", pet.ID.Value)
+				default:
+					return errors.Errorf("unexpected response %T", res)
+				}
+			}
+		},
+	},
+	{
+		Name:    "get-pet",
+		Summary: "find pet by ID (getPetById)",
+		Flags: func(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+			var params oas.GetPetByIdParams
+			checkRequired := paramFlags(fs, &params)
+			return func(ctx context.Context, e *env) error {
+				if err := checkRequired(); err != nil {
+					return &usageError{err: err}
+				}
+				res, err := e.Client.GetPetById(ctx, params)
+				if err != nil {
+					return errors.Wrap(err, "get pet")
+				}
+				switch res := res.(type) {
+				case *oas.Pet:
+					return writePets(e.Stdout, e.Output, res)
+				case *oas.GetPetByIdNotFound:
+					return errors.Wrapf(errNotFound, "pet %d", params.PetId)
+				default:
+					return errors.Errorf("unexpected response %T", res)
+				}
+			}
+		},
+	},
+	{
+		Name:    "update-pet",
+		Summary: "update pet name or status (updatePet)",
+		Flags: func(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+			var params oas.UpdatePetParams
+			checkRequired := paramFlags(fs, &params)
+			return func(ctx context.Context, e *env) error {
+				if err := checkRequired(); err != nil {
+					return &usageError{err: err}
+				}
+				res, err := e.Client.UpdatePet(ctx, params)
+				if err != nil {
+					return errors.Wrap(err, "update pet")
+				}
+				switch res := res.(type) {
+				case *oas.UpdatePetOK:
+					return nil
+				case *oas.UpdatePetNotFound:
+					return errors.Wrapf(errNotFound, "pet %d", params.PetId)
+				default:
+					return errors.Errorf("unexpected response %T", res)
+				}
+			}
+		},
+	},
+	{
+		Name:    "delete-pet",
+		Summary: "delete pet by ID (deletePet)",
+		Flags: func(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+			var params oas.DeletePetParams
+			checkRequired := paramFlags(fs, &params)
+			return func(ctx context.Context, e *env) error {
+				if err := checkRequired(); err != nil {
+					return &usageError{err: err}
+				}
+				res, err := e.Client.DeletePet(ctx, params)
+				if err != nil {
+					return errors.Wrap(err, "delete pet")
+				}
+				switch res := res.(type) {
+				case *oas.DeletePetOK:
+					return nil
+				case *oas.DeletePetNotFound:
+					return errors.Wrapf(errNotFound, "pet %d", params.PetId)
+				default:
+					return errors.Errorf("unexpected response %T", res)
+				}
+			}
+		},
+	},
+	{
+		Name:    "poll",
+		Summary: "fetch pet by ID periodically until interrupted",
+
+		LongRunning: true,
+		Flags: func(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+			id := fs.Int64("id", 0, "pet id to request, zero adds a new pet to poll")
+			interval := fs.Duration("interval", time.Second, "interval between requests")
+			return func(ctx context.Context, e *env) error {
+				return poll(ctx, e, *id, *interval)
+			}
+		},
+	},
+	{
+		Name:    "load",
+		Summary: "generate load with weighted operation mix and report latencies",
+
+		LongRunning: true,
+		Flags:       loadCommand,
+	},
+}
+
+// readPet reads and validates pet JSON from file or stdin.
+func readPet(name string, stdin io.Reader) (*oas.Pet, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if name == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read pet")
+	}
+	pet := new(oas.Pet)
+	if err := pet.Decode(jx.DecodeBytes(data)); err != nil {
+		return nil, errors.Wrap(err, "decode pet")
+	}
+	if err := pet.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validate pet")
+	}
+	return pet, nil
+}
+
+func poll(ctx context.Context, e *env, id int64, interval time.Duration) error {
+	tracer := e.Telemetry.TracerProvider().Tracer("api-client")
+	fetchPet := func(ctx context.Context) error {
+		ctx, span := tracer.Start(ctx, "tick")
+		defer span.End()
+		lg := zctx.From(ctx)
+		if id == 0 {
+			// Added on tick, so server may start later.
+			res, err := e.Client.AddPet(ctx, &oas.Pet{Name: "poll"}, oas.AddPetParams{})
+			if err != nil {
+				return errors.Wrap(err, "add pet")
+			}
+			pet, ok := res.(*oas.Pet)
+			if !ok {
+				return errors.Errorf("unexpected response %T", res)
+			}
+			id = pet.ID.Value
+			lg.Info("Added pet", zap.Int64("id", id))
+		}
+		res, err := e.Client.GetPetById(ctx, oas.GetPetByIdParams{
+			PetId: id,
+		})
+		if err != nil {
+			return errors.Wrap(err, "get pet")
+		}
+		switch res := res.(type) {
+		case *oas.Pet:
+			lg.Info("Got pet", zap.Any("pet", res))
+		case *oas.GetPetByIdNotFound:
+			lg.Warn("Pet not found", zap.Int64("id", id))
+		default:
+			return errors.Errorf("unexpected response %T", res)
+		}
+		return nil
+	}
+	tick := func() {
+		if err := fetchPet(ctx); err != nil {
+			zctx.From(ctx).Error("Failed to fetch pet", zap.Error(err))
+		}
+	}
+	tick()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			tick()
+		}
+	}
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen/validate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/oas"
+)
+
+// petPool tracks IDs of pets known to exist.
+type petPool struct {
+	mux sync.Mutex
+	ids []int64
+}
+
+func (p *petPool) Add(id int64) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.ids = append(p.ids, id)
+}
+
+// Random returns random known ID or zero if pool is empty.
+func (p *petPool) Random() int64 {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.ids) == 0 {
+		return 0
+	}
+	return p.ids[rand.IntN(len(p.ids))]
+}
+
+// Take removes and returns random known ID or zero if pool is empty.
+func (p *petPool) Take() int64 {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.ids) == 0 {
+		return 0
+	}
+	i := rand.IntN(len(p.ids))
+	id := p.ids[i]
+	p.ids = slices.Delete(p.ids, i, i+1)
+	return id
+}
+
+// loadOps are operations performed by load generator, by operation ID.
+//
+// Each operation returns response status or error.
+var loadOps = map[string]func(ctx context.Context, c oas.Invoker, pets *petPool) (int, error){
+	"addPet": func(ctx context.Context, c oas.Invoker, pets *petPool) (int, error) {
+		res, err := c.AddPet(ctx, &oas.Pet{
+			Name:   "load-" + strconv.Itoa(rand.IntN(1_000_000)),
+			Status: oas.NewOptPetStatus(oas.PetStatusAvailable),
+		}, oas.AddPetParams{})
+		if err != nil {
+			return 0, err
+		}
+		pet, ok := res.(*oas.Pet)
+		if !ok {
+			return 0, errors.Errorf("unexpected response %T", res)
+		}
+		if id, ok := pet.ID.Get(); ok {
+			pets.Add(id)
+		}
+		return 200, nil
+	},
+	"getPetById": func(ctx context.Context, c oas.Invoker, pets *petPool) (int, error) {
+		res, err := c.GetPetById(ctx, oas.GetPetByIdParams{PetId: pets.Random()})
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := res.(*oas.GetPetByIdNotFound); ok {
+			return 404, nil
+		}
+		return 200, nil
+	},
+	"updatePet": func(ctx context.Context, c oas.Invoker, pets *petPool) (int, error) {
+		statuses := oas.PetStatus("").AllValues()
+		res, err := c.UpdatePet(ctx, oas.UpdatePetParams{
+			PetId:  pets.Random(),
+			Status: oas.NewOptPetStatus(statuses[rand.IntN(len(statuses))]),
+		})
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := res.(*oas.UpdatePetNotFound); ok {
+			return 404, nil
+		}
+		return 200, nil
+	},
+	"deletePet": func(ctx context.Context, c oas.Invoker, pets *petPool) (int, error) {
+		res, err := c.DeletePet(ctx, oas.DeletePetParams{PetId: pets.Take()})
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := res.(*oas.DeletePetNotFound); ok {
+			return 404, nil
+		}
+		return 200, nil
+	},
+}
+
+// loadMix is weighted set of operation IDs.
+type loadMix struct {
+	ops     []string
+	weights []int
+	total   int
+}
+
+// parseLoadMix parses mix like "getPetById=8,addPet=1".
+func parseLoadMix(s string) (m loadMix, _ error) {
+	for _, e := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(e), "=")
+		if !ok {
+			return m, errors.Errorf("invalid mix entry %q, expected operationId=weight", e)
+		}
+		if _, ok := loadOps[name]; !ok {
+			return m, errors.Errorf("unknown operation %q", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return m, errors.Errorf("invalid weight %q of %s", weight, name)
+		}
+		if w == 0 {
+			continue
+		}
+		m.ops = append(m.ops, name)
+		m.weights = append(m.weights, w)
+		m.total += w
+	}
+	if m.total == 0 {
+		return m, errors.New("mix has no operations")
+	}
+	return m, nil
+}
+
+// Pick returns random operation ID according to weights.
+func (m loadMix) Pick() string {
+	n := rand.IntN(m.total)
+	for i, w := range m.weights {
+		if n < w {
+			return m.ops[i]
+		}
+		n -= w
+	}
+	return m.ops[len(m.ops)-1]
+}
+
+// errorStatus returns status label of failed request.
+func errorStatus(err error) string {
+	var (
+		statusErr *validate.UnexpectedStatusCodeError
+		urlErr    *url.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &statusErr):
+		return strconv.Itoa(statusErr.StatusCode)
+	case errors.As(err, &urlErr):
+		return "transport"
+	default:
+		return "error"
+	}
+}
+
+// Latencies are recorded in microseconds up to a minute.
+const (
+	histMin     = 1
+	histMax     = int64(time.Minute / time.Microsecond)
+	histSigFigs = 3
+)
+
+type opStats struct {
+	hist   *hdrhistogram.Histogram
+	errors map[string]int64
+}
+
+// loadStats collects latencies and errors by operation ID.
+type loadStats struct {
+	mux     sync.Mutex
+	ops     map[string]*opStats
+	dropped int64
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{ops: map[string]*opStats{}}
+}
+
+func (s *loadStats) Record(op string, latency time.Duration, status string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	st, ok := s.ops[op]
+	if !ok {
+		st = &opStats{
+			hist:   hdrhistogram.New(histMin, histMax, histSigFigs),
+			errors: map[string]int64{},
+		}
+		s.ops[op] = st
+	}
+	_ = st.hist.RecordValue(min(latency.Microseconds(), histMax))
+	if status != "" {
+		st.errors[status]++
+	}
+}
+
+func (s *loadStats) Drop() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.dropped++
+}
+
+// latencyReport is latency distribution in milliseconds.
+type latencyReport struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+func newLatencyReport(h *hdrhistogram.Histogram) latencyReport {
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	return latencyReport{
+		P50:  ms(h.ValueAtQuantile(50)),
+		P90:  ms(h.ValueAtQuantile(90)),
+		P99:  ms(h.ValueAtQuantile(99)),
+		P999: ms(h.ValueAtQuantile(99.9)),
+		Max:  ms(h.Max()),
+	}
+}
+
+type opReport struct {
+	OperationID string           `json:"operationId"`
+	Requests    int64            `json:"requests"`
+	Errors      map[string]int64 `json:"errors,omitempty"`
+	Latency     latencyReport    `json:"latencyMs"`
+}
+
+// loadReport is result of load run.
+type loadReport struct {
+	DurationSeconds float64    `json:"durationSeconds"`
+	Requests        int64      `json:"requests"`
+	Throughput      float64    `json:"throughput"`
+	Dropped         int64      `json:"dropped"`
+	Latency         opReport   `json:"total"`
+	Operations      []opReport `json:"operations"`
+}
+
+// Report returns statistics of run lasted for given duration.
+func (s *loadStats) Report(elapsed time.Duration) loadReport {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	total := hdrhistogram.New(histMin, histMax, histSigFigs)
+	r := loadReport{
+		DurationSeconds: elapsed.Seconds(),
+		Dropped:         s.dropped,
+		Latency: opReport{
+			OperationID: "total",
+			Errors:      map[string]int64{},
+		},
+	}
+	for op, st := range s.ops {
+		total.Merge(st.hist)
+		for status, n := range st.errors {
+			r.Latency.Errors[status] += n
+		}
+		r.Operations = append(r.Operations, opReport{
+			OperationID: op,
+			Requests:    st.hist.TotalCount(),
+			Errors:      st.errors,
+			Latency:     newLatencyReport(st.hist),
+		})
+	}
+	slices.SortFunc(r.Operations, func(a, b opReport) int {
+		return strings.Compare(a.OperationID, b.OperationID)
+	})
+	r.Requests = total.TotalCount()
+	r.Latency.Requests = r.Requests
+	r.Latency.Latency = newLatencyReport(total)
+	if elapsed > 0 {
+		r.Throughput = float64(r.Requests) / elapsed.Seconds()
+	}
+	return r
+}
+
+// WriteText writes human-readable report.
+func (r loadReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "OPERATION\tREQUESTS\tERRORS\tP50 ms\tP90 ms\tP99 ms\tP999 ms\tMAX ms\t")
+	for _, op := range append(r.Operations, r.Latency) {
+		var errs int64
+		for _, n := range op.Errors {
+			errs += n
+		}
+		l := op.Latency
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			op.OperationID, op.Requests, errs, l.P50, l.P90, l.P99, l.P999, l.Max,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "\nDuration: %.1fs, throughput: %.1f req/s, dropped: %d\n",
+		r.DurationSeconds, r.Throughput, r.Dropped,
+	)
+	for _, op := range r.Operations {
+		statuses := make([]string, 0, len(op.Errors))
+		for status := range op.Errors {
+			statuses = append(statuses, status)
+		}
+		slices.Sort(statuses)
+		for _, status := range statuses {
+			_, _ = fmt.Fprintf(w, "Errors: %s %s: %d\n", op.OperationID, status, op.Errors[status])
+		}
+	}
+	return nil
+}
+
+// loadConfig configures load run.
+type loadConfig struct {
+	Mix         loadMix
+	RPS         float64
+	Concurrency int
+	Duration    time.Duration
+	MaxInFlight int
+}
+
+// loadRunner runs load and records results.
+type loadRunner struct {
+	cfg    loadConfig
+	client oas.Invoker
+	tracer trace.Tracer
+	pets   *petPool
+	stats  *loadStats
+}
+
+// do performs single operation, measuring latency from intended start time.
+func (l *loadRunner) do(ctx context.Context, intended time.Time) {
+	op := l.cfg.Mix.Pick()
+	ctx, span := l.tracer.Start(ctx, "load."+op,
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("oas.operation", op),
+			attribute.Int64("load.schedule_delay_us", time.Since(intended).Microseconds()),
+		),
+	)
+	defer span.End()
+
+	code, err := loadOps[op](ctx, l.client, l.pets)
+	latency := time.Since(intended)
+
+	var status string
+	switch {
+	case err != nil:
+		status = errorStatus(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, status)
+	case code >= 300:
+		status = strconv.Itoa(code)
+		span.SetStatus(codes.Error, status)
+	}
+	l.stats.Record(op, latency, status)
+}
+
+// runOpenLoop issues requests at fixed rate regardless of responses,
+// so slow responses do not reduce offered load (no coordinated omission).
+func (l *loadRunner) runOpenLoop(ctx context.Context) {
+	var (
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, l.cfg.MaxInFlight)
+		interval = time.Duration(float64(time.Second) / l.cfg.RPS)
+		start    = time.Now()
+		timer    = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	defer wg.Wait()
+
+	for i := 0; ; i++ {
+		intended := start.Add(time.Duration(i) * interval)
+		if intended.Sub(start) >= l.cfg.Duration {
+			return
+		}
+		timer.Reset(time.Until(intended))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			l.stats.Drop()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			l.do(ctx, intended)
+		}()
+	}
+}
+
+// runClosedLoop runs fixed number of workers issuing requests back to back.
+//
+// Workers stop issuing requests after duration, requests in flight are
+// completed, so they are not reported as timeouts.
+func (l *loadRunner) runClosedLoop(ctx context.Context) {
+	deadline := time.Now().Add(l.cfg.Duration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < l.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && time.Now().Before(deadline) {
+				l.do(ctx, time.Now())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func loadCommand(fs *flag.FlagSet) func(ctx context.Context, e *env) error {
+	var arg struct {
+		Mix         string
+		RPS         float64
+		Concurrency int
+		Duration    time.Duration
+		MaxInFlight int
+		Seed        int
+		JSON        string
+	}
+	fs.StringVar(&arg.Mix, "mix", "getPetById=8,addPet=1,updatePet=1", "operation weights, like getPetById=8,addPet=1")
+	fs.Float64Var(&arg.RPS, "rps", 100, "target requests per second (open loop)")
+	fs.IntVar(&arg.Concurrency, "concurrency", 0, "fixed number of workers (closed loop), overrides -rps")
+	fs.DurationVar(&arg.Duration, "duration", 30*time.Second, "load duration")
+	fs.IntVar(&arg.MaxInFlight, "max-inflight", 1000, "maximum requests in flight in open loop, excess is dropped")
+	fs.IntVar(&arg.Seed, "seed-pets", 10, "pets to add before load starts")
+	fs.StringVar(&arg.JSON, "json", "", "write JSON report to file")
+
+	return func(ctx context.Context, e *env) error {
+		mix, err := parseLoadMix(arg.Mix)
+		if err != nil {
+			return &usageError{err: err}
+		}
+		if arg.Concurrency <= 0 && arg.RPS <= 0 {
+			return &usageError{err: errors.New("either -rps or -concurrency must be positive")}
+		}
+		if arg.MaxInFlight <= 0 {
+			return &usageError{err: errors.New("-max-inflight must be positive")}
+		}
+		l := &loadRunner{
+			cfg: loadConfig{
+				Mix:         mix,
+				RPS:         arg.RPS,
+				Concurrency: arg.Concurrency,
+				Duration:    arg.Duration,
+				MaxInFlight: arg.MaxInFlight,
+			},
+			client: e.Client,
+			tracer: e.Telemetry.TracerProvider().Tracer("api-client"),
+			pets:   &petPool{},
+			stats:  newLoadStats(),
+		}
+		for i := 0; i < arg.Seed; i++ {
+			if _, err := loadOps["addPet"](ctx, e.Client, l.pets); err != nil {
+				return errors.Wrap(err, "seed pets")
+			}
+		}
+
+		start := time.Now()
+		if arg.Concurrency > 0 {
+			l.runClosedLoop(ctx)
+		} else {
+			l.runOpenLoop(ctx)
+		}
+		report := l.stats.Report(time.Since(start))
+
+		if err := report.WriteText(e.Stdout); err != nil {
+			return errors.Wrap(err, "write report")
+		}
+		if arg.JSON != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "encode report")
+			}
+			if err := os.WriteFile(arg.JSON, append(data, '\n'), 0o644); err != nil {
+				return errors.Wrap(err, "write json report")
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"example/internal/oas"
+	"example/internal/oastest"
+)
+
+func TestParseLoadMix(t *testing.T) {
+	m, err := parseLoadMix("getPetById=3, addPet=1,deletePet=0")
+	require.NoError(t, err)
+	require.Equal(t, []string{"getPetById", "addPet"}, m.ops)
+	require.Equal(t, 4, m.total)
+	for i := 0; i < 100; i++ {
+		require.Contains(t, m.ops, m.Pick())
+	}
+
+	for _, s := range []string{
+		"",
+		"getPetById",
+		"listPets=1",
+		"getPetById=-1",
+		"getPetById=0",
+	} {
+		_, err := parseLoadMix(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	s := newLoadStats()
+	for i := 1; i <= 100; i++ {
+		s.Record("getPetById", time.Duration(i)*time.Millisecond, "")
+	}
+	s.Record("addPet", time.Second, "503")
+	s.Drop()
+
+	r := s.Report(time.Second)
+	require.Equal(t, int64(101), r.Requests)
+	require.Equal(t, int64(1), r.Dropped)
+	require.InDelta(t, 101, r.Throughput, 0.01)
+	require.Len(t, r.Operations, 2)
+	require.Equal(t, "addPet", r.Operations[0].OperationID)
+	require.Equal(t, map[string]int64{"503": 1}, r.Operations[0].Errors)
+	require.Equal(t, map[string]int64{"503": 1}, r.Latency.Errors)
+
+	get := r.Operations[1].Latency
+	require.InDelta(t, 50, get.P50, 0.1)
+	require.InDelta(t, 99, get.P99, 0.1)
+	require.InDelta(t, 100, get.Max, 0.1)
+
+	var out strings.Builder
+	require.NoError(t, r.WriteText(&out))
+	require.Contains(t, out.String(), "Errors: addPet 503: 1")
+}
+
+func TestLoadClosedLoop(t *testing.T) {
+	mix, err := parseLoadMix("getPetById=1")
+	require.NoError(t, err)
+	fake := oastest.New(t).OnGetPetById(func(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error) {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return &oas.Pet{Name: "Tom"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	l := &loadRunner{
+		cfg: loadConfig{
+			Mix:         mix,
+			Concurrency: 2,
+			Duration:    10 * time.Millisecond,
+		},
+		client: fake,
+		tracer: noop.NewTracerProvider().Tracer("test"),
+		pets:   &petPool{},
+		stats:  newLoadStats(),
+	}
+	l.runClosedLoop(context.Background())
+
+	// Requests in flight at deadline complete.
+	r := l.stats.Report(time.Second)
+	require.Equal(t, int64(2), r.Requests)
+	require.Empty(t, r.Latency.Errors)
+}
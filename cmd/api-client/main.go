@@ -3,89 +3,443 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-faster/errors"
-	"github.com/go-faster/sdk/app"
 	"github.com/go-faster/sdk/zctx"
+	"github.com/ogen-go/ogen/validate"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
 	"go.uber.org/zap"
 
-	"example/internal/api"
+	"example/internal/httpmiddleware"
+	"example/internal/httprecord"
+	"example/internal/httptransport"
 	"example/internal/oas"
 )
 
-func run(ctx context.Context, lg *zap.Logger, m *app.Telemetry) error {
-	var arg struct {
-		BaseURL string
-		ID      int64
+// Exit codes.
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitClientError = 4
+	exitServerError = 5
+	exitTransport   = 6
+)
+
+var (
+	// errNotFound is returned by commands when requested entity does not exist.
+	errNotFound = errors.New("not found")
+	// errRejected is returned by commands when server rejects request with
+	// declared 4XX status.
+	errRejected = errors.New("rejected")
+)
+
+// usageError is an error in command line arguments.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// exitCode returns process exit code for command error.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var (
+		usageErr  *usageError
+		statusErr *validate.UnexpectedStatusCodeError
+		urlErr    *url.Error
+	)
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.Is(err, errNotFound):
+		return exitNotFound
+	case errors.Is(err, errRejected):
+		return exitClientError
+	case errors.As(err, &statusErr):
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusNotFound:
+			return exitNotFound
+		case code >= 500:
+			return exitServerError
+		case code >= 400:
+			return exitClientError
+		}
+	case errors.As(err, &urlErr):
+		return exitTransport
 	}
-	flag.StringVar(&arg.BaseURL, "url", "http://server:8080", "target server url")
-	flag.Int64Var(&arg.ID, "id", 1337, "pet id to request")
-	flag.Parse()
+	return exitFailure
+}
 
-	// For route finding.
-	oasServer, err := oas.NewServer(api.Handler{})
+// env is environment of running command.
+type env struct {
+	Client    *oas.Client
+	Output    string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Telemetry *telemetry
+}
+
+// command is api-client subcommand.
+type command struct {
+	Name    string
+	Summary string
+	// LongRunning commands start their own root spans.
+	LongRunning bool
+	// Flags registers command flags and returns function running command.
+	Flags func(fs *flag.FlagSet) func(ctx context.Context, e *env) error
+}
+
+// clientConfig configures API client.
+type clientConfig struct {
+	// BaseURLs are server replicas, requests are balanced across them.
+	BaseURLs []string
+	// Balance is a balancing strategy.
+	Balance httptransport.BalanceStrategy
+	// DNSRefresh is an interval of resolving host of the first URL
+	// to replicas, zero disables it.
+	DNSRefresh time.Duration
+	// HealthInterval is an interval of replica health checks, zero disables them.
+	HealthInterval time.Duration
+	// MaxAttempts is a maximum number of attempts of idempotent requests.
+	MaxAttempts int
+	// Hedge enables hedged requests of safe operations.
+	Hedge bool
+	// BreakerRatio is a failure ratio opening circuit breaker, zero disables it.
+	BreakerRatio float64
+	// BreakerCoolDown is how long circuit breaker stays open.
+	BreakerCoolDown time.Duration
+	// Record is a sink of recorded requests, nil disables recording.
+	Record httprecord.Sink
+	// Replay are recorded entries served instead of sending requests,
+	// nil disables replaying.
+	Replay []httprecord.Entry
+}
+
+// newBalancer creates balancer of requests to the first base URL.
+//
+// Background work is stopped when ctx is done.
+func newBalancer(ctx context.Context, cfg clientConfig, target *url.URL, network http.RoundTripper) (*httptransport.Balancer, error) {
+	endpoints := make([]*url.URL, 0, len(cfg.BaseURLs))
+	for _, s := range cfg.BaseURLs {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %q", s)
+		}
+		endpoints = append(endpoints, u)
+	}
+	b, err := httptransport.NewBalancer(target, endpoints, httptransport.BalancerConfig{
+		Strategy:            cfg.Balance,
+		HealthCheckInterval: cfg.HealthInterval,
+		HealthCheckClient:   &http.Client{Transport: network},
+		// Resolved endpoints are addresses of target.
+		KeepHost: cfg.DNSRefresh > 0,
+	})
 	if err != nil {
-		return errors.Wrap(err, "server init")
+		return nil, err
 	}
 
-	httpClient := &http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport,
-			otelhttp.WithTracerProvider(m.TracerProvider()),
-			otelhttp.WithMeterProvider(m.MeterProvider()),
-			otelhttp.WithPropagators(m.TextMapPropagator()),
-			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
-				route, ok := oasServer.FindPath(r.Method, r.URL)
-				if !ok {
-					return operation
-				}
-				return route.OperationID()
-			}),
-		),
-	}
-	client, err := oas.NewClient(arg.BaseURL,
-		oas.WithClient(httpClient),
-		oas.WithMeterProvider(m.MeterProvider()),
-		oas.WithTracerProvider(m.TracerProvider()),
-	)
+	lg := zctx.From(ctx)
+	go func() {
+		_ = b.Run(ctx)
+	}()
+	if cfg.DNSRefresh > 0 {
+		go func() {
+			_ = b.WatchDNS(ctx, net.DefaultResolver, cfg.DNSRefresh, func(err error) {
+				lg.Warn("Failed to resolve replicas", zap.Error(err))
+			})
+		}()
+	}
+	return b, nil
+}
+
+func newClient(ctx context.Context, cfg clientConfig, t *telemetry) (*oas.Client, error) {
+	if len(cfg.BaseURLs) == 0 {
+		return nil, errors.New("no server url")
+	}
+	target, err := url.Parse(cfg.BaseURLs[0])
 	if err != nil {
-		return errors.Wrap(err, "client")
+		return nil, errors.Wrap(err, "parse server url")
+	}
+
+	// For route finding.
+	oasServer, err := oas.NewServer(oas.UnimplementedHandler{})
+	if err != nil {
+		return nil, errors.Wrap(err, "server init")
 	}
+	find := httpmiddleware.MakeRouteFinder(oasServer)
 
-	tracer := m.TracerProvider().Tracer("example")
-	fetchPet := func(ctx context.Context) error {
-		ctx, span := tracer.Start(ctx, "tick")
-		defer span.End()
-		res, err := client.GetPetById(ctx, oas.GetPetByIdParams{
-			PetId: arg.ID,
+	// Transport to servers, also used for health checks.
+	var network http.RoundTripper = http.DefaultTransport
+	if cfg.DNSRefresh > 0 {
+		// Endpoints are addresses of target host.
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = httptransport.EndpointTLSConfig(target, t.TLSClientConfig)
+		network = t
+	}
+	base := network
+	if cfg.Replay != nil {
+		replayer, err := httprecord.NewReplayer(find, cfg.Replay)
+		if err != nil {
+			return nil, errors.Wrap(err, "replayer")
+		}
+		base = replayer
+	}
+	transport := otelhttp.NewTransport(base,
+		otelhttp.WithTracerProvider(t.TracerProvider()),
+		otelhttp.WithMeterProvider(t.MeterProvider()),
+		otelhttp.WithPropagators(t.TextMapPropagator()),
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			route, ok := find(r.Method, r.URL)
+			if !ok {
+				return operation
+			}
+			return route.OperationID()
+		}),
+	)
+	var middlewares []httptransport.Middleware
+	if cfg.Record != nil {
+		// Record requests as seen by caller, once per logical request.
+		rec := httprecord.Recorder{
+			Sink:      cfg.Record,
+			Find:      find,
+			Redaction: httprecord.DefaultRedaction(),
+		}
+		middlewares = append(middlewares, rec.Transport)
+	}
+	if cfg.MaxAttempts > 1 {
+		// Make non-idempotent operations safe to retry.
+		middlewares = append(middlewares, httptransport.IdempotencyKey(
+			httptransport.MatchOperations(find, "addPet", "updatePet"),
+		))
+	}
+	if cfg.Hedge {
+		hedger, err := httptransport.NewHedger(find, httptransport.HedgeConfig{
+			Hedgeable:      httptransport.MatchOperations(find, "getPetById"),
+			TracerProvider: t.TracerProvider(),
+			MeterProvider:  t.MeterProvider(),
 		})
 		if err != nil {
-			return errors.Wrap(err, "get pet")
+			return nil, errors.Wrap(err, "hedger")
 		}
-		zctx.From(ctx).Info("Got pet", zap.Any("pet", res))
-		return nil
+		middlewares = append(middlewares, hedger.Middleware)
 	}
-	tick := func() {
-		if err := fetchPet(ctx); err != nil {
-			zctx.From(ctx).Error("Failed to fetch pet", zap.Error(err))
+	middlewares = append(middlewares,
+		httptransport.Retry(httptransport.RetryPolicy{
+			MaxAttempts:    cfg.MaxAttempts,
+			Budget:         httptransport.NewRetryBudget(0.2, 10),
+			Retryable:      httptransport.IdempotentOperations(find, "getPetById", "deletePet"),
+			TracerProvider: t.TracerProvider(),
+		}),
+	)
+	if cfg.BreakerRatio > 0 {
+		breakers, err := httptransport.NewBreakers(find, httptransport.BreakerConfig{
+			FailureRatio:  cfg.BreakerRatio,
+			CoolDown:      cfg.BreakerCoolDown,
+			MeterProvider: t.MeterProvider(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "circuit breaker")
 		}
+		middlewares = append(middlewares, breakers.Middleware)
 	}
-	tick()
+	if len(cfg.BaseURLs) > 1 || cfg.DNSRefresh > 0 {
+		balancer, err := newBalancer(ctx, cfg, target, network)
+		if err != nil {
+			return nil, errors.Wrap(err, "balancer")
+		}
+		middlewares = append(middlewares, balancer.Middleware)
+	}
+	httpClient := &http.Client{
+		Transport: httptransport.Wrap(transport, middlewares...),
+	}
+	return oas.NewClient(target.String(),
+		oas.WithClient(httpClient),
+		oas.WithMeterProvider(t.MeterProvider()),
+		oas.WithTracerProvider(t.TracerProvider()),
+	)
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: api-client <command> [flags]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %-12s %s\n", c.Name, c.Summary)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run 'api-client <command> -h' for command flags.")
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return &usageError{err: errors.New("command is required")}
+	}
+	name := args[0]
+	idx := slices.IndexFunc(commands, func(c command) bool { return c.Name == name })
+	if idx < 0 {
+		if name == "-h" || name == "-help" || name == "help" {
+			usage(os.Stdout)
+			return nil
+		}
+		usage(os.Stderr)
+		return &usageError{err: errors.Errorf("unknown command %q", name)}
+	}
+	cmd := commands[idx]
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
+	var arg struct {
+		BaseURL string
+		Output  string
+		Timeout time.Duration
+		Retries int
+		Hedge   bool
+		Record  string
+		Replay  string
+		Balance struct {
+			Strategy       string
+			DNSRefresh     time.Duration
+			HealthInterval time.Duration
+		}
+		Breaker struct {
+			Ratio    float64
+			CoolDown time.Duration
+		}
+	}
+	fs := flag.NewFlagSet("api-client "+cmd.Name, flag.ContinueOnError)
+	fs.StringVar(&arg.BaseURL, "url", "http://server:8080", "target server url, comma-separated list to balance across replicas")
+	fs.StringVar(&arg.Output, "o", outputJSON, "output format ("+strings.Join(outputFormats, ", ")+")")
+	fs.DurationVar(&arg.Timeout, "timeout", 0, "command timeout, zero means no timeout")
+	fs.IntVar(&arg.Retries, "retries", 2, "maximum retries of idempotent requests, zero disables retries")
+	fs.BoolVar(&arg.Hedge, "hedge", false, "send hedged getPetById request if response is slower than p95")
+	fs.StringVar(&arg.Balance.Strategy, "balance", string(httptransport.RoundRobin), "balancing strategy (round-robin, least-outstanding)")
+	fs.DurationVar(&arg.Balance.DNSRefresh, "dns-refresh", 0, "interval of resolving url host to replicas, zero disables it")
+	fs.DurationVar(&arg.Balance.HealthInterval, "health-interval", 0, "interval of replica /healthz checks, zero disables them")
+	fs.Float64Var(&arg.Breaker.Ratio, "breaker-ratio", 0.5, "failure ratio opening per-operation circuit breaker, zero disables it")
+	fs.DurationVar(&arg.Breaker.CoolDown, "breaker-cooldown", 5*time.Second, "how long circuit breaker stays open before probing")
+	fs.StringVar(&arg.Record, "record", "", "record requests to file, HAR if it has .har extension and JSON lines otherwise")
+	fs.StringVar(&arg.Replay, "replay", "", "serve responses recorded to file instead of sending requests")
+	runCmd := cmd.Flags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
 			return nil
-		case <-ticker.C:
-			tick()
 		}
+		return &usageError{err: err}
+	}
+	if !slices.Contains(outputFormats, arg.Output) {
+		return &usageError{err: errors.Errorf("unknown output format %q", arg.Output)}
+	}
+	switch httptransport.BalanceStrategy(arg.Balance.Strategy) {
+	case httptransport.RoundRobin, httptransport.LeastOutstanding:
+	default:
+		return &usageError{err: errors.Errorf("unknown balancing strategy %q", arg.Balance.Strategy)}
+	}
+
+	lg, err := newLogger()
+	if err != nil {
+		return err
 	}
+	defer func() { _ = lg.Sync() }()
+	ctx = zctx.Base(ctx, lg)
+
+	t, err := newTelemetry(ctx)
+	if err != nil {
+		return errors.Wrap(err, "telemetry")
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := t.Shutdown(shutdownCtx); err != nil {
+			lg.Warn("Telemetry shutdown failed", zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		record httprecord.Sink
+		replay []httprecord.Entry
+	)
+	if arg.Replay != "" {
+		if replay, err = httprecord.Load(arg.Replay); err != nil {
+			return errors.Wrap(err, "load replay")
+		}
+	}
+	if arg.Record != "" {
+		if record, err = httprecord.Create(arg.Record); err != nil {
+			return errors.Wrap(err, "create record")
+		}
+		defer func() {
+			if err := record.Close(); err != nil {
+				lg.Warn("Failed to close record", zap.Error(err))
+			}
+		}()
+	}
+
+	client, err := newClient(ctx, clientConfig{
+		BaseURLs:        strings.Split(arg.BaseURL, ","),
+		Balance:         httptransport.BalanceStrategy(arg.Balance.Strategy),
+		DNSRefresh:      arg.Balance.DNSRefresh,
+		HealthInterval:  arg.Balance.HealthInterval,
+		MaxAttempts:     max(arg.Retries, 0) + 1,
+		Hedge:           arg.Hedge,
+		BreakerRatio:    arg.Breaker.Ratio,
+		BreakerCoolDown: arg.Breaker.CoolDown,
+		Record:          record,
+		Replay:          replay,
+	}, t)
+	if err != nil {
+		return errors.Wrap(err, "client")
+	}
+	if arg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, arg.Timeout)
+		defer cancel()
+	}
+
+	e := &env{
+		Client:    client,
+		Output:    arg.Output,
+		Stdin:     os.Stdin,
+		Stdout:    os.Stdout,
+		Telemetry: t,
+	}
+	if cmd.LongRunning {
+		return runCmd(ctx, e)
+	}
+
+	ctx, span := t.TracerProvider().Tracer("api-client").Start(ctx, cmd.Name)
+	defer span.End()
+	if err := runCmd(ctx, e); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "command failed")
+		return err
+	}
+	return nil
 }
 
 func main() {
-	app.Run(run)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:])
+	cancel()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "api-client: %v\n", err)
+	}
+	os.Exit(exitCode(err))
 }
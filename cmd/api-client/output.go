@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-faster/errors"
+	"gopkg.in/yaml.v3"
+
+	"example/internal/oas"
+)
+
+// Output formats.
+const (
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
+)
+
+var outputFormats = []string{outputJSON, outputYAML, outputTable}
+
+// writePets writes pets in given format.
+func writePets(w io.Writer, format string, pets ...*oas.Pet) error {
+	switch format {
+	case outputJSON, outputYAML:
+		for _, pet := range pets {
+			data, err := pet.MarshalJSON()
+			if err != nil {
+				return errors.Wrap(err, "encode")
+			}
+			if format == outputYAML {
+				if data, err = jsonToYAML(data); err != nil {
+					return err
+				}
+			} else {
+				var buf bytes.Buffer
+				if err := json.Indent(&buf, data, "", "  "); err != nil {
+					return errors.Wrap(err, "indent")
+				}
+				data = append(buf.Bytes(), '\n')
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tPHOTO URLS")
+		for _, pet := range pets {
+			id := "-"
+			if v, ok := pet.ID.Get(); ok {
+				id = fmt.Sprint(v)
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+				id,
+				pet.Name,
+				pet.Status.Or("-"),
+				strings.Join(pet.PhotoUrls, ","),
+			)
+		}
+		return tw.Flush()
+	default:
+		return errors.Errorf("unknown output format %q", format)
+	}
+}
+
+// jsonToYAML re-encodes JSON document as YAML, keeping key order.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, errors.Wrap(err, "decode")
+	}
+	var resetStyle func(n *yaml.Node)
+	resetStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			resetStyle(c)
+		}
+	}
+	resetStyle(&node)
+	return yaml.Marshal(&node)
+}
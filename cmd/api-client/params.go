@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-faster/errors"
+)
+
+// flagName converts Go field name to flag name, like "PetId" to "pet-id".
+func flagName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isOpt reports whether t is ogen optional type, like oas.OptString.
+func isOpt(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 {
+		return false
+	}
+	v, ok := t.FieldByName("Value")
+	if !ok {
+		return false
+	}
+	set, ok := t.FieldByName("Set")
+	return ok && set.Type.Kind() == reflect.Bool && v.Index[0] == 0
+}
+
+// enumValues returns allowed values of ogen enum type, if any.
+func enumValues(t reflect.Type) []string {
+	m, ok := t.MethodByName("AllValues")
+	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 {
+		return nil
+	}
+	out := m.Func.Call([]reflect.Value{reflect.Zero(t)})[0]
+	values := make([]string, 0, out.Len())
+	for i := 0; i < out.Len(); i++ {
+		values = append(values, fmt.Sprint(out.Index(i).Interface()))
+	}
+	return values
+}
+
+// paramValue is flag.Value setting field of ogen parameters struct.
+type paramValue struct {
+	field reflect.Value
+	set   bool
+}
+
+func (p *paramValue) target() reflect.Value {
+	if isOpt(p.field.Type()) {
+		return p.field.FieldByName("Value")
+	}
+	return p.field
+}
+
+func (p *paramValue) String() string {
+	if p == nil || !p.field.IsValid() || !p.set {
+		return ""
+	}
+	return fmt.Sprint(p.target().Interface())
+}
+
+func (p *paramValue) Set(s string) error {
+	v := p.target()
+	if values := enumValues(v.Type()); len(values) > 0 {
+		if !slices.Contains(values, s) {
+			return errors.Errorf("must be one of: %s", strings.Join(values, ", "))
+		}
+	}
+	switch u := v.Addr().Interface().(type) {
+	case encoding.TextUnmarshaler:
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(s)
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetFloat(n)
+		default:
+			return errors.Errorf("unsupported type %s", v.Type())
+		}
+	}
+	if isOpt(p.field.Type()) {
+		p.field.FieldByName("Set").SetBool(true)
+	}
+	p.set = true
+	return nil
+}
+
+// paramFlags registers flag for every field of ogen parameters struct.
+//
+// Returned function checks that required parameters are set.
+func paramFlags(fs *flag.FlagSet, params any) (checkRequired func() error) {
+	v := reflect.ValueOf(params).Elem()
+	var required []*paramValue
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		pv := &paramValue{field: v.Field(i)}
+		usage := sf.Name + " parameter"
+		if values := enumValues(pv.target().Type()); len(values) > 0 {
+			usage += " (" + strings.Join(values, ", ") + ")"
+		}
+		if !isOpt(sf.Type) {
+			usage += ", required"
+			required = append(required, pv)
+			names = append(names, flagName(sf.Name))
+		}
+		fs.Var(pv, flagName(sf.Name), usage)
+	}
+	return func() error {
+		for i, pv := range required {
+			if !pv.set {
+				return errors.Errorf("flag -%s is required", names[i])
+			}
+		}
+		return nil
+	}
+}
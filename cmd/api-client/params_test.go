@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen/validate"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+func TestParamFlags(t *testing.T) {
+	newFlagSet := func(params *oas.UpdatePetParams) (*flag.FlagSet, func() error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		return fs, paramFlags(fs, params)
+	}
+
+	var params oas.UpdatePetParams
+	fs, checkRequired := newFlagSet(&params)
+	require.NoError(t, fs.Parse([]string{"-pet-id", "10", "-status", "sold"}))
+	require.NoError(t, checkRequired())
+	require.Equal(t, oas.UpdatePetParams{
+		PetId:  10,
+		Status: oas.NewOptPetStatus(oas.PetStatusSold),
+	}, params)
+
+	fs, _ = newFlagSet(&oas.UpdatePetParams{})
+	require.ErrorContains(t, fs.Parse([]string{"-status", "lost"}), "must be one of: available, pending, sold")
+
+	fs, checkRequired = newFlagSet(&oas.UpdatePetParams{})
+	require.NoError(t, fs.Parse([]string{"-name", "Tom"}))
+	require.EqualError(t, checkRequired(), "flag -pet-id is required")
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{&usageError{err: errors.New("bad flag")}, exitUsage},
+		{errors.Wrap(errNotFound, "pet 1"), exitNotFound},
+		{errors.Wrap(errRejected, "pet 1 already exists"), exitClientError},
+		{errors.Wrap(validate.UnexpectedStatusCode(404), "update pet"), exitNotFound},
+		{errors.Wrap(validate.UnexpectedStatusCode(400), "update pet"), exitClientError},
+		{errors.Wrap(validate.UnexpectedStatusCode(503), "update pet"), exitServerError},
+		{errors.Wrap(&url.Error{Op: "Get", URL: "/", Err: errors.New("refused")}, "get pet"), exitTransport},
+		{errors.New("decode response"), exitFailure},
+	} {
+		require.Equal(t, tt.code, exitCode(tt.err), "%v", tt.err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/sdk/autometer"
+	"github.com/go-faster/sdk/autotracer"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// telemetry is OpenTelemetry setup for short-lived commands.
+//
+// Unlike app.Run, it allows command to choose exit code after
+// telemetry is flushed.
+type telemetry struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	shutdown       []func(ctx context.Context) error
+}
+
+func (t *telemetry) TracerProvider() trace.TracerProvider             { return t.tracerProvider }
+func (t *telemetry) MeterProvider() metric.MeterProvider              { return t.meterProvider }
+func (t *telemetry) TextMapPropagator() propagation.TextMapPropagator { return t.propagator }
+
+// Shutdown flushes and stops telemetry providers.
+func (t *telemetry) Shutdown(ctx context.Context) (rerr error) {
+	for i := len(t.shutdown) - 1; i >= 0; i-- {
+		rerr = multierr.Append(rerr, t.shutdown[i](ctx))
+	}
+	return rerr
+}
+
+// newLogger creates logger writing to stderr, configured by OTEL_LOG_LEVEL.
+func newLogger() (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if s := os.Getenv("OTEL_LOG_LEVEL"); s != "" {
+		lvl, err := zapcore.ParseLevel(s)
+		if err != nil {
+			return nil, errors.Wrap(err, "OTEL_LOG_LEVEL")
+		}
+		cfg.Level.SetLevel(lvl)
+	}
+	return cfg.Build()
+}
+
+// newTelemetry initializes providers from OTEL_* environment variables.
+func newTelemetry(ctx context.Context) (*telemetry, error) {
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithProcess(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "resource")
+	}
+	if res, err = resource.Merge(resource.Default(), res); err != nil {
+		return nil, errors.Wrap(err, "merge resource")
+	}
+
+	t := &telemetry{
+		propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+	}
+	tp, stopTracer, err := autotracer.NewTracerProvider(ctx, autotracer.WithResource(res))
+	if err != nil {
+		return nil, errors.Wrap(err, "tracer provider")
+	}
+	t.tracerProvider = tp
+	if p, ok := tp.(interface {
+		Shutdown(ctx context.Context) error
+	}); ok {
+		// Exporter shutdown does not flush batched spans, while provider
+		// shutdown flushes them and stops exporter.
+		stopTracer = p.Shutdown
+	}
+	t.shutdown = append(t.shutdown, stopTracer)
+
+	mp, stopMeter, err := autometer.NewMeterProvider(ctx, autometer.WithResource(res))
+	if err != nil {
+		_ = t.Shutdown(ctx)
+		return nil, errors.Wrap(err, "meter provider")
+	}
+	t.meterProvider = mp
+	t.shutdown = append(t.shutdown, stopMeter)
+
+	return t, nil
+}
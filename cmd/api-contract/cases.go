@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ogen-go/ogen"
+
+	"example/internal/oasspec"
+)
+
+// testCase is a single request checked against spec.
+type testCase struct {
+	Operation *oasspec.Operation
+	Name      string
+	// Valid is true if request conforms to spec and must succeed,
+	// otherwise server must reject it with 4XX.
+	Valid bool
+	// Missing is true if request is valid but addresses entity that does
+	// not exist, so server must reject it with 4XX declared by spec.
+	Missing bool
+
+	// Path parameters by name. Empty value of integer parameter
+	// is replaced with ID of entity created by preceding cases.
+	Path   map[string]string
+	Query  url.Values
+	Header http.Header
+	// Body is nil if request has no body.
+	Body []byte
+}
+
+// methodOrder orders operations sharing path so that entities are created
+// before they are read, updated and deleted.
+var methodOrder = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodPost,
+	http.MethodDelete,
+}
+
+// generator generates test cases from spec.
+type generator struct {
+	spec *oasspec.Spec
+	// runID makes generated header values unique across runs,
+	// so repeated runs do not hit idempotency replay.
+	runID string
+	seq   int
+}
+
+// Cases returns test cases of every operation.
+//
+// Operations without path parameters go first, then operations are ordered
+// by path and method, so entity is created, read, updated and then deleted.
+// Entity is deleted after operations on its sub-resources.
+func (g *generator) Cases() []*testCase {
+	ops := slices.Clone(g.spec.Operations())
+	slices.SortStableFunc(ops, func(a, b *oasspec.Operation) int {
+		if c := compareBool(hasPathParams(a), hasPathParams(b)); c != 0 {
+			return c
+		}
+		if c := strings.Compare(resource(a.Path), resource(b.Path)); c != 0 {
+			return c
+		}
+		if c := compareBool(a.Method == http.MethodDelete, b.Method == http.MethodDelete); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return slices.Index(methodOrder, a.Method) - slices.Index(methodOrder, b.Method)
+	})
+	var cases []*testCase
+	for _, op := range ops {
+		cases = append(cases, g.operation(op)...)
+	}
+	return cases
+}
+
+func (g *generator) operation(op *oasspec.Operation) []*testCase {
+	// base returns valid request with required parameters and fields only.
+	base := func(name string, valid bool) *testCase {
+		c := &testCase{
+			Operation: op,
+			Name:      name,
+			Valid:     valid,
+			Path:      map[string]string{},
+			Query:     url.Values{},
+			Header:    http.Header{},
+		}
+		for _, p := range op.Parameters {
+			if p.Required {
+				g.setParam(c, p, g.paramValue(p))
+			}
+		}
+		if b := op.RequestBody; b != nil {
+			c.Body = g.marshal(g.value(b.Schema, false))
+		}
+		return c
+	}
+
+	var invalid []*testCase
+	valid := []*testCase{base("valid required only", true)}
+	optional := op.RequestBody != nil || slices.ContainsFunc(op.Parameters, func(p *oasspec.Parameter) bool {
+		return !p.Required
+	})
+	if full := base("valid all fields", true); optional {
+		for _, p := range op.Parameters {
+			g.setParam(full, p, g.paramValue(p))
+		}
+		if b := op.RequestBody; b != nil {
+			full.Body = g.marshal(g.value(b.Schema, true))
+		}
+		valid = append(valid, full)
+	}
+
+	for _, p := range op.Parameters {
+		schema := g.spec.Resolve(p.Schema)
+		if schema == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("%s %s", p.In, p.Name)
+		for _, v := range enumValues(schema) {
+			c := base(fmt.Sprintf("%s=%v", prefix, v), true)
+			g.setParam(c, p, fmt.Sprint(v))
+			valid = append(valid, c)
+		}
+		if m := schema.MaxLength; m != nil {
+			c := base(fmt.Sprintf("%s of max length %d", prefix, *m), true)
+			g.setParam(c, p, g.unique(int(*m)))
+			valid = append(valid, c)
+		}
+		for _, v := range g.invalidParams(schema) {
+			c := base(prefix+" "+v.Name, false)
+			g.setParam(c, p, v.Value)
+			invalid = append(invalid, c)
+		}
+		if p.In == "path" && schema.Type == "integer" {
+			c := base(prefix+" of missing entity", false)
+			c.Missing = true
+			g.setParam(c, p, missingID(schema))
+			invalid = append(invalid, c)
+		}
+		if p.Required && p.In != "path" {
+			c := base(prefix+" missing", false)
+			c.Query.Del(p.Name)
+			c.Header.Del(p.Name)
+			invalid = append(invalid, c)
+		}
+	}
+
+	if b := op.RequestBody; b != nil {
+		if b.Required {
+			c := base("body missing", false)
+			c.Body = nil
+			invalid = append(invalid, c)
+		}
+		c := base("body malformed", false)
+		c.Body = []byte(`{"`)
+		invalid = append(invalid, c)
+
+		g.bodyCases(b.Schema, func(name string, ok bool, v any) {
+			c := base("body "+name, ok)
+			c.Body = g.marshal(v)
+			if ok {
+				valid = append(valid, c)
+			} else {
+				invalid = append(invalid, c)
+			}
+		})
+	}
+
+	// Invalid requests go first, so valid ones of the same operation
+	// act on entity that was not modified by buggy server.
+	return append(invalid, valid...)
+}
+
+// bodyCases calls add for mutations of JSON object of schema.
+func (g *generator) bodyCases(schema *ogen.Schema, add func(name string, valid bool, v any)) {
+	schema = g.spec.Resolve(schema)
+	if schema == nil || len(schema.Properties) == 0 {
+		return
+	}
+	for _, name := range schema.Required {
+		obj := g.value(schema, true).(map[string]any)
+		delete(obj, name)
+		add(fmt.Sprintf("missing required %s", name), false, obj)
+	}
+	for _, p := range schema.Properties {
+		ps := g.spec.Resolve(p.Schema)
+		if ps == nil {
+			continue
+		}
+		set := func(v any) map[string]any {
+			obj := g.value(schema, false).(map[string]any)
+			obj[p.Name] = v
+			return obj
+		}
+		for _, v := range enumValues(ps) {
+			add(fmt.Sprintf("%s=%v", p.Name, v), true, set(v))
+		}
+		if v, ok := wrongType(ps); ok {
+			add(fmt.Sprintf("%s of wrong type", p.Name), false, set(v))
+		}
+		if len(ps.Enum) > 0 {
+			add(fmt.Sprintf("%s not in enum", p.Name), false, set(notInEnum(ps)))
+		}
+		if ps.Type == "integer" {
+			add(fmt.Sprintf("%s overflow", p.Name), false, set(json.Number(overflow(ps))))
+		}
+		if !ps.Nullable {
+			add(fmt.Sprintf("%s null", p.Name), false, set(nil))
+		}
+	}
+}
+
+// namedValue is a raw parameter value with test case name.
+type namedValue struct {
+	Name  string
+	Value string
+}
+
+// invalidParams returns invalid raw values of parameter.
+func (g *generator) invalidParams(schema *ogen.Schema) (r []namedValue) {
+	switch schema.Type {
+	case "integer", "number":
+		r = append(r, namedValue{"of wrong type", "abc"})
+	case "boolean":
+		r = append(r, namedValue{"of wrong type", "yes"})
+	}
+	if schema.Type == "integer" {
+		r = append(r, namedValue{"overflow", overflow(schema)})
+	}
+	if len(schema.Enum) > 0 {
+		r = append(r, namedValue{"not in enum", notInEnum(schema)})
+	}
+	if m := schema.MaxLength; m != nil {
+		r = append(r, namedValue{fmt.Sprintf("longer than %d", *m), g.unique(int(*m) + 1)})
+	}
+	// Empty value is indistinguishable from missing one, so minimum length
+	// boundary is checked only if it is greater than one.
+	if m := schema.MinLength; m != nil && *m > 1 {
+		r = append(r, namedValue{fmt.Sprintf("shorter than %d", *m), strings.Repeat("x", int(*m)-1)})
+	}
+	return r
+}
+
+func (g *generator) setParam(c *testCase, p *oasspec.Parameter, v string) {
+	switch p.In {
+	case "path":
+		c.Path[p.Name] = v
+	case "query":
+		c.Query.Set(p.Name, v)
+	case "header":
+		c.Header.Set(p.Name, v)
+	}
+}
+
+// paramValue returns valid raw value of parameter.
+func (g *generator) paramValue(p *oasspec.Parameter) string {
+	schema := g.spec.Resolve(p.Schema)
+	switch {
+	case schema == nil:
+		return "x"
+	case p.In == "path" && schema.Type == "integer":
+		// Filled with created entity ID.
+		return ""
+	case p.In == "header" && schema.Type == "string" && len(schema.Enum) == 0:
+		// Headers are likely keys like Idempotency-Key, keep them unique.
+		n := 32
+		if m := schema.MaxLength; m != nil {
+			n = min(n, int(*m))
+		}
+		return g.unique(n)
+	}
+	switch v := g.value(schema, false).(type) {
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// value returns valid value of schema. Optional object properties
+// are included only if full is true.
+//
+// Integer "id" properties are never set, so server assigns them
+// instead of overwriting existing entities.
+func (g *generator) value(schema *ogen.Schema, full bool) any {
+	schema = g.spec.Resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	for _, raw := range [][]byte{schema.Example, schema.Default} {
+		if len(raw) == 0 {
+			continue
+		}
+		if v, err := oasspec.DecodeJSON(raw); err == nil {
+			return v
+		}
+	}
+	if values := enumValues(schema); len(values) > 0 {
+		return values[0]
+	}
+	switch schema.Type {
+	case "object", "":
+		obj := map[string]any{}
+		for _, p := range schema.Properties {
+			if p.Name == "id" && g.spec.Resolve(p.Schema).Type == "integer" {
+				continue
+			}
+			if full || slices.Contains(schema.Required, p.Name) {
+				obj[p.Name] = g.value(p.Schema, full)
+			}
+		}
+		return obj
+	case "array":
+		arr := []any{}
+		if schema.Items != nil && schema.Items.Item != nil {
+			arr = append(arr, g.value(schema.Items.Item, full))
+		}
+		return arr
+	case "string":
+		n := 6
+		if m := schema.MinLength; m != nil {
+			n = max(n, int(*m))
+		}
+		if m := schema.MaxLength; m != nil {
+			n = min(n, int(*m))
+		}
+		return strings.Repeat("x", n)
+	case "integer", "number":
+		if len(schema.Minimum) > 0 {
+			return json.Number(schema.Minimum)
+		}
+		return json.Number("1")
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+// unique returns unique string of given length.
+func (g *generator) unique(n int) string {
+	g.seq++
+	s := fmt.Sprintf("%s-%d", g.runID, g.seq)
+	if len(s) >= n {
+		return s[len(s)-n:]
+	}
+	return s + strings.Repeat("x", n-len(s))
+}
+
+func (g *generator) marshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func enumValues(schema *ogen.Schema) (r []any) {
+	for _, raw := range schema.Enum {
+		if v, err := oasspec.DecodeJSON(raw); err == nil {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func notInEnum(schema *ogen.Schema) string {
+	v := "unknown"
+	for slices.Contains(enumValues(schema), any(v)) {
+		v += "x"
+	}
+	return v
+}
+
+// wrongType returns value of type other than schema type.
+func wrongType(schema *ogen.Schema) (any, bool) {
+	switch schema.Type {
+	case "string":
+		return json.Number("123"), true
+	case "integer", "number", "boolean", "array":
+		return "abc", true
+	case "object":
+		return []any{}, true
+	}
+	return nil, false
+}
+
+// missingID returns valid ID that is not assigned to any entity.
+func missingID(schema *ogen.Schema) string {
+	if schema.Format == "int32" {
+		return "2147483647"
+	}
+	return "9223372036854775807"
+}
+
+// overflow returns integer out of range of schema format.
+func overflow(schema *ogen.Schema) string {
+	if schema.Format == "int32" {
+		return "2147483648"
+	}
+	return "9223372036854775808"
+}
+
+// resource returns collection path of entity addressed by path,
+// e.g. "/pet" for "/pet/{petId}".
+func resource(path string) string {
+	if i := strings.IndexByte(path, '{'); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimSuffix(path, "/")
+}
+
+func hasPathParams(op *oasspec.Operation) bool {
+	return slices.ContainsFunc(op.Parameters, func(p *oasspec.Parameter) bool {
+		return p.In == "path"
+	})
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+// Command api-contract checks that running server conforms to OpenAPI spec.
+//
+// It generates valid and invalid requests for every operation of spec,
+// sends them to server and validates response status codes and bodies
+// against declared schemas.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"example"
+	"example/internal/oasspec"
+)
+
+// errFailed is returned if some test cases failed.
+var errFailed = errors.New("contract check failed")
+
+// usageError is an error in command line arguments.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	var arg struct {
+		BaseURL      string
+		Spec         string
+		JUnit        string
+		APIKey       string
+		APIKeyHeader string
+		Timeout      time.Duration
+	}
+	fs := flag.NewFlagSet("api-contract", flag.ContinueOnError)
+	fs.StringVar(&arg.BaseURL, "url", "http://server:8080", "target server url")
+	fs.StringVar(&arg.Spec, "spec", "", "OpenAPI spec file, embedded spec is used if empty")
+	fs.StringVar(&arg.JUnit, "junit", "", "write JUnit XML report to file")
+	fs.StringVar(&arg.APIKey, "api-key", "", "API key sent with every request")
+	fs.StringVar(&arg.APIKeyHeader, "api-key-header", "X-API-Key", "API key header")
+	fs.DurationVar(&arg.Timeout, "timeout", 10*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return &usageError{err: err}
+	}
+	baseURL, err := url.Parse(arg.BaseURL)
+	if err != nil || baseURL.Host == "" {
+		return &usageError{err: errors.Errorf("invalid url %q", arg.BaseURL)}
+	}
+
+	data := example.OpenAPISpec
+	if arg.Spec != "" {
+		if data, err = os.ReadFile(arg.Spec); err != nil {
+			return errors.Wrap(err, "read spec")
+		}
+	}
+	spec, err := oasspec.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	r := &runner{
+		Spec:    spec,
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: arg.Timeout},
+		Header:  http.Header{},
+	}
+	if arg.APIKey != "" {
+		r.Header.Set(arg.APIKeyHeader, arg.APIKey)
+	}
+	g := &generator{
+		spec:  spec,
+		runID: strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	results := r.Run(ctx, g.Cases())
+
+	if err := writeText(stdout, results); err != nil {
+		return errors.Wrap(err, "write report")
+	}
+	if arg.JUnit != "" {
+		f, err := os.Create(arg.JUnit)
+		if err != nil {
+			return errors.Wrap(err, "create junit report")
+		}
+		if err := writeJUnit(f, results); err != nil {
+			_ = f.Close()
+			return errors.Wrap(err, "write junit report")
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrap(err, "close junit report")
+		}
+	}
+	for _, res := range results {
+		if res.Failure != "" {
+			return errFailed
+		}
+	}
+	return nil
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:], os.Stdout)
+	cancel()
+
+	code := 0
+	var usageErr *usageError
+	switch {
+	case err == nil:
+	case errors.As(err, &usageErr):
+		code = 2
+	default:
+		code = 1
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "api-contract: %v\n", err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example"
+	"example/internal/apiserver"
+	"example/internal/apitest"
+	"example/internal/oasspec"
+)
+
+func TestContract(t *testing.T) {
+	cfg := apiserver.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.Keys = []string{"secret"}
+	e := apitest.New(t, cfg)
+
+	report := filepath.Join(t.TempDir(), "report.xml")
+	var out bytes.Buffer
+	err := run(context.Background(), []string{
+		"-url", e.Server.URL,
+		"-api-key", "secret",
+		"-junit", report,
+	}, &out)
+	require.NoError(t, err, out.String())
+	require.NotContains(t, out.String(), "FAIL")
+	for _, name := range []string{
+		"body missing required name",
+		"body status=pending",
+		"body status not in enum",
+		"query status=sold",
+		"query status not in enum",
+		"header Idempotency-Key longer than 255",
+		"path petId of wrong type",
+		"path petId of missing entity",
+		"path webhookId of missing entity",
+	} {
+		require.Contains(t, out.String(), name)
+	}
+
+	data, err := os.ReadFile(report)
+	require.NoError(t, err)
+	var suites junitSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	require.Zero(t, suites.Failures)
+	var names []string
+	for _, s := range suites.Suites {
+		names = append(names, s.Name)
+	}
+	require.Equal(t, []string{
+		"addPet",
+		"listWebhooks",
+		"createWebhook",
+		"getPetById",
+		"updatePet",
+		"deletePet",
+		"getWebhook",
+		"listWebhookDeliveries",
+		"enableWebhook",
+		"deleteWebhook",
+	}, names)
+}
+
+func TestContractFailure(t *testing.T) {
+	// Server accepts anything and returns pet with invalid status.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":1,"name":"Tom","status":"lost"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	report := filepath.Join(t.TempDir(), "report.xml")
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-url", srv.URL, "-junit", report}, &out)
+	require.ErrorIs(t, err, errFailed)
+	require.Contains(t, out.String(), `invalid response: $.status: value lost is not one of enum values`)
+	require.Contains(t, out.String(), "status 200, expected 4XX")
+	require.Contains(t, out.String(), "status 200, expected declared 4XX for missing entity")
+
+	data, err := os.ReadFile(report)
+	require.NoError(t, err)
+	var suites junitSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	var lines int
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "PASS") || strings.HasPrefix(line, "FAIL") {
+			lines++
+		}
+	}
+	require.Equal(t, lines, suites.Tests)
+	require.NotZero(t, suites.Failures)
+}
+
+func TestGenerator(t *testing.T) {
+	spec, err := oasspec.Parse(example.OpenAPISpec)
+	require.NoError(t, err)
+	g := &generator{spec: spec, runID: "run"}
+
+	var (
+		addPet  []*testCase
+		ops     []string
+		missing []string
+	)
+	for _, c := range g.Cases() {
+		if c.Operation.ID == "addPet" {
+			addPet = append(addPet, c)
+		}
+		if c.Missing {
+			require.NotEmpty(t, c.Path, c.Name)
+			missing = append(missing, c.Operation.ID)
+		}
+		if !slices.Contains(ops, c.Operation.ID) {
+			ops = append(ops, c.Operation.ID)
+		}
+	}
+	// Entities are created first and deleted after operations on sub-resources.
+	require.Equal(t, []string{
+		"addPet",
+		"listWebhooks",
+		"createWebhook",
+		"getPetById",
+		"updatePet",
+		"deletePet",
+		"getWebhook",
+		"listWebhookDeliveries",
+		"enableWebhook",
+		"deleteWebhook",
+	}, ops)
+	require.Subset(t, missing, []string{"getPetById", "updatePet", "deletePet"})
+	for _, c := range addPet {
+		if c.Valid {
+			// Server assigns ID.
+			require.NotContains(t, string(c.Body), `"id"`, c.Name)
+		}
+		if key := c.Header.Get("Idempotency-Key"); key != "" {
+			require.Contains(t, key, "run-", c.Name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// writeText writes human-readable report.
+func writeText(w io.Writer, results []result) error {
+	var failed int
+	for _, r := range results {
+		c := r.Case
+		status := "PASS"
+		if r.Failure != "" {
+			status = "FAIL"
+			failed++
+		}
+		if _, err := fmt.Fprintf(w, "%s  %-12s %s (%s)\n", status, c.Operation.ID, c.Name, r.Duration.Round(time.Millisecond)); err != nil {
+			return err
+		}
+		if r.Failure != "" {
+			if _, err := fmt.Fprintf(w, "      %s %s: %s\n", c.Operation.Method, c.Operation.Path, r.Failure); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d failed\n", len(results)-failed, failed)
+	return err
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes JUnit XML report with test suite per operation.
+func writeJUnit(w io.Writer, results []result) error {
+	report := junitSuites{Name: "api-contract"}
+	index := map[string]int{}
+	for _, r := range results {
+		c := r.Case
+		idx, ok := index[c.Operation.ID]
+		if !ok {
+			idx = len(report.Suites)
+			index[c.Operation.ID] = idx
+			report.Suites = append(report.Suites, junitSuite{Name: c.Operation.ID})
+		}
+		s := &report.Suites[idx]
+		tc := junitCase{
+			Name:      c.Name,
+			ClassName: c.Operation.ID,
+			Time:      r.Duration.Seconds(),
+		}
+		if r.Failure != "" {
+			tc.Failure = &junitFailure{
+				Message: r.Failure,
+				Text:    fmt.Sprintf("%s %s: %s", c.Operation.Method, c.Operation.Path, r.Failure),
+			}
+			s.Failures++
+			report.Failures++
+		}
+		s.Cases = append(s.Cases, tc)
+		s.Tests++
+		s.Time += tc.Time
+		report.Tests++
+		report.Time += tc.Time
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"example/internal/oasspec"
+)
+
+// result is a result of test case.
+type result struct {
+	Case     *testCase
+	Status   int
+	Duration time.Duration
+	// Failure is empty if case passed.
+	Failure string
+}
+
+// runner sends test cases to server and checks responses.
+type runner struct {
+	Spec    *oasspec.Spec
+	BaseURL *url.URL
+	Client  *http.Client
+	// Header is added to every request, e.g. API key.
+	Header http.Header
+
+	// lastID is ID of last entity returned by valid request, by resource.
+	lastID map[string]string
+}
+
+// Run runs test cases in order.
+func (r *runner) Run(ctx context.Context, cases []*testCase) []result {
+	results := make([]result, 0, len(cases))
+	for _, c := range cases {
+		start := time.Now()
+		status, err := r.run(ctx, c)
+		res := result{
+			Case:     c,
+			Status:   status,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			res.Failure = err.Error()
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (r *runner) run(ctx context.Context, c *testCase) (int, error) {
+	req, err := r.request(ctx, c)
+	if err != nil {
+		return 0, errors.Wrap(err, "create request")
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "send request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, errors.Wrap(err, "read response")
+	}
+	return resp.StatusCode, r.check(c, resp, body)
+}
+
+func (r *runner) request(ctx context.Context, c *testCase) (*http.Request, error) {
+	path := c.Operation.Path
+	for name, v := range c.Path {
+		if v == "" {
+			id, ok := r.lastID[resource(path)]
+			if !ok {
+				return nil, errors.Errorf("no entity created to fill %q", name)
+			}
+			v = id
+		}
+		path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(v))
+	}
+	u := r.BaseURL.JoinPath(path)
+	u.RawQuery = c.Query.Encode()
+
+	var body io.Reader
+	if c.Body != nil {
+		body = bytes.NewReader(c.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.Operation.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	if c.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// check checks response to test case.
+func (r *runner) check(c *testCase, resp *http.Response, body []byte) error {
+	code := resp.StatusCode
+	declared, ok := c.Operation.Response(code)
+	switch {
+	case c.Missing && (!ok || code < 400 || code >= 500):
+		return errors.Errorf("status %d, expected declared 4XX for missing entity%s", code, snippet(body))
+	case c.Valid && !ok:
+		return errors.Errorf("status %d is not declared%s", code, snippet(body))
+	case c.Valid && code >= 400:
+		return errors.Errorf("status %d, expected success%s", code, snippet(body))
+	case !c.Valid && (code < 400 || code >= 500):
+		return errors.Errorf("status %d, expected 4XX%s", code, snippet(body))
+	}
+	if !ok || declared.Schema == nil {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != declared.ContentType {
+		return errors.Errorf("content type %q, expected %q", resp.Header.Get("Content-Type"), declared.ContentType)
+	}
+	v, err := oasspec.DecodeJSON(body)
+	if err != nil {
+		return errors.Wrapf(err, "decode response%s", snippet(body))
+	}
+	if err := r.Spec.Validate(declared.Schema, v); err != nil {
+		return errors.Wrap(err, "invalid response")
+	}
+	if obj, ok := v.(map[string]any); ok && c.Valid {
+		if id, ok := obj["id"].(json.Number); ok {
+			if _, err := strconv.ParseInt(id.String(), 10, 64); err == nil {
+				if r.lastID == nil {
+					r.lastID = map[string]string{}
+				}
+				r.lastID[resource(c.Operation.Path)] = id.String()
+			}
+		}
+	}
+	return nil
+}
+
+// snippet returns beginning of response body for failure message.
+func snippet(body []byte) string {
+	const limit = 200
+	s := strings.TrimSpace(string(body))
+	if s == "" {
+		return ""
+	}
+	if len(s) > limit {
+		s = s[:limit] + "..."
+	}
+	return fmt.Sprintf(": %s", s)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen"
+	"go.uber.org/zap"
+
+	"example/internal/httpmiddleware"
+)
+
+// routeEntry describes route served by ogen server.
+type routeEntry struct {
+	OperationID string `json:"operationId"`
+	Name        string `json:"name"`
+	Method      string `json:"method"`
+	PathPattern string `json:"pathPattern"`
+}
+
+var pathParam = regexp.MustCompile(`\{[^/}]+\}`)
+
+// routeTable lists operations of spec as resolved by given route finder.
+func routeTable(spec []byte, find httpmiddleware.RouteFinder) ([]routeEntry, error) {
+	s, err := ogen.Parse(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse spec")
+	}
+	var routes []routeEntry
+	for path, item := range s.Paths {
+		for method, op := range map[string]*ogen.Operation{
+			http.MethodGet:     item.Get,
+			http.MethodPut:     item.Put,
+			http.MethodPost:    item.Post,
+			http.MethodDelete:  item.Delete,
+			http.MethodOptions: item.Options,
+			http.MethodHead:    item.Head,
+			http.MethodPatch:   item.Patch,
+		} {
+			if op == nil {
+				continue
+			}
+			// Any segment value matches parameter in ogen router.
+			u := &url.URL{Path: pathParam.ReplaceAllString(path, "0")}
+			route, ok := find(method, u)
+			if !ok {
+				return nil, errors.Errorf("%s %s: route not found", method, path)
+			}
+			routes = append(routes, routeEntry{
+				OperationID: route.OperationID(),
+				Name:        route.Name(),
+				Method:      method,
+				PathPattern: route.PathPattern(),
+			})
+		}
+	}
+	slices.SortFunc(routes, func(a, b routeEntry) int {
+		if c := strings.Compare(a.PathPattern, b.PathPattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return routes, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	_ = e.Encode(v)
+}
+
+// newAdminHandler returns handler of admin listener.
+//
+// Endpoints:
+//
+//	/debug/pprof/  net/http/pprof profiles
+//	/loglevel      GET or PUT {"level":"debug"} to change log level
+//	/buildinfo     build information of binary
+//	/routes        operations served by API
+//	/chaos         GET or PUT fault injection config, if fault injection is allowed
+func newAdminHandler(level zap.AtomicLevel, routes []routeEntry, chaos *httpmiddleware.Chaos) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /loglevel", level)
+	mux.Handle("PUT /loglevel", level)
+	mux.HandleFunc("GET /buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			http.Error(w, "build info is not available", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, info)
+	})
+	mux.HandleFunc("GET /routes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, routes)
+	})
+	if chaos != nil {
+		mux.Handle("GET /chaos", chaos)
+		mux.Handle("PUT /chaos", chaos)
+	}
+	return mux
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"example"
+	"example/internal/api"
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+// noopMetrics implements httpmiddleware.Metrics with no-op providers.
+type noopMetrics struct{}
+
+func (noopMetrics) TracerProvider() trace.TracerProvider { return tracenoop.NewTracerProvider() }
+func (noopMetrics) MeterProvider() metric.MeterProvider  { return metricnoop.NewMeterProvider() }
+func (noopMetrics) TextMapPropagator() propagation.TextMapPropagator {
+	return propagation.TraceContext{}
+}
+
+func TestAdminHandler(t *testing.T) {
+	srv, err := oas.NewServer(api.NewHandler(api.NewMemoryStorage()))
+	require.NoError(t, err)
+	routes, err := routeTable(example.OpenAPISpec, httpmiddleware.MakeRouteFinder(srv))
+	require.NoError(t, err)
+	require.Equal(t, []routeEntry{
+		{OperationID: "addPet", Name: "AddPet", Method: http.MethodPost, PathPattern: "/pet"},
+		{OperationID: "deletePet", Name: "DeletePet", Method: http.MethodDelete, PathPattern: "/pet/{petId}"},
+		{OperationID: "getPetById", Name: "GetPetById", Method: http.MethodGet, PathPattern: "/pet/{petId}"},
+		{OperationID: "updatePet", Name: "UpdatePet", Method: http.MethodPost, PathPattern: "/pet/{petId}"},
+		{OperationID: "listWebhooks", Name: "ListWebhooks", Method: http.MethodGet, PathPattern: "/webhooks"},
+		{OperationID: "createWebhook", Name: "CreateWebhook", Method: http.MethodPost, PathPattern: "/webhooks"},
+		{OperationID: "deleteWebhook", Name: "DeleteWebhook", Method: http.MethodDelete, PathPattern: "/webhooks/{webhookId}"},
+		{OperationID: "getWebhook", Name: "GetWebhook", Method: http.MethodGet, PathPattern: "/webhooks/{webhookId}"},
+		{OperationID: "listWebhookDeliveries", Name: "ListWebhookDeliveries", Method: http.MethodGet, PathPattern: "/webhooks/{webhookId}/deliveries"},
+		{OperationID: "enableWebhook", Name: "EnableWebhook", Method: http.MethodPost, PathPattern: "/webhooks/{webhookId}/enable"},
+	}, routes)
+
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	h := newAdminHandler(level, routes, nil)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/routes", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var got []routeEntry
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &got))
+	require.Equal(t, routes, got)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/buildinfo", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Contains(t, rw.Body.String(), "GoVersion")
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	// Fault injection is not allowed.
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/chaos", nil))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+
+	chaos, err := httpmiddleware.NewChaos(httpmiddleware.MakeRouteFinder(srv), noopMetrics{})
+	require.NoError(t, err)
+	h = newAdminHandler(level, routes, chaos)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/chaos", strings.NewReader(`{"enabled":true,"operations":{"getPetById":{"error_rate":0.5}}}`)))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	require.True(t, chaos.Config().Enabled)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/chaos", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Contains(t, rw.Body.String(), "getPetById")
+}
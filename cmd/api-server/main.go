@@ -3,72 +3,148 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/go-faster/errors"
 	"github.com/go-faster/sdk/app"
 	"github.com/go-faster/sdk/zctx"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/errgroup"
 
-	"example/internal/api"
-	"example/internal/httpmiddleware"
-	"example/internal/oas"
+	"example"
+	"example/internal/apiserver"
 )
 
-const shutdownTimeout = 15 * time.Second
+// serveHTTP runs server until ctx is done, then shuts it down gracefully.
+func serveHTTP(ctx context.Context, lg *zap.Logger, srv *http.Server, shutdownTimeout time.Duration) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		// Wait until g ctx canceled, then try to shut down server.
+		<-ctx.Done()
 
-func main() {
-	app.Run(func(ctx context.Context, lg *zap.Logger, m *app.Telemetry) error {
-		var arg struct {
-			Addr string
+		lg.Info("Shutting down", zap.Duration("timeout", shutdownTimeout))
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	})
+	g.Go(func() error {
+		defer lg.Info("Server stopped")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrap(err, "http")
 		}
-		flag.StringVar(&arg.Addr, "addr", "0.0.0.0:8080", "listen address")
-		flag.Parse()
+		return nil
+	})
+	return g.Wait()
+}
+
+func run(
+	ctx context.Context,
+	lg *zap.Logger,
+	m *app.Telemetry,
+	loader *apiserver.ConfigLoader,
+	cfg apiserver.Config,
+	level zap.AtomicLevel,
+) error {
+	lg.Info("Initializing",
+		zap.String("http.addr", cfg.Listen.Addr),
+		zap.String("admin.addr", cfg.Listen.AdminAddr),
+		zap.String("storage.driver", cfg.Storage.Driver),
+	)
+	storage, err := apiserver.OpenStorage(cfg.Storage)
+	if err != nil {
+		return errors.Wrap(err, "storage")
+	}
+	defer func() { _ = storage.Close() }()
 
-		lg.Info("Initializing",
-			zap.String("http.addr", arg.Addr),
-		)
-		oasServer, err := oas.NewServer(api.Handler{},
-			oas.WithTracerProvider(m.TracerProvider()),
-			oas.WithMeterProvider(m.MeterProvider()),
-		)
+	server, err := apiserver.New(cfg, apiserver.Options{
+		Storage: storage,
+		Metrics: m,
+		Logger:  zctx.From(ctx),
+		Level:   level,
+		Loader:  loader,
+	})
+	if err != nil {
+		return errors.Wrap(err, "server")
+	}
+	defer func() {
+		if err := server.Close(); err != nil {
+			lg.Warn("Failed to close server", zap.Error(err))
+		}
+	}()
+	httpServer := &http.Server{
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		Addr:              cfg.Listen.Addr,
+		Handler:           server,
+	}
+	httpServer.RegisterOnShutdown(server.CloseStreams)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return server.Run(ctx)
+	})
+	g.Go(func() error {
+		return server.Relay().Run(ctx)
+	})
+	g.Go(func() error {
+		return serveHTTP(ctx, lg, httpServer, cfg.Timeouts.Shutdown)
+	})
+	if addr := cfg.Listen.AdminAddr; addr != "" {
+		routes, err := routeTable(example.OpenAPISpec, server.RouteFinder())
 		if err != nil {
-			return errors.Wrap(err, "server init")
+			return errors.Wrap(err, "route table")
 		}
-
-		// Using OpenTelemetry instrumentation for HTTP server.
-		routeFinder := httpmiddleware.MakeRouteFinder(oasServer)
-		httpServer := http.Server{
-			ReadHeaderTimeout: time.Second,
-			Addr:              arg.Addr,
-			Handler: httpmiddleware.Wrap(oasServer,
-				httpmiddleware.InjectLogger(zctx.From(ctx)),
-				httpmiddleware.Instrument("api", routeFinder, m),
-				httpmiddleware.LogRequests(routeFinder),
-				httpmiddleware.Labeler(routeFinder),
-			),
+		adminServer := &http.Server{
+			ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+			Addr:              addr,
+			Handler:           newAdminHandler(level, routes, server.Chaos()),
 		}
-		g, ctx := errgroup.WithContext(ctx)
 		g.Go(func() error {
-			// Wait until g ctx canceled, then try to shut down server.
-			<-ctx.Done()
+			return serveHTTP(ctx, lg.Named("admin"), adminServer, cfg.Timeouts.Shutdown)
+		})
+	}
 
-			lg.Info("Shutting down", zap.Duration("timeout", shutdownTimeout))
+	return g.Wait()
+}
 
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-			defer cancel()
-			return httpServer.Shutdown(shutdownCtx)
-		})
-		g.Go(func() error {
-			defer lg.Info("Server stopped")
-			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return errors.Wrap(err, "http")
-			}
-			return nil
-		})
+func main() {
+	var (
+		loader      apiserver.ConfigLoader
+		printConfig bool
+	)
+	flag.StringVar(&loader.Path, "config", os.Getenv(apiserver.EnvPrefix+"CONFIG"), "path to YAML or TOML config file (env "+apiserver.EnvPrefix+"CONFIG)")
+	flag.BoolVar(&printConfig, "print-config", false, "print effective config with secrets redacted and exit")
+	loader.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-		return g.Wait()
-	})
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		data, err := apiserver.MarshalConfig(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+
+	// Level is shared with reloader to change it at runtime.
+	level := zap.NewAtomicLevel()
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zapConfig.Level = level
+
+	app.Run(func(ctx context.Context, lg *zap.Logger, m *app.Telemetry) error {
+		return run(ctx, lg, m, &loader, cfg, level)
+	}, app.WithZapConfig(zapConfig))
 }
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CloudEvents content types.
+const (
+	// CloudEventsContentType is a content type of event in structured mode.
+	CloudEventsContentType = "application/cloudevents+json"
+	// CloudEventsDataContentType is a content type of event data.
+	CloudEventsDataContentType = "application/json"
+)
+
+// CloudEventTypePrefix is a prefix of CloudEvents type of pet change event,
+// e.g. "com.example.pet.status_changed".
+const CloudEventTypePrefix = "com.example.pet."
+
+// CloudEvent is a CloudEvents 1.0 envelope of pet change event.
+//
+// Data is a pet JSON, it is empty for EventDeleted.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	Data            []byte
+	// TraceParent and TraceState are Distributed Tracing extension
+	// attributes, context of span that published event.
+	TraceParent string
+	TraceState  string
+}
+
+// CloudEventsConfig configures conversion of events to CloudEvents.
+type CloudEventsConfig struct {
+	// Source is a URI reference of event source.
+	//
+	// Defaults to "/pet".
+	Source string
+	// DataSchema is an URI of Pet schema, omitted if empty.
+	DataSchema string
+}
+
+func (c *CloudEventsConfig) setDefaults() {
+	if c.Source == "" {
+		c.Source = "/pet"
+	}
+}
+
+// NewCloudEvent converts pet change event to CloudEvent.
+//
+// Event committed to outbox keeps its outbox ID, so redelivered event has
+// the same ID and consumers can deduplicate it.
+func NewCloudEvent(ev Event, cfg CloudEventsConfig) CloudEvent {
+	cfg.setDefaults()
+	id := ev.ID
+	if ev.OutboxID != 0 {
+		id = ev.OutboxID
+	}
+	ce := CloudEvent{
+		ID:      strconv.FormatUint(id, 10),
+		Source:  cfg.Source,
+		Type:    CloudEventTypePrefix + string(ev.Type),
+		Subject: strconv.FormatInt(ev.PetID, 10),
+		Time:    ev.Time.UTC(),
+	}
+	if ev.Type != EventDeleted {
+		e := jx.GetEncoder()
+		ev.Pet.Encode(e)
+		ce.Data = append([]byte(nil), e.Bytes()...)
+		jx.PutEncoder(e)
+		ce.DataContentType = CloudEventsDataContentType
+		ce.DataSchema = cfg.DataSchema
+	}
+	if ev.Trace.IsValid() {
+		carrier := propagation.MapCarrier{}
+		propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), ev.Trace), carrier)
+		ce.TraceParent = carrier.Get("traceparent")
+		ce.TraceState = carrier.Get("tracestate")
+	}
+	return ce
+}
+
+// attrs calls f for every set context attribute except data content type.
+func (ce CloudEvent) attrs(f func(name, value string)) {
+	f("specversion", "1.0")
+	f("id", ce.ID)
+	f("source", ce.Source)
+	f("type", ce.Type)
+	for _, a := range []struct{ name, value string }{
+		{"subject", ce.Subject},
+		{"dataschema", ce.DataSchema},
+		{"traceparent", ce.TraceParent},
+		{"tracestate", ce.TraceState},
+	} {
+		if a.value != "" {
+			f(a.name, a.value)
+		}
+	}
+	if !ce.Time.IsZero() {
+		f("time", ce.Time.Format(time.RFC3339Nano))
+	}
+}
+
+// set sets context attribute, unknown extensions are ignored.
+func (ce *CloudEvent) set(name, value string) error {
+	switch name {
+	case "specversion":
+		if value != "1.0" {
+			return errors.Errorf("unsupported specversion %q", value)
+		}
+	case "id":
+		ce.ID = value
+	case "source":
+		ce.Source = value
+	case "type":
+		ce.Type = value
+	case "subject":
+		ce.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return errors.Wrap(err, "time")
+		}
+		ce.Time = t
+	case "datacontenttype":
+		ce.DataContentType = value
+	case "dataschema":
+		ce.DataSchema = value
+	case "traceparent":
+		ce.TraceParent = value
+	case "tracestate":
+		ce.TraceState = value
+	}
+	return nil
+}
+
+func (ce CloudEvent) validate() error {
+	switch {
+	case ce.ID == "":
+		return errors.New("id is required")
+	case ce.Source == "":
+		return errors.New("source is required")
+	case ce.Type == "":
+		return errors.New("type is required")
+	default:
+		return nil
+	}
+}
+
+// Encode encodes event as JSON in structured mode.
+func (ce CloudEvent) Encode(e *jx.Encoder) {
+	e.Obj(func(e *jx.Encoder) {
+		ce.attrs(func(name, value string) {
+			e.Field(name, func(e *jx.Encoder) { e.Str(value) })
+		})
+		if len(ce.Data) > 0 {
+			e.Field("datacontenttype", func(e *jx.Encoder) { e.Str(ce.DataContentType) })
+			e.Field("data", func(e *jx.Encoder) { e.Raw(ce.Data) })
+		}
+	})
+}
+
+// Decode decodes event from JSON in structured mode.
+func (ce *CloudEvent) Decode(d *jx.Decoder) error {
+	var versioned bool
+	if err := d.ObjBytes(func(d *jx.Decoder, key []byte) error {
+		switch string(key) {
+		case "data":
+			raw, err := d.Raw()
+			if err != nil {
+				return err
+			}
+			ce.Data = append([]byte(nil), raw...)
+			return nil
+		case "data_base64":
+			return errors.New("binary data is not supported")
+		default:
+			if d.Next() != jx.String {
+				// Extensions of other types.
+				return d.Skip()
+			}
+			v, err := d.Str()
+			if err != nil {
+				return err
+			}
+			versioned = versioned || string(key) == "specversion"
+			return ce.set(string(key), v)
+		}
+	}); err != nil {
+		return err
+	}
+	if !versioned {
+		return errors.New("specversion is required")
+	}
+	return ce.validate()
+}
+
+// WriteHTTP writes event in binary mode: attributes to ce-* headers and data
+// content type to Content-Type. Returns request or response body.
+func (ce CloudEvent) WriteHTTP(h http.Header) []byte {
+	ce.attrs(func(name, value string) {
+		h.Set("ce-"+name, value)
+	})
+	if len(ce.Data) > 0 {
+		h.Set("Content-Type", ce.DataContentType)
+	}
+	return ce.Data
+}
+
+// ReadCloudEvent reads event from HTTP message in structured or binary mode.
+func ReadCloudEvent(h http.Header, body []byte) (CloudEvent, error) {
+	var ce CloudEvent
+	if mt, _, _ := mime.ParseMediaType(h.Get("Content-Type")); mt == CloudEventsContentType {
+		if err := ce.Decode(jx.DecodeBytes(body)); err != nil {
+			return ce, errors.Wrap(err, "decode structured event")
+		}
+		return ce, nil
+	}
+	if h.Get("ce-specversion") == "" {
+		return ce, errors.New("not a CloudEvents message")
+	}
+	for name, values := range h {
+		attr, ok := strings.CutPrefix(strings.ToLower(name), "ce-")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if err := ce.set(attr, values[0]); err != nil {
+			return ce, errors.Wrapf(err, "header %s", name)
+		}
+	}
+	if len(body) > 0 {
+		ce.DataContentType = h.Get("Content-Type")
+		ce.Data = body
+	}
+	if err := ce.validate(); err != nil {
+		return ce, errors.Wrap(err, "binary event")
+	}
+	return ce, nil
+}
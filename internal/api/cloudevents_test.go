@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-faster/jx"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/oas"
+)
+
+func TestCloudEvent(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("b7ad6b7169203331")
+	require.NoError(t, err)
+	cfg := CloudEventsConfig{
+		Source:     "https://example.com/pet",
+		DataSchema: "https://example.com/openapi.yml#/components/schemas/Pet",
+	}
+	ev := Event{
+		ID:       1,
+		OutboxID: 42,
+		Type:     EventStatusChanged,
+		PetID:    10,
+		Pet:      oas.Pet{ID: oas.NewOptInt64(10), Name: "Tom", Status: oas.NewOptPetStatus(oas.PetStatusSold)},
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Trace: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	ce := NewCloudEvent(ev, cfg)
+
+	t.Run("Structured", func(t *testing.T) {
+		e := jx.GetEncoder()
+		defer jx.PutEncoder(e)
+		ce.Encode(e)
+		require.JSONEq(t, `{
+			"specversion": "1.0",
+			"id": "42",
+			"source": "https://example.com/pet",
+			"type": "com.example.pet.status_changed",
+			"subject": "10",
+			"time": "2024-01-02T03:04:05Z",
+			"datacontenttype": "application/json",
+			"dataschema": "https://example.com/openapi.yml#/components/schemas/Pet",
+			"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			"data": {"id": 10, "name": "Tom", "status": "sold"}
+		}`, e.String())
+
+		got, err := ReadCloudEvent(http.Header{"Content-Type": {"application/cloudevents+json; charset=utf-8"}}, e.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, ce, got)
+	})
+	t.Run("Binary", func(t *testing.T) {
+		h := http.Header{}
+		body := ce.WriteHTTP(h)
+		require.JSONEq(t, `{"id":10,"name":"Tom","status":"sold"}`, string(body))
+		require.Equal(t, "application/json", h.Get("Content-Type"))
+		require.Equal(t, "42", h.Get("ce-id"))
+		require.Equal(t, "10", h.Get("ce-subject"))
+		require.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", h.Get("ce-traceparent"))
+
+		got, err := ReadCloudEvent(h, body)
+		require.NoError(t, err)
+		require.Equal(t, ce, got)
+	})
+	t.Run("Deleted", func(t *testing.T) {
+		ce := NewCloudEvent(Event{ID: 3, Type: EventDeleted, PetID: 10, Time: ev.Time}, cfg)
+		e := jx.GetEncoder()
+		defer jx.PutEncoder(e)
+		ce.Encode(e)
+		require.JSONEq(t, `{
+			"specversion": "1.0",
+			"id": "3",
+			"source": "https://example.com/pet",
+			"type": "com.example.pet.deleted",
+			"subject": "10",
+			"time": "2024-01-02T03:04:05Z"
+		}`, e.String())
+
+		h := http.Header{}
+		require.Empty(t, ce.WriteHTTP(h))
+		require.Empty(t, h.Get("Content-Type"))
+		got, err := ReadCloudEvent(h, nil)
+		require.NoError(t, err)
+		require.Equal(t, ce, got)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		structured := http.Header{"Content-Type": {CloudEventsContentType}}
+		for _, tt := range []struct {
+			header http.Header
+			body   string
+			err    string
+		}{
+			{http.Header{"Content-Type": {"application/json"}}, `{}`, "not a CloudEvents message"},
+			{structured, `{"id":"1","source":"/pet","type":"created"}`, "specversion is required"},
+			{structured, `{"specversion":"0.3","id":"1","source":"/pet","type":"created"}`, "unsupported specversion"},
+			{structured, `{"specversion":"1.0","source":"/pet","type":"created"}`, "id is required"},
+			{structured, `{"specversion":"1.0","id":"1","source":"/pet","type":"created","time":"now"}`, "time"},
+			{http.Header{"Ce-Specversion": {"1.0"}, "Ce-Id": {"1"}, "Ce-Type": {"created"}}, ``, "source is required"},
+		} {
+			_, err := ReadCloudEvent(tt.header, []byte(tt.body))
+			require.ErrorContains(t, err, tt.err, tt.body)
+		}
+	})
+}
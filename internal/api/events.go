@@ -0,0 +1,255 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/oas"
+)
+
+// EventType is a type of pet change event.
+type EventType string
+
+// Pet change event types.
+const (
+	EventCreated       EventType = "created"
+	EventUpdated       EventType = "updated"
+	EventDeleted       EventType = "deleted"
+	EventStatusChanged EventType = "status_changed"
+)
+
+// Event is a pet change event.
+type Event struct {
+	// ID is a sequence number of event, starting from 1.
+	ID    uint64
+	Type  EventType
+	PetID int64
+	// Pet is a pet after change, zero for EventDeleted.
+	Pet  oas.Pet
+	Time time.Time
+	// OutboxID is an ID of outbox record of event, if any. Unlike ID, it
+	// is kept across restarts and redeliveries.
+	OutboxID uint64
+	// Trace is a context of span that published event.
+	Trace trace.SpanContext
+}
+
+// Encode encodes event as JSON.
+func (e Event) Encode(enc *jx.Encoder) {
+	enc.Obj(func(enc *jx.Encoder) {
+		enc.Field("id", func(enc *jx.Encoder) { enc.UInt64(e.ID) })
+		enc.Field("type", func(enc *jx.Encoder) { enc.Str(string(e.Type)) })
+		enc.Field("pet_id", func(enc *jx.Encoder) { enc.Int64(e.PetID) })
+		if e.Type != EventDeleted {
+			enc.Field("pet", e.Pet.Encode)
+		}
+		enc.Field("time", func(enc *jx.Encoder) { enc.Str(e.Time.UTC().Format(time.RFC3339Nano)) })
+	})
+}
+
+// Decode decodes event from JSON.
+func (e *Event) Decode(d *jx.Decoder) error {
+	return d.ObjBytes(func(d *jx.Decoder, key []byte) error {
+		switch string(key) {
+		case "id":
+			v, err := d.UInt64()
+			e.ID = v
+			return err
+		case "type":
+			v, err := d.Str()
+			e.Type = EventType(v)
+			return err
+		case "pet_id":
+			v, err := d.Int64()
+			e.PetID = v
+			return err
+		case "pet":
+			return e.Pet.Decode(d)
+		case "time":
+			v, err := d.Str()
+			if err != nil {
+				return err
+			}
+			e.Time, err = time.Parse(time.RFC3339Nano, v)
+			return err
+		default:
+			return d.Skip()
+		}
+	})
+}
+
+var (
+	// ErrSubscriberLagged is returned by Subscription.Err if subscriber did
+	// not keep up with events.
+	ErrSubscriberLagged = errors.New("subscriber lagged behind")
+	// ErrEventsClosed is returned by Subscription.Err if Events is closed.
+	ErrEventsClosed = errors.New("events closed")
+)
+
+// Events is an in-memory log of pet change events.
+//
+// Last events are kept in a ring buffer, so subscribers can resume from the
+// last received event after reconnect.
+type Events struct {
+	mux    sync.Mutex
+	ring   []Event
+	lastID uint64
+	subs   map[*Subscription]struct{}
+	closed bool
+	now    func() time.Time
+}
+
+// NewEvents creates new Events keeping size last events.
+func NewEvents(size int) *Events {
+	if size < 1 {
+		size = 1
+	}
+	return &Events{
+		ring: make([]Event, size),
+		subs: map[*Subscription]struct{}{},
+		now:  time.Now,
+	}
+}
+
+// Publish appends event and sends it to subscribers.
+//
+// Publish never blocks: subscriber with full queue is closed with
+// ErrSubscriberLagged and is expected to resume from its last event.
+func (e *Events) Publish(typ EventType, petID int64, pet oas.Pet) Event {
+	return e.publish(Event{
+		Type:  typ,
+		PetID: petID,
+		Pet:   pet,
+		Time:  e.now(),
+	})
+}
+
+// publish appends event, assigning its ID.
+func (e *Events) publish(ev Event) Event {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.lastID++
+	ev.ID = e.lastID
+	ev.Pet = clonePet(ev.Pet)
+	e.ring[e.index(ev.ID)] = ev
+	for sub := range e.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			e.remove(sub, ErrSubscriberLagged)
+		}
+	}
+	return ev
+}
+
+// LastID returns ID of last published event.
+func (e *Events) LastID() uint64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.lastID
+}
+
+// Subscribe subscribes to events published after event lastID, zero means
+// only new events. Up to buffer events are queued for subscriber.
+//
+// Buffered events after lastID are returned in Subscription.Replay.
+func (e *Events) Subscribe(lastID uint64, buffer int) *Subscription {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	sub := &Subscription{
+		events: e,
+		ch:     make(chan Event, max(buffer, 1)),
+	}
+	if e.closed {
+		sub.err = ErrEventsClosed
+		close(sub.ch)
+		return sub
+	}
+	e.subs[sub] = struct{}{}
+	if lastID == 0 {
+		return sub
+	}
+
+	oldest := uint64(1)
+	if size := uint64(len(e.ring)); e.lastID > size {
+		oldest = e.lastID - size + 1
+	}
+	from := lastID + 1
+	switch {
+	case lastID > e.lastID:
+		// ID from before restart.
+		sub.Missed = true
+		from = oldest
+	case from < oldest:
+		sub.Missed = true
+		from = oldest
+	}
+	for id := from; id <= e.lastID; id++ {
+		sub.Replay = append(sub.Replay, e.ring[e.index(id)])
+	}
+	return sub
+}
+
+// Close closes all subscriptions with ErrEventsClosed.
+func (e *Events) Close() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.closed = true
+	for sub := range e.subs {
+		e.remove(sub, ErrEventsClosed)
+	}
+}
+
+func (e *Events) index(id uint64) int {
+	return int((id - 1) % uint64(len(e.ring)))
+}
+
+// remove removes subscription, must be called with lock held.
+func (e *Events) remove(sub *Subscription, err error) {
+	if _, ok := e.subs[sub]; !ok {
+		return
+	}
+	delete(e.subs, sub)
+	sub.err = err
+	close(sub.ch)
+}
+
+// Subscription is a subscription to pet change events.
+type Subscription struct {
+	events *Events
+	ch     chan Event
+	err    error
+
+	// Replay are buffered events after requested event.
+	Replay []Event
+	// Missed is true if some events after requested event are no longer
+	// buffered, so subscriber should reload state.
+	Missed bool
+}
+
+// C returns channel of events, closed when subscription is closed.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Err returns reason of subscription close after C is closed, nil if it
+// was closed by subscriber.
+func (s *Subscription) Err() error {
+	s.events.mux.Lock()
+	defer s.events.mux.Unlock()
+	return s.err
+}
+
+// Close unsubscribes from events.
+func (s *Subscription) Close() {
+	s.events.mux.Lock()
+	defer s.events.mux.Unlock()
+	s.events.remove(s, nil)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-faster/jx"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+func eventTypes(events []Event) (r []EventType) {
+	for _, ev := range events {
+		r = append(r, ev.Type)
+	}
+	return r
+}
+
+func eventIDs(events []Event) (r []uint64) {
+	for _, ev := range events {
+		r = append(r, ev.ID)
+	}
+	return r
+}
+
+func TestEvents(t *testing.T) {
+	events := NewEvents(3)
+	events.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	live := events.Subscribe(0, 1)
+	ev := events.Publish(EventCreated, 1, oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"})
+	require.Equal(t, ev, <-live.C())
+
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	ev.Encode(e)
+	require.JSONEq(t, `{"id":1,"type":"created","pet_id":1,"pet":{"id":1,"name":"Tom"},"time":"2024-01-02T03:04:05Z"}`, e.String())
+
+	events.Publish(EventUpdated, 1, oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"})
+	events.Publish(EventDeleted, 1, oas.Pet{})
+
+	// Resume after first event.
+	sub := events.Subscribe(1, 1)
+	require.False(t, sub.Missed)
+	require.Equal(t, []EventType{EventUpdated, EventDeleted}, eventTypes(sub.Replay))
+	sub.Close()
+	_, ok := <-sub.C()
+	require.False(t, ok)
+	require.NoError(t, sub.Err())
+
+	// Up to date.
+	sub = events.Subscribe(3, 1)
+	require.False(t, sub.Missed)
+	require.Empty(t, sub.Replay)
+	sub.Close()
+
+	// First event is evicted, but it was received.
+	events.Publish(EventCreated, 2, oas.Pet{ID: oas.NewOptInt64(2), Name: "Jerry"})
+	sub = events.Subscribe(1, 1)
+	require.False(t, sub.Missed)
+	require.Equal(t, []uint64{2, 3, 4}, eventIDs(sub.Replay))
+	sub.Close()
+
+	// Second event is evicted.
+	events.Publish(EventUpdated, 2, oas.Pet{ID: oas.NewOptInt64(2), Name: "Jerry"})
+	sub = events.Subscribe(1, 1)
+	require.True(t, sub.Missed)
+	require.Equal(t, []uint64{3, 4, 5}, eventIDs(sub.Replay))
+	sub.Close()
+
+	// ID from before restart.
+	sub = events.Subscribe(100, 1)
+	require.True(t, sub.Missed)
+	require.Len(t, sub.Replay, 3)
+	sub.Close()
+
+	// Live subscriber with buffer of 1 did not read queued events.
+	_, ok = <-live.C()
+	require.True(t, ok)
+	_, ok = <-live.C()
+	require.False(t, ok)
+	require.ErrorIs(t, live.Err(), ErrSubscriberLagged)
+
+	sub = events.Subscribe(0, 1)
+	events.Close()
+	_, ok = <-sub.C()
+	require.False(t, ok)
+	require.ErrorIs(t, sub.Err(), ErrEventsClosed)
+	// Subscription after close is closed.
+	sub = events.Subscribe(0, 1)
+	_, ok = <-sub.C()
+	require.False(t, ok)
+	require.ErrorIs(t, sub.Err(), ErrEventsClosed)
+}
+
+func TestEventFilter(t *testing.T) {
+	sold := Event{
+		Type:  EventStatusChanged,
+		PetID: 1,
+		Pet:   oas.Pet{ID: oas.NewOptInt64(1), Status: oas.NewOptPetStatus(oas.PetStatusSold)},
+	}
+	deleted := Event{Type: EventDeleted, PetID: 1}
+	for _, tt := range []struct {
+		Filter  EventFilter
+		Sold    bool
+		Deleted bool
+	}{
+		{EventFilter{}, true, true},
+		{EventFilter{PetIDs: []int64{1}}, true, true},
+		{EventFilter{PetIDs: []int64{2}}, false, false},
+		{EventFilter{Statuses: []oas.PetStatus{oas.PetStatusSold}}, true, false},
+		{EventFilter{Statuses: []oas.PetStatus{oas.PetStatusPending}}, false, false},
+		{EventFilter{Events: []EventType{EventDeleted}}, false, true},
+	} {
+		require.Equal(t, tt.Sold, tt.Filter.Match(sold), "%+v", tt.Filter)
+		require.Equal(t, tt.Deleted, tt.Filter.Match(deleted), "%+v", tt.Filter)
+	}
+
+	require.NoError(t, EventFilter{Statuses: []oas.PetStatus{oas.PetStatusSold}, Events: []EventType{EventCreated}}.Validate())
+	require.EqualError(t, EventFilter{Statuses: []oas.PetStatus{"lost"}}.Validate(), "statuses: invalid value: lost")
+	require.EqualError(t, EventFilter{Events: []EventType{"moved"}}.Validate(), `events: unknown event type "moved"`)
+}
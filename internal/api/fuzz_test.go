@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-faster/jx"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+func newFuzzServer(t *testing.T) *oas.Server {
+	srv, err := oas.NewServer(NewHandler(NewMemoryStorage()),
+		oas.WithErrorHandler(ErrorHandler),
+	)
+	require.NoError(t, err)
+	return srv
+}
+
+// FuzzServer feeds arbitrary requests through router, parameter and body decoders.
+//
+// Seed corpus is in testdata/fuzz/FuzzServer.
+func FuzzServer(f *testing.F) {
+	f.Fuzz(func(t *testing.T, method, rawPath, rawQuery string, body []byte) {
+		path, err := url.PathUnescape(rawPath)
+		if err != nil {
+			t.Skip()
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", bytes.NewReader(body))
+		req.Method = method
+		req.URL = &url.URL{Path: path, RawPath: rawPath, RawQuery: rawQuery}
+		req.Header.Set("Content-Type", "application/json")
+
+		rw := httptest.NewRecorder()
+		newFuzzServer(t).ServeHTTP(rw, req)
+		require.Less(t, rw.Code, http.StatusInternalServerError, "%s %s?%s: %s", method, rawPath, rawQuery, rw.Body)
+	})
+}
+
+// FuzzUpdatePetParams feeds arbitrary parameters of updatePet.
+func FuzzUpdatePetParams(f *testing.F) {
+	f.Add("10", "doggie", "available")
+	f.Add("1", "", "sold")
+	f.Add("-1", "x", "unknown")
+	f.Add("9223372036854775808", "x", "")
+	f.Fuzz(func(t *testing.T, petID, name, status string) {
+		srv := newFuzzServer(t)
+		add := httptest.NewRequest(http.MethodPost, "/pet", bytes.NewReader([]byte(`{"id":10,"name":"doggie"}`)))
+		add.Header.Set("Content-Type", "application/json")
+		srv.ServeHTTP(httptest.NewRecorder(), add)
+
+		q := url.Values{"name": {name}, "status": {status}}
+		req := httptest.NewRequest(http.MethodPost, "/pet/", nil)
+		req.URL.Path += petID
+		req.URL.RawPath = "/pet/" + url.PathEscape(petID)
+		req.URL.RawQuery = q.Encode()
+
+		rw := httptest.NewRecorder()
+		srv.ServeHTTP(rw, req)
+		require.Less(t, rw.Code, http.StatusInternalServerError, "%s: %s", req.URL, rw.Body)
+		if rw.Code != http.StatusOK {
+			return
+		}
+		// Only valid status is accepted.
+		require.NoError(t, oas.PetStatus(status).Validate())
+	})
+}
+
+// FuzzPetDecode checks that any Pet accepted by decoder round-trips through encoder.
+//
+// Seed corpus is in testdata/fuzz/FuzzPetDecode.
+func FuzzPetDecode(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var pet oas.Pet
+		if err := pet.Decode(jx.DecodeBytes(data)); err != nil {
+			return
+		}
+
+		e := jx.GetEncoder()
+		defer jx.PutEncoder(e)
+		pet.Encode(e)
+		encoded := e.Bytes()
+
+		var decoded oas.Pet
+		require.NoError(t, decoded.Decode(jx.DecodeBytes(encoded)), "%s", encoded)
+		require.Equal(t, pet, decoded)
+	})
+}
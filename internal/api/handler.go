@@ -2,9 +2,12 @@ package api
 
 import (
 	"context"
-	"fmt"
+	"net/http"
 
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
 	"github.com/go-faster/sdk/zctx"
+	"github.com/ogen-go/ogen/ogenerrors"
 	"go.uber.org/zap"
 
 	"example/internal/oas"
@@ -15,13 +18,130 @@ var _ oas.Handler = (*Handler)(nil)
 
 type Handler struct {
 	oas.UnimplementedHandler // automatically implement all methods
+
+	storage       Storage
+	relay         *Relay
+	webhookPolicy WebhookPolicy
+}
+
+// Option configures Handler.
+type Option func(h *Handler)
+
+// WithRelay sets outbox relay notified after pet changes, so their events
+// are published without waiting for relay interval.
+func WithRelay(relay *Relay) Option {
+	return func(h *Handler) {
+		h.relay = relay
+	}
+}
+
+// WithWebhookPolicy sets policy of webhook addresses checked on
+// registration.
+func WithWebhookPolicy(p WebhookPolicy) Option {
+	return func(h *Handler) {
+		h.webhookPolicy = p
+	}
+}
+
+// NewHandler creates new Handler using given storage.
+func NewHandler(storage Storage, opts ...Option) *Handler {
+	h := &Handler{storage: storage}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// notify notifies relay about committed outbox records.
+func (h *Handler) notify() {
+	if h.relay != nil {
+		h.relay.Notify()
+	}
 }
 
-func (h Handler) GetPetById(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error) {
+// AddPet adds pet to storage.
+//
+// Repeated requests with the same idempotency key are handled by
+// httpmiddleware.IdempotencyCache before reaching handler.
+func (h *Handler) AddPet(ctx context.Context, req *oas.Pet, _ oas.AddPetParams) (oas.AddPetRes, error) {
+	zctx.From(ctx).Info("AddPet", zap.Any("pet", req))
+	pet, err := h.storage.AddPet(ctx, *req)
+	switch {
+	case errors.Is(err, ErrInvalidID):
+		return &oas.AddPetBadRequest{}, nil
+	case errors.Is(err, ErrConflict):
+		return &oas.AddPetConflict{}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "add pet")
+	}
+	h.notify()
+	return &pet, nil
+}
+
+func (h *Handler) GetPetById(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error) {
 	zctx.From(ctx).Info("GetPetById", zap.Any("params", params))
-	return &oas.Pet{
-		ID:     oas.NewOptInt64(params.PetId),
-		Name:   fmt.Sprintf("Pet %d", params.PetId),
-		Status: oas.NewOptPetStatus(oas.PetStatusAvailable),
-	}, nil
+	pet, err := h.storage.GetPet(ctx, params.PetId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &oas.GetPetByIdNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "get pet")
+	}
+	return &pet, nil
+}
+
+func (h *Handler) UpdatePet(ctx context.Context, params oas.UpdatePetParams) (oas.UpdatePetRes, error) {
+	zctx.From(ctx).Info("UpdatePet", zap.Any("params", params))
+	_, err := h.storage.UpdatePet(ctx, params.PetId, func(pet *oas.Pet) error {
+		if v, ok := params.Name.Get(); ok {
+			pet.Name = v
+		}
+		if v, ok := params.Status.Get(); ok {
+			pet.Status.SetTo(v)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &oas.UpdatePetNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "update pet")
+	}
+	h.notify()
+	return &oas.UpdatePetOK{}, nil
+}
+
+func (h *Handler) DeletePet(ctx context.Context, params oas.DeletePetParams) (oas.DeletePetRes, error) {
+	zctx.From(ctx).Info("DeletePet", zap.Any("params", params))
+	if err := h.storage.DeletePet(ctx, params.PetId); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &oas.DeletePetNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "delete pet")
+	}
+	h.notify()
+	return &oas.DeletePetOK{}, nil
+}
+
+// ErrorHandler is ogen error handler that maps ErrInvalidWebhook to 400.
+func ErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	var code int
+	switch {
+	case errors.Is(err, ErrInvalidWebhook):
+		code = http.StatusBadRequest
+	default:
+		ogenerrors.DefaultErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	e.ObjStart()
+	e.FieldStart("error_message")
+	e.StrEscape(err.Error())
+	e.ObjEnd()
+
+	_, _ = w.Write(e.Bytes())
 }
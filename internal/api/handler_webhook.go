@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/sdk/zctx"
+	"go.uber.org/zap"
+
+	"example/internal/oas"
+)
+
+// ErrInvalidWebhook is returned by Handler when webhook request is invalid.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+func webhookToOAS(wh Webhook) (*oas.Webhook, error) {
+	u, err := url.Parse(wh.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse url")
+	}
+	r := &oas.Webhook{
+		ID:          wh.ID,
+		URL:         *u,
+		Statuses:    wh.Filter.Statuses,
+		Enabled:     wh.Enabled,
+		Failures:    wh.Failures,
+		ContentMode: wh.ContentMode,
+		CreatedAt:   wh.CreatedAt,
+	}
+	if r.ContentMode == "" {
+		r.ContentMode = oas.WebhookContentModeStructured
+	}
+	for _, typ := range wh.Filter.Events {
+		r.Events = append(r.Events, oas.WebhookEventType(typ))
+	}
+	if wh.DisabledReason != "" {
+		r.DisabledReason.SetTo(wh.DisabledReason)
+	}
+	return r, nil
+}
+
+func deliveryToOAS(d Delivery) oas.WebhookDelivery {
+	r := oas.WebhookDelivery{
+		ID:        d.ID,
+		EventID:   int64(d.EventID),
+		EventType: oas.WebhookEventType(d.EventType),
+		Status:    d.Status,
+		Attempts:  make([]oas.WebhookAttempt, 0, len(d.Attempts)),
+		CreatedAt: d.CreatedAt,
+	}
+	if d.Status == oas.WebhookDeliveryStatusPending {
+		r.NextAttemptAt.SetTo(d.NextAttempt)
+	}
+	for _, a := range d.Attempts {
+		attempt := oas.WebhookAttempt{
+			StartedAt:  a.Start,
+			DurationMs: a.Duration.Milliseconds(),
+		}
+		if a.StatusCode != 0 {
+			attempt.StatusCode.SetTo(a.StatusCode)
+		}
+		if a.Error != "" {
+			attempt.Error.SetTo(a.Error)
+		}
+		r.Attempts = append(r.Attempts, attempt)
+	}
+	return r
+}
+
+// CreateWebhook registers webhook.
+func (h *Handler) CreateWebhook(ctx context.Context, req *oas.WebhookRequest, _ oas.CreateWebhookParams) (*oas.Webhook, error) {
+	zctx.From(ctx).Info("CreateWebhook", zap.Stringer("url", &req.URL))
+	if s := req.URL.Scheme; (s != "http" && s != "https") || req.URL.Host == "" {
+		return nil, errors.Wrap(ErrInvalidWebhook, "url must be absolute http or https URL")
+	}
+	if err := h.webhookPolicy.CheckHost(ctx, req.URL.Hostname()); err != nil {
+		return nil, errors.Wrapf(ErrInvalidWebhook, "url: %s", err)
+	}
+	wh := Webhook{
+		URL:         req.URL.String(),
+		Filter:      EventFilter{Statuses: req.Statuses},
+		Secret:      req.Secret,
+		Enabled:     true,
+		ContentMode: req.ContentMode.Or(oas.WebhookContentModeStructured),
+		CreatedAt:   time.Now().UTC(),
+	}
+	for _, typ := range req.Events {
+		wh.Filter.Events = append(wh.Filter.Events, EventType(typ))
+	}
+	wh, err := h.storage.AddWebhook(ctx, wh)
+	if err != nil {
+		return nil, errors.Wrap(err, "add webhook")
+	}
+	return webhookToOAS(wh)
+}
+
+// ListWebhooks returns registered webhooks.
+func (h *Handler) ListWebhooks(ctx context.Context) ([]oas.Webhook, error) {
+	webhooks, err := h.storage.ListWebhooks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "list webhooks")
+	}
+	r := make([]oas.Webhook, 0, len(webhooks))
+	for _, wh := range webhooks {
+		v, err := webhookToOAS(wh)
+		if err != nil {
+			return nil, errors.Wrapf(err, "webhook %d", wh.ID)
+		}
+		r = append(r, *v)
+	}
+	return r, nil
+}
+
+func (h *Handler) GetWebhook(ctx context.Context, params oas.GetWebhookParams) (oas.GetWebhookRes, error) {
+	wh, err := h.storage.GetWebhook(ctx, params.WebhookId)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return &oas.GetWebhookNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "get webhook")
+	}
+	return webhookToOAS(wh)
+}
+
+func (h *Handler) DeleteWebhook(ctx context.Context, params oas.DeleteWebhookParams) (oas.DeleteWebhookRes, error) {
+	zctx.From(ctx).Info("DeleteWebhook", zap.Any("params", params))
+	if err := h.storage.DeleteWebhook(ctx, params.WebhookId); err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return &oas.DeleteWebhookNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "delete webhook")
+	}
+	return &oas.DeleteWebhookOK{}, nil
+}
+
+// EnableWebhook enables webhook, resetting its failures.
+func (h *Handler) EnableWebhook(ctx context.Context, params oas.EnableWebhookParams) (oas.EnableWebhookRes, error) {
+	zctx.From(ctx).Info("EnableWebhook", zap.Any("params", params))
+	wh, err := h.storage.UpdateWebhook(ctx, params.WebhookId, func(wh *Webhook) error {
+		wh.Enabled = true
+		wh.Failures = 0
+		wh.DisabledReason = ""
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return &oas.EnableWebhookNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "enable webhook")
+	}
+	return webhookToOAS(wh)
+}
+
+func (h *Handler) ListWebhookDeliveries(ctx context.Context, params oas.ListWebhookDeliveriesParams) (oas.ListWebhookDeliveriesRes, error) {
+	if _, err := h.storage.GetWebhook(ctx, params.WebhookId); err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return &oas.ListWebhookDeliveriesNotFound{}, nil
+		}
+		return nil, errors.Wrap(err, "get webhook")
+	}
+	deliveries, err := h.storage.ListDeliveries(ctx, params.WebhookId, params.Before.Or(0), params.Limit.Or(100))
+	if err != nil {
+		return nil, errors.Wrap(err, "list deliveries")
+	}
+	r := make(oas.ListWebhookDeliveriesOKApplicationJSON, 0, len(deliveries))
+	for _, d := range deliveries {
+		r = append(r, deliveryToOAS(d))
+	}
+	return &r, nil
+}
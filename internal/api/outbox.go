@@ -0,0 +1,392 @@
+package api
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+// OutboxRecord is a pet change event committed with the change.
+type OutboxRecord struct {
+	// Event is a committed event, Event.ID is a sequence number of record.
+	Event Event
+	// Trace is a context of request that made the change.
+	Trace propagation.MapCarrier
+}
+
+// OutboxStorage is a transactional outbox of pet change events.
+//
+// Pet mutations of Storage append their events to outbox atomically with
+// the change, so events of committed changes are never lost and events of
+// failed ones are never published.
+type OutboxStorage interface {
+	// PendingOutbox returns up to limit unpublished records with ID greater
+	// than afterID in commit order.
+	PendingOutbox(ctx context.Context, afterID uint64, limit int) ([]OutboxRecord, error)
+	// AckOutbox removes published records.
+	AckOutbox(ctx context.Context, ids ...uint64) error
+}
+
+// changeRecords returns outbox records of pet change without IDs: old is
+// nil for added pet and pet is nil for deleted one.
+func changeRecords(ctx context.Context, id int64, old, pet *oas.Pet) []OutboxRecord {
+	// Storage has no configured propagator, API uses W3C Trace Context.
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	now := time.Now().UTC()
+	record := func(typ EventType, pet oas.Pet) OutboxRecord {
+		return OutboxRecord{
+			Event: Event{Type: typ, PetID: id, Pet: clonePet(pet), Time: now},
+			Trace: carrier,
+		}
+	}
+	switch {
+	case old == nil:
+		return []OutboxRecord{record(EventCreated, *pet)}
+	case pet == nil:
+		return []OutboxRecord{record(EventDeleted, oas.Pet{})}
+	case old.Status != pet.Status:
+		return []OutboxRecord{record(EventUpdated, *pet), record(EventStatusChanged, *pet)}
+	default:
+		return []OutboxRecord{record(EventUpdated, *pet)}
+	}
+}
+
+// EventPublisher publishes committed pet change events.
+//
+// Delivery is at-least-once: event is published again if Relay did not
+// acknowledge it, e.g. on failure of another publisher or crash, so
+// consumers should deduplicate by event ID.
+type EventPublisher interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+// MultiPublisher publishes event to every publisher.
+//
+// If some publishers fail, retry of the same outbox record is published
+// only to them, so e.g. event streams do not get event twice because
+// webhooks failed.
+type MultiPublisher struct {
+	pubs []EventPublisher
+
+	mux sync.Mutex
+	// done are publishers succeeded by outbox ID of partially published
+	// records.
+	done map[uint64][]bool
+}
+
+// NewMultiPublisher creates new MultiPublisher.
+func NewMultiPublisher(pubs ...EventPublisher) *MultiPublisher {
+	return &MultiPublisher{
+		pubs: pubs,
+		done: map[uint64][]bool{},
+	}
+}
+
+// Publish implements EventPublisher.
+func (m *MultiPublisher) Publish(ctx context.Context, ev Event) error {
+	m.mux.Lock()
+	done, ok := m.done[ev.OutboxID]
+	m.mux.Unlock()
+	if !ok {
+		done = make([]bool, len(m.pubs))
+	}
+
+	var errs []error
+	for i, p := range m.pubs {
+		if done[i] {
+			continue
+		}
+		if err := p.Publish(ctx, ev); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		done[i] = true
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	switch {
+	case len(errs) == 0:
+		delete(m.done, ev.OutboxID)
+	case ev.OutboxID != 0:
+		// Event without outbox record is not retried by relay.
+		m.done[ev.OutboxID] = done
+	}
+	return errors.Join(errs...)
+}
+
+type memoryPublisher struct {
+	events *Events
+}
+
+// NewMemoryPublisher returns EventPublisher appending events to in-memory
+// log of event streams.
+//
+// Events are numbered by log, outbox ID is kept in Event.OutboxID.
+func NewMemoryPublisher(events *Events) EventPublisher {
+	return memoryPublisher{events: events}
+}
+
+func (p memoryPublisher) Publish(ctx context.Context, ev Event) error {
+	if ev.OutboxID == 0 {
+		ev.OutboxID = ev.ID
+	}
+	p.events.publish(ev)
+	return nil
+}
+
+// FilePublisher is EventPublisher appending events to file as structured
+// CloudEvents JSON lines.
+type FilePublisher struct {
+	mux  sync.Mutex
+	file *os.File
+	ce   CloudEventsConfig
+}
+
+// OpenFilePublisher opens or creates file at path for appending events.
+func OpenFilePublisher(path string, ce CloudEventsConfig) (*FilePublisher, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open")
+	}
+	return &FilePublisher{file: f, ce: ce}, nil
+}
+
+// Publish implements EventPublisher.
+//
+// File is synced, so published event survives crash.
+func (p *FilePublisher) Publish(ctx context.Context, ev Event) error {
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	NewCloudEvent(ev, p.ce).Encode(e)
+	e.RawStr("\n")
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if _, err := p.file.Write(e.Bytes()); err != nil {
+		return errors.Wrap(err, "write")
+	}
+	return p.file.Sync()
+}
+
+// Close closes file.
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+// RelayConfig configures Relay.
+type RelayConfig struct {
+	// Interval is an interval of checking outbox, relay is also woken by
+	// Notify after commit.
+	//
+	// Defaults to 1s.
+	Interval time.Duration
+	// Batch is a number of records read from outbox at once.
+	//
+	// Defaults to 100.
+	Batch int
+}
+
+func (c *RelayConfig) setDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Batch <= 0 {
+		c.Batch = 100
+	}
+}
+
+// Relay publishes outbox records and removes published ones.
+//
+// Records are published in commit order. If publishing fails, the
+// following records of the same pet wait for retry, so events of every pet
+// are published in order, while other pets are not blocked.
+type Relay struct {
+	storage OutboxStorage
+	pub     EventPublisher
+	cfg     RelayConfig
+	tracer  trace.Tracer
+	wake    chan struct{}
+
+	// oldest is commit time of the oldest pending record in Unix
+	// nanoseconds, zero if outbox is empty.
+	oldest    atomic.Int64
+	lag       metric.Float64Histogram
+	published metric.Int64Counter
+}
+
+// NewRelay creates new Relay.
+func NewRelay(storage OutboxStorage, pub EventPublisher, m httpmiddleware.Metrics, cfg RelayConfig) (*Relay, error) {
+	cfg.setDefaults()
+	r := &Relay{
+		storage: storage,
+		pub:     pub,
+		cfg:     cfg,
+		tracer:  m.TracerProvider().Tracer("example/internal/api"),
+		wake:    make(chan struct{}, 1),
+	}
+	meter := m.MeterProvider().Meter("example/internal/api")
+	var err error
+	if r.lag, err = meter.Float64Histogram("outbox.lag",
+		metric.WithDescription("Time from commit of pet change to publishing of its event"),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, errors.Wrap(err, "create lag histogram")
+	}
+	if r.published, err = meter.Int64Counter("outbox.published",
+		metric.WithDescription("Number of outbox records publishing attempts by result"),
+	); err != nil {
+		return nil, errors.Wrap(err, "create published counter")
+	}
+	if _, err := meter.Float64ObservableGauge("outbox.oldest_age",
+		metric.WithDescription("Age of the oldest unpublished outbox record"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			var age float64
+			if oldest := r.oldest.Load(); oldest != 0 {
+				age = time.Since(time.Unix(0, oldest)).Seconds()
+			}
+			o.Observe(age)
+			return nil
+		}),
+	); err != nil {
+		return nil, errors.Wrap(err, "create oldest age gauge")
+	}
+	return r, nil
+}
+
+// Notify wakes relay to publish new records.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes outbox records until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.flush(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			zctx.From(ctx).Error("Outbox relay failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush publishes pending records until outbox is empty or only records
+// waiting for retry are left.
+func (r *Relay) flush(ctx context.Context) (rerr error) {
+	var (
+		after  uint64
+		failed = map[int64]struct{}{}
+		// retry is commit time of the first record left for retry.
+		retry time.Time
+	)
+	defer func() {
+		if rerr != nil {
+			// Outbox state is unknown.
+			return
+		}
+		var oldest int64
+		if !retry.IsZero() {
+			oldest = retry.UnixNano()
+		}
+		r.oldest.Store(oldest)
+	}()
+	for {
+		records, err := r.storage.PendingOutbox(ctx, after, r.cfg.Batch)
+		if err != nil {
+			return errors.Wrap(err, "pending outbox")
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		var acked []uint64
+		for _, rec := range records {
+			after = rec.Event.ID
+			if _, ok := failed[rec.Event.PetID]; ok {
+				// Keep order of pet events.
+				continue
+			}
+			if err := r.publish(ctx, rec); err != nil {
+				failed[rec.Event.PetID] = struct{}{}
+				if retry.IsZero() {
+					retry = rec.Event.Time
+				}
+				zctx.From(ctx).Warn("Publish outbox record",
+					zap.Uint64("id", rec.Event.ID),
+					zap.Int64("pet", rec.Event.PetID),
+					zap.Error(err),
+				)
+				continue
+			}
+			acked = append(acked, rec.Event.ID)
+		}
+		if len(acked) > 0 {
+			if err := r.storage.AckOutbox(ctx, acked...); err != nil {
+				return errors.Wrap(err, "ack outbox")
+			}
+		}
+		if len(records) < r.cfg.Batch {
+			// Failed records are retried on next tick.
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, rec OutboxRecord) error {
+	// Publishing continues trace of request that made the change.
+	parent := propagation.TraceContext{}.Extract(ctx, rec.Trace)
+	ctx, span := r.tracer.Start(parent, "outbox.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.Int64("outbox.record.id", int64(rec.Event.ID)),
+			attribute.String("event.type", string(rec.Event.Type)),
+		),
+	)
+	defer span.End()
+
+	// Consumers continue trace from publishing span.
+	ev := rec.Event
+	ev.OutboxID = ev.ID
+	ev.Trace = span.SpanContext()
+
+	result := "success"
+	err := r.pub.Publish(ctx, ev)
+	if err != nil {
+		result = "failure"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+	} else {
+		r.lag.Record(ctx, time.Since(rec.Event.Time).Seconds())
+	}
+	r.published.Add(ctx, 1, metric.WithAttributes(attribute.String("outbox.result", result)))
+	return err
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+func recordTypes(records []OutboxRecord) (r []EventType) {
+	for _, rec := range records {
+		r = append(r, rec.Event.Type)
+	}
+	return r
+}
+
+func recordIDs(records []OutboxRecord) (r []uint64) {
+	for _, rec := range records {
+		r = append(r, rec.Event.ID)
+	}
+	return r
+}
+
+func TestHandlerOutbox(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	h := NewHandler(storage)
+
+	provider := httpmiddleware.NewProvider()
+	ctx, span := provider.Tracer("test").Start(ctx, "request")
+	defer span.End()
+
+	added, err := h.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	// Explicit ID of existing pet does not replace it, so there is no
+	// second created event.
+	conflict, err := h.AddPet(ctx, &oas.Pet{ID: pet.ID, Name: "Jerry"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	require.IsType(t, &oas.AddPetConflict{}, conflict)
+	stored, err := storage.GetPet(ctx, pet.ID.Value)
+	require.NoError(t, err)
+	require.Equal(t, "Tom", stored.Name)
+	for _, params := range []oas.UpdatePetParams{
+		{PetId: pet.ID.Value, Name: oas.NewOptString("Tommy")},
+		{PetId: pet.ID.Value, Status: oas.NewOptPetStatus(oas.PetStatusSold)},
+		// Status is not changed.
+		{PetId: pet.ID.Value, Status: oas.NewOptPetStatus(oas.PetStatusSold)},
+	} {
+		res, err := h.UpdatePet(ctx, params)
+		require.NoError(t, err)
+		require.IsType(t, &oas.UpdatePetOK{}, res)
+	}
+	deleted, err := h.DeletePet(ctx, oas.DeletePetParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	require.IsType(t, &oas.DeletePetOK{}, deleted)
+	// Failed mutations are not recorded.
+	deleted, err = h.DeletePet(ctx, oas.DeletePetParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	require.IsType(t, &oas.DeletePetNotFound{}, deleted)
+	updated, err := h.UpdatePet(ctx, oas.UpdatePetParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	require.IsType(t, &oas.UpdatePetNotFound{}, updated)
+
+	records, err := storage.PendingOutbox(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []EventType{
+		EventCreated,
+		EventUpdated,
+		EventUpdated,
+		EventStatusChanged,
+		EventUpdated,
+		EventDeleted,
+	}, recordTypes(records))
+	for i, rec := range records {
+		require.Equal(t, uint64(i+1), rec.Event.ID)
+		require.Equal(t, pet.ID.Value, rec.Event.PetID)
+		require.NotEmpty(t, rec.Trace.Get("traceparent"))
+	}
+	require.Equal(t, "Tommy", records[1].Event.Pet.Name)
+	require.Equal(t, oas.NewOptPetStatus(oas.PetStatusSold), records[3].Event.Pet.Status)
+}
+
+// testPublisher records published events and fails for pets in fail.
+type testPublisher struct {
+	mux       sync.Mutex
+	fail      map[int64]bool
+	published []Event
+	traces    []trace.SpanContext
+}
+
+func (p *testPublisher) Publish(ctx context.Context, ev Event) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.fail[ev.PetID] {
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, ev)
+	p.traces = append(p.traces, trace.SpanContextFromContext(ctx))
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	provider := httpmiddleware.NewProvider()
+	reader := sdkmetric.NewManualReader()
+	pub := &testPublisher{fail: map[int64]bool{1: true}}
+	relay, err := NewRelay(storage, pub, testMetrics{
+		tp: provider,
+		mp: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	}, RelayConfig{Batch: 2})
+	require.NoError(t, err)
+
+	reqCtx, span := provider.Tracer("test").Start(ctx, "request")
+	tom, err := storage.AddPet(reqCtx, oas.Pet{Name: "Tom"})
+	require.NoError(t, err)
+	jerry, err := storage.AddPet(reqCtx, oas.Pet{Name: "Jerry"})
+	require.NoError(t, err)
+	for _, pet := range []oas.Pet{tom, jerry} {
+		_, err = storage.UpdatePet(reqCtx, pet.ID.Value, func(pet *oas.Pet) error {
+			pet.Status.SetTo(oas.PetStatusSold)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	span.End()
+
+	// Events of Tom wait for retry, Jerry is not blocked.
+	require.NoError(t, relay.flush(ctx))
+	require.Len(t, pub.published, 3)
+	for _, ev := range pub.published {
+		require.Equal(t, jerry.ID.Value, ev.PetID)
+	}
+	records, err := storage.PendingOutbox(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 3, 4}, recordIDs(records))
+
+	// Publishing continues trace of request, event carries publishing span.
+	for i, sc := range pub.traces {
+		require.Equal(t, span.SpanContext().TraceID(), sc.TraceID())
+		require.Equal(t, sc, pub.published[i].Trace)
+		require.Equal(t, []uint64{2, 5, 6}[i], pub.published[i].OutboxID)
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	gauges := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			gauges[m.Name] = true
+			if m.Name == "outbox.oldest_age" {
+				points := m.Data.(metricdata.Gauge[float64]).DataPoints
+				require.Len(t, points, 1)
+				require.Greater(t, points[0].Value, 0.0)
+			}
+		}
+	}
+	require.True(t, gauges["outbox.lag"])
+	require.True(t, gauges["outbox.published"])
+
+	pub.fail = nil
+	require.NoError(t, relay.flush(ctx))
+	var tomEvents []EventType
+	for _, ev := range pub.published[3:] {
+		require.Equal(t, tom.ID.Value, ev.PetID)
+		tomEvents = append(tomEvents, ev.Type)
+	}
+	require.Equal(t, []EventType{EventCreated, EventUpdated, EventStatusChanged}, tomEvents)
+	records, err = storage.PendingOutbox(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	// Run publishes on notify.
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx) }()
+	require.NoError(t, storage.DeletePet(ctx, tom.ID.Value))
+	relay.Notify()
+	require.Eventually(t, func() bool {
+		pub.mux.Lock()
+		defer pub.mux.Unlock()
+		return len(pub.published) == 7
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, EventDeleted, pub.published[6].Type)
+}
+
+func TestMultiPublisher(t *testing.T) {
+	ctx := context.Background()
+	events := NewEvents(10)
+	failing := &testPublisher{fail: map[int64]bool{1: true}}
+	pub := NewMultiPublisher(NewMemoryPublisher(events), failing)
+
+	ev := Event{ID: 3, OutboxID: 3, Type: EventCreated, PetID: 1}
+	require.Error(t, pub.Publish(ctx, ev))
+	require.Error(t, pub.Publish(ctx, ev))
+	failing.fail[1] = false
+	require.NoError(t, pub.Publish(ctx, ev))
+	// Retries are published only to failed publisher.
+	require.Equal(t, uint64(1), events.LastID())
+	require.Len(t, failing.published, 1)
+
+	// Record is published to every publisher again once succeeded.
+	require.NoError(t, pub.Publish(ctx, ev))
+	require.Equal(t, uint64(2), events.LastID())
+	require.Len(t, failing.published, 2)
+}
+
+func TestFilePublisher(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := OpenFilePublisher(path, CloudEventsConfig{Source: "https://example.com/pet"})
+	require.NoError(t, err)
+
+	events := NewEvents(1)
+	pub := NewMultiPublisher(p, NewMemoryPublisher(events))
+	sub := events.Subscribe(0, 2)
+	defer sub.Close()
+	published := []Event{
+		{ID: 7, Type: EventCreated, PetID: 1, Pet: oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"}, Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: 8, Type: EventDeleted, PetID: 1, Time: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)},
+	}
+	for _, ev := range published {
+		require.NoError(t, pub.Publish(ctx, ev))
+	}
+	require.NoError(t, p.Close())
+	// Log renumbers events, keeping outbox ID and commit time.
+	ev := <-sub.C()
+	require.Equal(t, uint64(1), ev.ID)
+	require.Equal(t, uint64(7), ev.OutboxID)
+	require.Equal(t, published[0].Time, ev.Time)
+	require.Equal(t, EventDeleted, (<-sub.C()).Type)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	var got []CloudEvent
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var ce CloudEvent
+		require.NoError(t, ce.Decode(jx.DecodeBytes(s.Bytes())))
+		got = append(got, ce)
+	}
+	require.NoError(t, s.Err())
+	require.Equal(t, []CloudEvent{
+		{
+			ID:              "7",
+			Source:          "https://example.com/pet",
+			Type:            "com.example.pet.created",
+			Subject:         "1",
+			Time:            published[0].Time,
+			DataContentType: "application/json",
+			Data:            []byte(`{"id":1,"name":"Tom"}`),
+		},
+		{
+			ID:      "8",
+			Source:  "https://example.com/pet",
+			Type:    "com.example.pet.deleted",
+			Subject: "1",
+			Time:    published[1].Time,
+		},
+	}, got)
+}
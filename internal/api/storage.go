@@ -0,0 +1,349 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+
+	"example/internal/oas"
+)
+
+var (
+	// ErrNotFound is returned by Storage when pet does not exist.
+	ErrNotFound = errors.New("pet not found")
+	// ErrInvalidID is returned by Storage.AddPet for explicit ID that is
+	// not positive.
+	ErrInvalidID = errors.New("pet id must be positive")
+	// ErrConflict is returned by Storage.AddPet for explicit ID of existing
+	// pet.
+	ErrConflict = errors.New("pet already exists")
+	// ErrWebhookNotFound is returned by WebhookStorage when webhook or
+	// delivery does not exist.
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// Storage stores pets and webhooks.
+//
+// Pet mutations append their events to outbox.
+type Storage interface {
+	WebhookStorage
+	OutboxStorage
+
+	// AddPet stores new pet, assigning ID if it is not set. Explicit ID
+	// must be positive and not used, otherwise ErrInvalidID or ErrConflict
+	// is returned.
+	AddPet(ctx context.Context, pet oas.Pet) (oas.Pet, error)
+	// GetPet returns pet by ID or ErrNotFound.
+	GetPet(ctx context.Context, id int64) (oas.Pet, error)
+	// UpdatePet calls fn on stored pet and saves the result.
+	UpdatePet(ctx context.Context, id int64, fn func(pet *oas.Pet) error) (oas.Pet, error)
+	// DeletePet deletes pet by ID or returns ErrNotFound.
+	DeletePet(ctx context.Context, id int64) error
+	// Close releases storage resources.
+	Close() error
+}
+
+// Compile-time check for MemoryStorage.
+var _ Storage = (*MemoryStorage)(nil)
+
+// MemoryStorage is in-memory Storage.
+type MemoryStorage struct {
+	mux    sync.Mutex
+	lastID int64
+	pets   map[int64]oas.Pet
+
+	lastWebhookID  int64
+	webhooks       map[int64]Webhook
+	lastDeliveryID int64
+	deliveries     map[int64]Delivery
+	// pending are IDs of pending deliveries.
+	pending map[int64]struct{}
+
+	lastOutboxID uint64
+	outbox       []OutboxRecord
+}
+
+// NewMemoryStorage creates new MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		pets:       map[int64]oas.Pet{},
+		webhooks:   map[int64]Webhook{},
+		deliveries: map[int64]Delivery{},
+		pending:    map[int64]struct{}{},
+	}
+}
+
+func clonePet(p oas.Pet) oas.Pet {
+	p.PhotoUrls = slices.Clone(p.PhotoUrls)
+	return p
+}
+
+// AddPet implements Storage.
+func (s *MemoryStorage) AddPet(ctx context.Context, pet oas.Pet) (oas.Pet, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	id, ok := pet.ID.Get()
+	switch {
+	case !ok:
+		s.lastID++
+		id = s.lastID
+		pet.ID.SetTo(id)
+	case id <= 0:
+		return oas.Pet{}, ErrInvalidID
+	default:
+		if _, ok := s.pets[id]; ok {
+			return oas.Pet{}, ErrConflict
+		}
+		s.lastID = max(s.lastID, id)
+	}
+	s.pets[id] = clonePet(pet)
+	s.appendOutbox(changeRecords(ctx, id, nil, &pet))
+	return pet, nil
+}
+
+// appendOutbox assigns IDs to records and appends them to outbox, must be
+// called with lock held.
+func (s *MemoryStorage) appendOutbox(records []OutboxRecord) {
+	for _, rec := range records {
+		s.lastOutboxID++
+		rec.Event.ID = s.lastOutboxID
+		s.outbox = append(s.outbox, rec)
+	}
+}
+
+// GetPet implements Storage.
+func (s *MemoryStorage) GetPet(ctx context.Context, id int64) (oas.Pet, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pet, ok := s.pets[id]
+	if !ok {
+		return oas.Pet{}, ErrNotFound
+	}
+	return clonePet(pet), nil
+}
+
+// UpdatePet implements Storage.
+func (s *MemoryStorage) UpdatePet(ctx context.Context, id int64, fn func(pet *oas.Pet) error) (oas.Pet, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pet, ok := s.pets[id]
+	if !ok {
+		return oas.Pet{}, ErrNotFound
+	}
+	old := pet
+	pet = clonePet(pet)
+	if err := fn(&pet); err != nil {
+		return oas.Pet{}, err
+	}
+	s.pets[id] = clonePet(pet)
+	s.appendOutbox(changeRecords(ctx, id, &old, &pet))
+	return pet, nil
+}
+
+// DeletePet implements Storage.
+func (s *MemoryStorage) DeletePet(ctx context.Context, id int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.pets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.pets, id)
+	s.appendOutbox(changeRecords(ctx, id, &oas.Pet{}, nil))
+	return nil
+}
+
+// PendingOutbox implements OutboxStorage.
+func (s *MemoryStorage) PendingOutbox(ctx context.Context, afterID uint64, limit int) ([]OutboxRecord, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	i, _ := slices.BinarySearchFunc(s.outbox, afterID+1, func(rec OutboxRecord, id uint64) int {
+		return cmp.Compare(rec.Event.ID, id)
+	})
+	r := s.outbox[i:]
+	return slices.Clone(r[:min(limit, len(r))]), nil
+}
+
+// AckOutbox implements OutboxStorage.
+func (s *MemoryStorage) AckOutbox(ctx context.Context, ids ...uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.outbox = slices.DeleteFunc(s.outbox, func(rec OutboxRecord) bool {
+		return slices.Contains(ids, rec.Event.ID)
+	})
+	return nil
+}
+
+// AddWebhook implements WebhookStorage.
+func (s *MemoryStorage) AddWebhook(ctx context.Context, wh Webhook) (Webhook, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.lastWebhookID++
+	wh.ID = s.lastWebhookID
+	s.webhooks[wh.ID] = wh.clone()
+	return wh, nil
+}
+
+// GetWebhook implements WebhookStorage.
+func (s *MemoryStorage) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	wh, ok := s.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return wh.clone(), nil
+}
+
+// ListWebhooks implements WebhookStorage.
+func (s *MemoryStorage) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := make([]Webhook, 0, len(s.webhooks))
+	for _, wh := range s.webhooks {
+		r = append(r, wh.clone())
+	}
+	slices.SortFunc(r, func(a, b Webhook) int { return cmp.Compare(a.ID, b.ID) })
+	return r, nil
+}
+
+// UpdateWebhook implements WebhookStorage.
+func (s *MemoryStorage) UpdateWebhook(ctx context.Context, id int64, fn func(wh *Webhook) error) (Webhook, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	wh, ok := s.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	wh = wh.clone()
+	if err := fn(&wh); err != nil {
+		return Webhook{}, err
+	}
+	s.webhooks[id] = wh.clone()
+	return wh, nil
+}
+
+// DeleteWebhook implements WebhookStorage.
+func (s *MemoryStorage) DeleteWebhook(ctx context.Context, id int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	maps.DeleteFunc(s.deliveries, func(_ int64, d Delivery) bool {
+		if d.WebhookID != id {
+			return false
+		}
+		delete(s.pending, d.ID)
+		return true
+	})
+	return nil
+}
+
+// putDelivery stores delivery and updates pending index, must be called
+// with lock held.
+func (s *MemoryStorage) putDelivery(d Delivery) {
+	s.deliveries[d.ID] = d.clone()
+	if d.Status == oas.WebhookDeliveryStatusPending {
+		s.pending[d.ID] = struct{}{}
+	} else {
+		delete(s.pending, d.ID)
+	}
+}
+
+// AddDelivery implements WebhookStorage.
+func (s *MemoryStorage) AddDelivery(ctx context.Context, d Delivery) (Delivery, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.webhooks[d.WebhookID]; !ok {
+		return Delivery{}, ErrWebhookNotFound
+	}
+	s.lastDeliveryID++
+	d.ID = s.lastDeliveryID
+	s.putDelivery(d)
+	return d, nil
+}
+
+// UpdateDelivery implements WebhookStorage.
+func (s *MemoryStorage) UpdateDelivery(ctx context.Context, id int64, fn func(d *Delivery) error) (Delivery, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrWebhookNotFound
+	}
+	d = d.clone()
+	if err := fn(&d); err != nil {
+		return Delivery{}, err
+	}
+	s.putDelivery(d)
+	return d, nil
+}
+
+// ListDeliveries implements WebhookStorage.
+func (s *MemoryStorage) ListDeliveries(ctx context.Context, webhookID, beforeID int64, limit int) ([]Delivery, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var r []Delivery
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && (beforeID == 0 || d.ID < beforeID) {
+			r = append(r, d)
+		}
+	}
+	slices.SortFunc(r, func(a, b Delivery) int { return cmp.Compare(b.ID, a.ID) })
+	r = r[:min(limit, len(r))]
+	for i, d := range r {
+		r[i] = d.clone()
+	}
+	return r, nil
+}
+
+// PendingDeliveries implements WebhookStorage.
+func (s *MemoryStorage) PendingDeliveries(ctx context.Context, limit int) ([]Delivery, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := make([]Delivery, 0, len(s.pending))
+	for id := range s.pending {
+		r = append(r, s.deliveries[id].clone())
+	}
+	sortPending(r)
+	if len(r) > limit {
+		r = r[:limit]
+	}
+	return r, nil
+}
+
+// PruneDeliveries implements WebhookStorage.
+func (s *MemoryStorage) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	n := len(s.deliveries)
+	maps.DeleteFunc(s.deliveries, func(_ int64, d Delivery) bool {
+		return d.Status != oas.WebhookDeliveryStatusPending && d.FinishedAt.Before(before)
+	})
+	return n - len(s.deliveries), nil
+}
+
+// Close implements Storage.
+func (s *MemoryStorage) Close() error { return nil }
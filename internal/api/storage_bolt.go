@@ -0,0 +1,532 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"go.etcd.io/bbolt"
+	"go.opentelemetry.io/otel/propagation"
+
+	"example/internal/oas"
+)
+
+var (
+	bucketPets       = []byte("pets")
+	bucketWebhooks   = []byte("webhooks")
+	bucketDeliveries = []byte("deliveries")
+	bucketOutbox     = []byte("outbox")
+
+	// Indexes of deliveries, values are empty.
+	bucketPendingDeliveries  = []byte("deliveries_pending")
+	bucketFinishedDeliveries = []byte("deliveries_finished")
+	bucketWebhookDeliveries  = []byte("webhook_deliveries")
+)
+
+// Compile-time check for BoltStorage.
+var _ Storage = (*BoltStorage)(nil)
+
+// BoltStorage is Storage backed by bbolt database.
+type BoltStorage struct {
+	db *bbolt.DB
+}
+
+// OpenBoltStorage opens or creates bbolt database at given path.
+func OpenBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "open")
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{
+			bucketPets, bucketWebhooks, bucketDeliveries, bucketOutbox,
+			bucketPendingDeliveries, bucketFinishedDeliveries, bucketWebhookDeliveries,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return errors.Wrapf(err, "%s", name)
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "create bucket")
+	}
+	return &BoltStorage{db: db}, nil
+}
+
+func petKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+func encodePet(pet oas.Pet) []byte {
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	pet.Encode(e)
+	return append([]byte(nil), e.Bytes()...)
+}
+
+func decodePet(data []byte) (pet oas.Pet, _ error) {
+	if err := pet.Decode(jx.DecodeBytes(data)); err != nil {
+		return pet, errors.Wrap(err, "decode pet")
+	}
+	return pet, nil
+}
+
+func encodeOutbox(rec OutboxRecord) []byte {
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	e.Obj(func(e *jx.Encoder) {
+		e.Field("event", rec.Event.Encode)
+		e.Field("trace", func(e *jx.Encoder) {
+			e.Obj(func(e *jx.Encoder) {
+				for k, v := range rec.Trace {
+					e.Field(k, func(e *jx.Encoder) { e.Str(v) })
+				}
+			})
+		})
+	})
+	return append([]byte(nil), e.Bytes()...)
+}
+
+func decodeOutbox(data []byte) (rec OutboxRecord, _ error) {
+	rec.Trace = propagation.MapCarrier{}
+	if err := jx.DecodeBytes(data).ObjBytes(func(d *jx.Decoder, key []byte) error {
+		switch string(key) {
+		case "event":
+			return rec.Event.Decode(d)
+		case "trace":
+			return d.ObjBytes(func(d *jx.Decoder, key []byte) error {
+				v, err := d.Str()
+				rec.Trace[string(key)] = v
+				return err
+			})
+		default:
+			return d.Skip()
+		}
+	}); err != nil {
+		return rec, errors.Wrap(err, "decode outbox record")
+	}
+	return rec, nil
+}
+
+// appendOutbox assigns IDs to records and stores them in outbox bucket.
+func appendOutbox(tx *bbolt.Tx, records []OutboxRecord) error {
+	b := tx.Bucket(bucketOutbox)
+	for _, rec := range records {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return errors.Wrap(err, "next outbox id")
+		}
+		rec.Event.ID = seq
+		if err := b.Put(petKey(int64(seq)), encodeOutbox(rec)); err != nil {
+			return errors.Wrap(err, "put outbox record")
+		}
+	}
+	return nil
+}
+
+// AddPet implements Storage.
+func (s *BoltStorage) AddPet(ctx context.Context, pet oas.Pet) (oas.Pet, error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketPets)
+		id, ok := pet.ID.Get()
+		switch {
+		case !ok:
+			seq, err := b.NextSequence()
+			if err != nil {
+				return errors.Wrap(err, "next id")
+			}
+			id = int64(seq)
+			pet.ID.SetTo(id)
+		case id <= 0:
+			return ErrInvalidID
+		case b.Get(petKey(id)) != nil:
+			return ErrConflict
+		case uint64(id) > b.Sequence():
+			if err := b.SetSequence(uint64(id)); err != nil {
+				return errors.Wrap(err, "set sequence")
+			}
+		}
+		if err := b.Put(petKey(id), encodePet(pet)); err != nil {
+			return err
+		}
+		return appendOutbox(tx, changeRecords(ctx, id, nil, &pet))
+	})
+	if err != nil {
+		return oas.Pet{}, err
+	}
+	return pet, nil
+}
+
+// GetPet implements Storage.
+func (s *BoltStorage) GetPet(ctx context.Context, id int64) (pet oas.Pet, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(bucketPets).Get(petKey(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		var err error
+		pet, err = decodePet(data)
+		return err
+	})
+	return pet, err
+}
+
+// UpdatePet implements Storage.
+func (s *BoltStorage) UpdatePet(ctx context.Context, id int64, fn func(pet *oas.Pet) error) (pet oas.Pet, _ error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketPets)
+		data := b.Get(petKey(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		old, err := decodePet(data)
+		if err != nil {
+			return err
+		}
+		if pet, err = decodePet(data); err != nil {
+			return err
+		}
+		if err := fn(&pet); err != nil {
+			return err
+		}
+		if err := b.Put(petKey(id), encodePet(pet)); err != nil {
+			return err
+		}
+		return appendOutbox(tx, changeRecords(ctx, id, &old, &pet))
+	})
+	if err != nil {
+		return oas.Pet{}, err
+	}
+	return pet, nil
+}
+
+// DeletePet implements Storage.
+func (s *BoltStorage) DeletePet(ctx context.Context, id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketPets)
+		key := petKey(id)
+		if b.Get(key) == nil {
+			return ErrNotFound
+		}
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return appendOutbox(tx, changeRecords(ctx, id, &oas.Pet{}, nil))
+	})
+}
+
+// getJSON decodes JSON value stored at key to v or returns notFound.
+func getJSON(b *bbolt.Bucket, key []byte, v any, notFound error) error {
+	data := b.Get(key)
+	if data == nil {
+		return notFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// putJSON stores v encoded as JSON at key.
+func putJSON(b *bbolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	return b.Put(key, data)
+}
+
+// AddWebhook implements WebhookStorage.
+func (s *BoltStorage) AddWebhook(ctx context.Context, wh Webhook) (Webhook, error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketWebhooks)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return errors.Wrap(err, "next id")
+		}
+		wh.ID = int64(seq)
+		return putJSON(b, petKey(wh.ID), wh)
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	return wh, nil
+}
+
+// GetWebhook implements WebhookStorage.
+func (s *BoltStorage) GetWebhook(ctx context.Context, id int64) (wh Webhook, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return getJSON(tx.Bucket(bucketWebhooks), petKey(id), &wh, ErrWebhookNotFound)
+	})
+	return wh, err
+}
+
+// ListWebhooks implements WebhookStorage.
+func (s *BoltStorage) ListWebhooks(ctx context.Context) (r []Webhook, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		// Keys are big-endian IDs, so cursor iterates in ID order.
+		return tx.Bucket(bucketWebhooks).ForEach(func(_, data []byte) error {
+			var wh Webhook
+			if err := json.Unmarshal(data, &wh); err != nil {
+				return errors.Wrap(err, "decode webhook")
+			}
+			r = append(r, wh)
+			return nil
+		})
+	})
+	return r, err
+}
+
+// UpdateWebhook implements WebhookStorage.
+func (s *BoltStorage) UpdateWebhook(ctx context.Context, id int64, fn func(wh *Webhook) error) (wh Webhook, _ error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketWebhooks)
+		if err := getJSON(b, petKey(id), &wh, ErrWebhookNotFound); err != nil {
+			return err
+		}
+		if err := fn(&wh); err != nil {
+			return err
+		}
+		return putJSON(b, petKey(id), wh)
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	return wh, nil
+}
+
+// DeleteWebhook implements WebhookStorage.
+func (s *BoltStorage) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketWebhooks)
+		key := petKey(id)
+		if b.Get(key) == nil {
+			return ErrWebhookNotFound
+		}
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		// Collect IDs first, cursor must not be used while deleting.
+		var ids []int64
+		c := tx.Bucket(bucketWebhookDeliveries).Cursor()
+		for k, _ := c.Seek(key); k != nil && bytes.HasPrefix(k, key); k, _ = c.Next() {
+			ids = append(ids, int64(binary.BigEndian.Uint64(k[8:])))
+		}
+		for _, deliveryID := range ids {
+			if err := deleteDelivery(tx, deliveryID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deliveryIndexKey returns key of delivery in index by time, big-endian
+// Unix nanoseconds and ID, so cursor iterates in time order.
+func deliveryIndexKey(t time.Time, id int64) []byte {
+	var ns int64
+	if !t.IsZero() {
+		ns = max(t.UnixNano(), 0)
+	}
+	return binary.BigEndian.AppendUint64(petKey(ns), uint64(id))
+}
+
+// deliveryIndex returns index bucket and key of delivery: pending ones are
+// indexed by time of next attempt and finished ones by time of finish.
+func deliveryIndex(tx *bbolt.Tx, d Delivery) (*bbolt.Bucket, []byte) {
+	if d.Status == oas.WebhookDeliveryStatusPending {
+		return tx.Bucket(bucketPendingDeliveries), deliveryIndexKey(d.NextAttempt, d.ID)
+	}
+	return tx.Bucket(bucketFinishedDeliveries), deliveryIndexKey(d.FinishedAt, d.ID)
+}
+
+// putDelivery stores delivery and updates its indexes, old is previously
+// stored delivery, if any.
+func putDelivery(tx *bbolt.Tx, old *Delivery, d Delivery) error {
+	if old != nil {
+		b, key := deliveryIndex(tx, *old)
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	} else {
+		key := binary.BigEndian.AppendUint64(petKey(d.WebhookID), uint64(d.ID))
+		if err := tx.Bucket(bucketWebhookDeliveries).Put(key, nil); err != nil {
+			return err
+		}
+	}
+	b, key := deliveryIndex(tx, d)
+	if err := b.Put(key, nil); err != nil {
+		return err
+	}
+	return putJSON(tx.Bucket(bucketDeliveries), petKey(d.ID), d)
+}
+
+// deleteDelivery deletes delivery with its indexes.
+func deleteDelivery(tx *bbolt.Tx, id int64) error {
+	deliveries := tx.Bucket(bucketDeliveries)
+	var d Delivery
+	if err := getJSON(deliveries, petKey(id), &d, ErrWebhookNotFound); err != nil {
+		return err
+	}
+	b, key := deliveryIndex(tx, d)
+	if err := b.Delete(key); err != nil {
+		return err
+	}
+	key = binary.BigEndian.AppendUint64(petKey(d.WebhookID), uint64(d.ID))
+	if err := tx.Bucket(bucketWebhookDeliveries).Delete(key); err != nil {
+		return err
+	}
+	return deliveries.Delete(petKey(id))
+}
+
+// AddDelivery implements WebhookStorage.
+func (s *BoltStorage) AddDelivery(ctx context.Context, d Delivery) (Delivery, error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(bucketWebhooks).Get(petKey(d.WebhookID)) == nil {
+			return ErrWebhookNotFound
+		}
+		seq, err := tx.Bucket(bucketDeliveries).NextSequence()
+		if err != nil {
+			return errors.Wrap(err, "next id")
+		}
+		d.ID = int64(seq)
+		return putDelivery(tx, nil, d)
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+	return d, nil
+}
+
+// UpdateDelivery implements WebhookStorage.
+func (s *BoltStorage) UpdateDelivery(ctx context.Context, id int64, fn func(d *Delivery) error) (d Delivery, _ error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var old Delivery
+		if err := getJSON(tx.Bucket(bucketDeliveries), petKey(id), &old, ErrWebhookNotFound); err != nil {
+			return err
+		}
+		d = old.clone()
+		if err := fn(&d); err != nil {
+			return err
+		}
+		return putDelivery(tx, &old, d)
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+	return d, nil
+}
+
+// getDeliveries decodes deliveries with given IDs.
+func getDeliveries(tx *bbolt.Tx, ids []int64) ([]Delivery, error) {
+	b := tx.Bucket(bucketDeliveries)
+	r := make([]Delivery, 0, len(ids))
+	for _, id := range ids {
+		var d Delivery
+		if err := getJSON(b, petKey(id), &d, ErrWebhookNotFound); err != nil {
+			return nil, errors.Wrapf(err, "delivery %d", id)
+		}
+		r = append(r, d)
+	}
+	return r, nil
+}
+
+// ListDeliveries implements WebhookStorage.
+func (s *BoltStorage) ListDeliveries(ctx context.Context, webhookID, beforeID int64, limit int) (r []Delivery, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		prefix := petKey(webhookID)
+		if beforeID == 0 {
+			beforeID = math.MaxInt64
+		}
+		// Seek to the first key after page and step back.
+		c := tx.Bucket(bucketWebhookDeliveries).Cursor()
+		k, _ := c.Seek(binary.BigEndian.AppendUint64(prefix, uint64(beforeID)))
+		if k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		var ids []int64
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(ids) < limit; k, _ = c.Prev() {
+			ids = append(ids, int64(binary.BigEndian.Uint64(k[8:])))
+		}
+		var err error
+		r, err = getDeliveries(tx, ids)
+		return err
+	})
+	return r, err
+}
+
+// PendingDeliveries implements WebhookStorage.
+func (s *BoltStorage) PendingDeliveries(ctx context.Context, limit int) (r []Delivery, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var ids []int64
+		c := tx.Bucket(bucketPendingDeliveries).Cursor()
+		for k, _ := c.First(); k != nil && len(ids) < limit; k, _ = c.Next() {
+			ids = append(ids, int64(binary.BigEndian.Uint64(k[8:])))
+		}
+		var err error
+		r, err = getDeliveries(tx, ids)
+		return err
+	})
+	return r, err
+}
+
+// PruneDeliveries implements WebhookStorage.
+func (s *BoltStorage) PruneDeliveries(ctx context.Context, before time.Time) (n int, _ error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			ids []int64
+			end = deliveryIndexKey(before, 0)
+			c   = tx.Bucket(bucketFinishedDeliveries).Cursor()
+		)
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+			ids = append(ids, int64(binary.BigEndian.Uint64(k[8:])))
+		}
+		for _, id := range ids {
+			if err := deleteDelivery(tx, id); err != nil {
+				return errors.Wrapf(err, "delete delivery %d", id)
+			}
+		}
+		n = len(ids)
+		return nil
+	})
+	return n, err
+}
+
+// PendingOutbox implements OutboxStorage.
+func (s *BoltStorage) PendingOutbox(ctx context.Context, afterID uint64, limit int) (r []OutboxRecord, _ error) {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketOutbox).Cursor()
+		for k, data := c.Seek(petKey(int64(afterID + 1))); k != nil && len(r) < limit; k, data = c.Next() {
+			rec, err := decodeOutbox(data)
+			if err != nil {
+				return err
+			}
+			r = append(r, rec)
+		}
+		return nil
+	})
+	return r, err
+}
+
+// AckOutbox implements OutboxStorage.
+func (s *BoltStorage) AckOutbox(ctx context.Context, ids ...uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketOutbox)
+		for _, id := range ids {
+			if err := b.Delete(petKey(int64(id))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close implements Storage.
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
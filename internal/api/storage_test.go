@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	pet, err := s.AddPet(ctx, oas.Pet{
+		Name:      "Tom",
+		PhotoUrls: []string{"https://example.com/tom.png"},
+	})
+	require.NoError(t, err)
+	id, ok := pet.ID.Get()
+	require.True(t, ok, "ID should be assigned")
+
+	got, err := s.GetPet(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, pet, got)
+
+	// Explicit ID should not be reused by sequence.
+	_, err = s.AddPet(ctx, oas.Pet{ID: oas.NewOptInt64(100), Name: "Jerry"})
+	require.NoError(t, err)
+	next, err := s.AddPet(ctx, oas.Pet{Name: "Spike"})
+	require.NoError(t, err)
+	require.Equal(t, oas.NewOptInt64(101), next.ID)
+
+	// Explicit ID must be positive and not used.
+	for _, invalid := range []int64{0, -1, math.MinInt64} {
+		_, err = s.AddPet(ctx, oas.Pet{ID: oas.NewOptInt64(invalid), Name: "Tyke"})
+		require.ErrorIs(t, err, ErrInvalidID, invalid)
+	}
+	_, err = s.AddPet(ctx, oas.Pet{ID: oas.NewOptInt64(100), Name: "Tyke"})
+	require.ErrorIs(t, err, ErrConflict)
+	got, err = s.GetPet(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, "Jerry", got.Name)
+
+	updated, err := s.UpdatePet(ctx, id, func(pet *oas.Pet) error {
+		pet.Status.SetTo(oas.PetStatusSold)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, oas.NewOptPetStatus(oas.PetStatusSold), updated.Status)
+
+	got, err = s.GetPet(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+
+	require.NoError(t, s.DeletePet(ctx, id))
+	_, err = s.GetPet(ctx, id)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, s.DeletePet(ctx, id), ErrNotFound)
+	_, err = s.UpdatePet(ctx, id, func(pet *oas.Pet) error { return nil })
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = s.UpdatePet(ctx, next.ID.Value, func(pet *oas.Pet) error { return errors.New("rollback") })
+	require.EqualError(t, err, "rollback")
+
+	// Failed mutations are not recorded.
+	records, err := s.PendingOutbox(ctx, 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, []EventType{
+		EventCreated,
+		EventCreated,
+		EventCreated,
+		EventUpdated,
+		EventStatusChanged,
+		EventDeleted,
+	}, recordTypes(records))
+	require.Equal(t, updated, records[4].Event.Pet)
+	require.Equal(t, id, records[5].Event.PetID)
+	require.NoError(t, s.AckOutbox(ctx, records[0].Event.ID, records[2].Event.ID))
+	records, err = s.PendingOutbox(ctx, 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 4}, recordIDs(records))
+	records, err = s.PendingOutbox(ctx, 4, 100)
+	require.NoError(t, err)
+	require.Equal(t, []EventType{EventStatusChanged, EventDeleted}, recordTypes(records))
+
+	testWebhookStorage(t, s)
+	require.NoError(t, s.Close())
+}
+
+func testWebhookStorage(t *testing.T, s WebhookStorage) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	wh, err := s.AddWebhook(ctx, Webhook{
+		URL:       "https://example.com/hook",
+		Filter:    EventFilter{Events: []EventType{EventStatusChanged}},
+		Secret:    "0123456789abcdef",
+		Enabled:   true,
+		CreatedAt: now,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, wh.ID)
+	other, err := s.AddWebhook(ctx, Webhook{URL: "https://example.com/other", CreatedAt: now})
+	require.NoError(t, err)
+
+	got, err := s.GetWebhook(ctx, wh.ID)
+	require.NoError(t, err)
+	require.Equal(t, wh, got)
+	list, err := s.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Webhook{wh, other}, list)
+
+	wh, err = s.UpdateWebhook(ctx, wh.ID, func(wh *Webhook) error {
+		wh.Failures++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, wh.Failures)
+
+	var ids []int64
+	for i := range 3 {
+		d, err := s.AddDelivery(ctx, Delivery{
+			WebhookID:   wh.ID,
+			EventID:     uint64(i + 1),
+			EventType:   EventStatusChanged,
+			Payload:     []byte(`{}`),
+			Trace:       map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			Status:      oas.WebhookDeliveryStatusPending,
+			NextAttempt: now.Add(-time.Duration(i) * time.Second),
+			CreatedAt:   now,
+		})
+		require.NoError(t, err)
+		ids = append(ids, d.ID)
+	}
+	_, err = s.AddDelivery(ctx, Delivery{WebhookID: other.ID, Status: oas.WebhookDeliveryStatusSucceeded, FinishedAt: now})
+	require.NoError(t, err)
+	_, err = s.AddDelivery(ctx, Delivery{WebhookID: 1000})
+	require.ErrorIs(t, err, ErrWebhookNotFound)
+
+	d, err := s.UpdateDelivery(ctx, ids[2], func(d *Delivery) error {
+		d.Status = oas.WebhookDeliveryStatusSucceeded
+		d.Attempts = append(d.Attempts, DeliveryAttempt{Start: now, Duration: time.Second, StatusCode: 200})
+		d.FinishedAt = now.Add(time.Second)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, d.Attempts, 1)
+	_, err = s.UpdateDelivery(ctx, 1000, func(d *Delivery) error { return nil })
+	require.ErrorIs(t, err, ErrWebhookNotFound)
+
+	deliveries, err := s.ListDeliveries(ctx, wh.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	require.Equal(t, d, deliveries[0], "most recent first")
+	// Pages.
+	deliveries, err = s.ListDeliveries(ctx, wh.ID, 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{ids[2], ids[1]}, []int64{deliveries[0].ID, deliveries[1].ID})
+	deliveries, err = s.ListDeliveries(ctx, wh.ID, ids[1], 2)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, ids[0], deliveries[0].ID)
+
+	// Ordered by next attempt.
+	pending, err := s.PendingDeliveries(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, []int64{ids[1], ids[0]}, []int64{pending[0].ID, pending[1].ID})
+	pending, err = s.PendingDeliveries(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// Only deliveries finished before given time are pruned.
+	n, err := s.PruneDeliveries(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	deliveries, err = s.ListDeliveries(ctx, other.ID, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+	n, err = s.PruneDeliveries(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	deliveries, err = s.ListDeliveries(ctx, wh.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	_, err = s.AddDelivery(ctx, Delivery{WebhookID: other.ID, Status: oas.WebhookDeliveryStatusPending})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteWebhook(ctx, wh.ID))
+	_, err = s.GetWebhook(ctx, wh.ID)
+	require.ErrorIs(t, err, ErrWebhookNotFound)
+	require.ErrorIs(t, s.DeleteWebhook(ctx, wh.ID), ErrWebhookNotFound)
+	deliveries, err = s.ListDeliveries(ctx, wh.ID, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+	pending, err = s.PendingDeliveries(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, other.ID, pending[0].WebhookID)
+	deliveries, err = s.ListDeliveries(ctx, other.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func TestBoltStorage(t *testing.T) {
+	s, err := OpenBoltStorage(filepath.Join(t.TempDir(), "pets.db"))
+	require.NoError(t, err)
+	testStorage(t, s)
+}
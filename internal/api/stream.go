@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"example/internal/httpmiddleware"
+)
+
+// StreamConfig configures EventStream.
+type StreamConfig struct {
+	// Heartbeat is an interval of comments keeping idle connection alive.
+	//
+	// Defaults to 15s.
+	Heartbeat time.Duration
+	// Buffer is a number of events queued per subscriber. Subscriber with
+	// full queue is disconnected and expected to reconnect with Last-Event-ID.
+	//
+	// Defaults to 64.
+	Buffer int
+	// CloudEvents configures event data.
+	CloudEvents CloudEventsConfig
+}
+
+func (c *StreamConfig) setDefaults() {
+	if c.Heartbeat <= 0 {
+		c.Heartbeat = 15 * time.Second
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 64
+	}
+}
+
+// EventStream serves pet change events as Server-Sent Events.
+//
+// Request span ends once the stream is established, the connection itself
+// is traced by child span ended on disconnect.
+type EventStream struct {
+	events     *Events
+	cfg        StreamConfig
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	active   metric.Int64UpDownCounter
+	duration metric.Float64Histogram
+	sent     metric.Int64Counter
+}
+
+// NewEventStream creates new EventStream.
+func NewEventStream(events *Events, m httpmiddleware.Metrics, cfg StreamConfig) (*EventStream, error) {
+	cfg.setDefaults()
+	meter := m.MeterProvider().Meter("example/internal/api")
+	active, err := meter.Int64UpDownCounter("sse.connections.active",
+		metric.WithDescription("Number of open event stream connections"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create active counter")
+	}
+	duration, err := meter.Float64Histogram("sse.connection.duration",
+		metric.WithDescription("Duration of event stream connections by close reason"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create duration histogram")
+	}
+	sent, err := meter.Int64Counter("sse.events.sent",
+		metric.WithDescription("Number of sent events by event type"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create sent counter")
+	}
+	return &EventStream{
+		events:     events,
+		cfg:        cfg,
+		tracer:     m.TracerProvider().Tracer("example/internal/api"),
+		propagator: m.TextMapPropagator(),
+		active:     active,
+		duration:   duration,
+		sent:       sent,
+	}, nil
+}
+
+// Close reasons of event stream and WebSocket connection.
+const (
+	closeClient   = "client"
+	closeLagged   = "lagged"
+	closeShutdown = "shutdown"
+	closeError    = "write_error"
+	closePing     = "ping_timeout"
+)
+
+// ServeHTTP implements http.Handler.
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := s.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := s.tracer.Start(ctx, "api.petEvents",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", "/pet/events"),
+		),
+	)
+	defer span.End()
+
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			span.SetStatus(codes.Error, "invalid Last-Event-ID")
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+	sub := s.events.Subscribe(lastID, s.cfg.Buffer)
+	defer sub.Close()
+	span.SetAttributes(
+		attribute.Int64("sse.last_event_id", int64(lastID)),
+		attribute.Int("sse.replayed", len(sub.Replay)),
+		attribute.Bool("sse.missed", sub.Missed),
+	)
+
+	rc := http.NewResponseController(w)
+	// Stream outlives server write timeout.
+	_ = rc.SetWriteDeadline(time.Time{})
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev Event) error {
+		if err := writeEvent(w, ev, s.cfg.CloudEvents); err != nil {
+			return err
+		}
+		s.sent.Add(ctx, 1, metric.WithAttributes(attribute.String("event.type", string(ev.Type))))
+		return nil
+	}
+	err := func() error {
+		if sub.Missed {
+			// Client should reload state, events since its last one are lost.
+			if _, err := io.WriteString(w, "event: reset\ndata: {}\n\n"); err != nil {
+				return err
+			}
+		}
+		for _, ev := range sub.Replay {
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	}()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "write")
+		return
+	}
+	span.SetStatus(codes.Ok, "")
+	span.End()
+
+	s.stream(ctx, w, rc, sub, send)
+}
+
+// stream sends events until client disconnects or subscription is closed.
+func (s *EventStream) stream(
+	ctx context.Context,
+	w http.ResponseWriter,
+	rc *http.ResponseController,
+	sub *Subscription,
+	send func(ev Event) error,
+) {
+	start := time.Now()
+	ctx, span := s.tracer.Start(ctx, "api.petEvents.stream")
+	defer span.End()
+	s.active.Add(ctx, 1)
+
+	var (
+		sent   int
+		reason string
+	)
+	defer func() {
+		s.active.Add(ctx, -1)
+		s.duration.Record(ctx, time.Since(start).Seconds(),
+			metric.WithAttributes(attribute.String("sse.close_reason", reason)),
+		)
+		span.SetAttributes(
+			attribute.Int("sse.events_sent", sent),
+			attribute.String("sse.close_reason", reason),
+		)
+		zctx.From(ctx).Debug("Event stream closed",
+			zap.String("reason", reason),
+			zap.Int("sent", sent),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}()
+
+	heartbeat := time.NewTicker(s.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			reason = closeClient
+			return
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		case ev, ok := <-sub.C():
+			if !ok {
+				reason = closeShutdown
+				if errors.Is(sub.Err(), ErrSubscriberLagged) {
+					reason = closeLagged
+				}
+				return
+			}
+			if err = send(ev); err == nil {
+				sent++
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			reason = closeError
+			span.RecordError(err)
+			return
+		}
+	}
+}
+
+// writeEvent writes event in text/event-stream format with structured
+// CloudEvent as data.
+func writeEvent(w io.Writer, ev Event, ce CloudEventsConfig) error {
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	NewCloudEvent(ev, ce).Encode(e)
+
+	buf := make([]byte, 0, len(e.Bytes())+64)
+	buf = append(buf, "id: "...)
+	buf = strconv.AppendUint(buf, ev.ID, 10)
+	buf = append(buf, "\nevent: "...)
+	buf = append(buf, ev.Type...)
+	buf = append(buf, "\ndata: "...)
+	buf = append(buf, e.Bytes()...)
+	buf = append(buf, "\n\n"...)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,529 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+// Webhook is a registered receiver of pet change events.
+type Webhook struct {
+	ID     int64       `json:"id"`
+	URL    string      `json:"url"`
+	Filter EventFilter `json:"filter"`
+	// Secret is a key of request signature.
+	Secret  string `json:"secret"`
+	Enabled bool   `json:"enabled"`
+	// Failures is a number of consecutive failed attempts.
+	Failures       int    `json:"failures"`
+	DisabledReason string `json:"disabled_reason,omitempty"`
+	// ContentMode is a CloudEvents HTTP content mode, empty means structured.
+	ContentMode oas.WebhookContentMode `json:"content_mode,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+func (wh Webhook) clone() Webhook {
+	wh.Filter.PetIDs = slices.Clone(wh.Filter.PetIDs)
+	wh.Filter.Statuses = slices.Clone(wh.Filter.Statuses)
+	wh.Filter.Events = slices.Clone(wh.Filter.Events)
+	return wh
+}
+
+// Delivery is a queued notification of webhook about event.
+type Delivery struct {
+	ID        int64     `json:"id"`
+	WebhookID int64     `json:"webhook_id"`
+	EventID   uint64    `json:"event_id"`
+	EventType EventType `json:"event_type"`
+	// Payload is a structured CloudEvent.
+	Payload []byte `json:"payload"`
+	// Trace is a context of request that caused event, delivery spans
+	// are linked to it.
+	Trace       propagation.MapCarrier    `json:"trace,omitempty"`
+	Status      oas.WebhookDeliveryStatus `json:"status"`
+	Attempts    []DeliveryAttempt         `json:"attempts,omitempty"`
+	NextAttempt time.Time                 `json:"next_attempt"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	// FinishedAt is a time delivery succeeded or failed.
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+func (d Delivery) clone() Delivery {
+	d.Payload = slices.Clone(d.Payload)
+	d.Attempts = slices.Clone(d.Attempts)
+	return d
+}
+
+// DeliveryAttempt is an attempt to deliver event to webhook.
+type DeliveryAttempt struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	// StatusCode is a response status, zero if request failed.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// sortPending sorts pending deliveries by time of next attempt.
+func sortPending(ds []Delivery) {
+	slices.SortFunc(ds, func(a, b Delivery) int {
+		if c := a.NextAttempt.Compare(b.NextAttempt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// WebhookStorage stores webhooks and queue of their deliveries.
+type WebhookStorage interface {
+	// AddWebhook stores new webhook, assigning ID.
+	AddWebhook(ctx context.Context, wh Webhook) (Webhook, error)
+	// GetWebhook returns webhook by ID or ErrWebhookNotFound.
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	// ListWebhooks returns webhooks ordered by ID.
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	// UpdateWebhook calls fn on stored webhook and saves the result.
+	UpdateWebhook(ctx context.Context, id int64, fn func(wh *Webhook) error) (Webhook, error)
+	// DeleteWebhook deletes webhook with its deliveries or returns ErrWebhookNotFound.
+	DeleteWebhook(ctx context.Context, id int64) error
+
+	// AddDelivery stores new delivery of existing webhook, assigning ID.
+	AddDelivery(ctx context.Context, d Delivery) (Delivery, error)
+	// UpdateDelivery calls fn on stored delivery and saves the result.
+	UpdateDelivery(ctx context.Context, id int64, fn func(d *Delivery) error) (Delivery, error)
+	// ListDeliveries returns up to limit deliveries of webhook with ID less
+	// than beforeID, the most recent first. Zero beforeID lists from the
+	// most recent one.
+	ListDeliveries(ctx context.Context, webhookID, beforeID int64, limit int) ([]Delivery, error)
+	// PendingDeliveries returns up to limit pending deliveries ordered by
+	// time of next attempt.
+	PendingDeliveries(ctx context.Context, limit int) ([]Delivery, error)
+	// PruneDeliveries deletes deliveries finished before given time and
+	// returns their number.
+	PruneDeliveries(ctx context.Context, before time.Time) (int, error)
+}
+
+// Webhook request headers.
+const (
+	WebhookIDHeader        = "Webhook-Id"
+	WebhookEventHeader     = "Webhook-Event"
+	WebhookTimestampHeader = "Webhook-Timestamp"
+	WebhookSignatureHeader = "Webhook-Signature"
+)
+
+// WebhookSignature returns value of Webhook-Signature header: hex-encoded
+// HMAC-SHA256 of timestamp in Unix seconds, dot and body, keyed by secret.
+func WebhookSignature(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(strconv.AppendInt(nil, timestamp.Unix(), 10))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks signature of webhook request with given body.
+//
+// Requests with timestamp older than tolerance are rejected to prevent replay.
+func VerifyWebhook(r *http.Request, body []byte, secret string, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "parse timestamp")
+	}
+	timestamp := time.Unix(ts, 0)
+	if d := time.Since(timestamp); d > tolerance || d < -tolerance {
+		return errors.Errorf("timestamp %s is out of tolerance", timestamp.UTC())
+	}
+	want := WebhookSignature(secret, timestamp, body)
+	if !hmac.Equal([]byte(r.Header.Get(WebhookSignatureHeader)), []byte(want)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// WebhooksConfig configures Webhooks.
+type WebhooksConfig struct {
+	// Client sends webhook requests, its transport should enforce Policy.
+	//
+	// Defaults to client with 10s timeout and Policy transport.
+	Client *http.Client
+	// Policy restricts addresses of webhooks.
+	Policy WebhookPolicy
+	// MaxAttempts is a number of attempts before delivery fails.
+	//
+	// Defaults to 8.
+	MaxAttempts int
+	// MinBackoff is a delay before the second attempt, doubled for every
+	// next one.
+	//
+	// Defaults to 1s.
+	MinBackoff time.Duration
+	// MaxBackoff limits delay between attempts.
+	//
+	// Defaults to 1h.
+	MaxBackoff time.Duration
+	// DisableAfter is a number of consecutive failed attempts disabling
+	// webhook.
+	//
+	// Defaults to 20.
+	DisableAfter int
+	// PollInterval is an interval of checking queue for due deliveries
+	// and pruning finished ones.
+	//
+	// Defaults to 5s.
+	PollInterval time.Duration
+	// Retention is how long finished deliveries are kept.
+	//
+	// Defaults to 7 days.
+	Retention time.Duration
+	// CloudEvents configures payloads.
+	CloudEvents CloudEventsConfig
+}
+
+func (c *WebhooksConfig) setDefaults() {
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second, Transport: c.Policy.Transport()}
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 8
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Hour
+	}
+	if c.DisableAfter <= 0 {
+		c.DisableAfter = 20
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = 5 * time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * time.Hour
+	}
+}
+
+// backoff returns delay after given number of failed attempts.
+func (c WebhooksConfig) backoff(attempts int) time.Duration {
+	d := c.MinBackoff
+	for i := 1; i < attempts && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxBackoff)
+}
+
+// Webhooks delivers pet change events to registered webhooks.
+//
+// Deliveries are queued in WebhookStorage, so they survive restart with
+// durable storage, and retried with exponential backoff.
+type Webhooks struct {
+	storage    WebhookStorage
+	cfg        WebhooksConfig
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	wake       chan struct{}
+	now        func() time.Time
+
+	attempts metric.Int64Counter
+	disabled metric.Int64Counter
+}
+
+// NewWebhooks creates new Webhooks.
+func NewWebhooks(storage WebhookStorage, m httpmiddleware.Metrics, cfg WebhooksConfig) (*Webhooks, error) {
+	cfg.setDefaults()
+	meter := m.MeterProvider().Meter("example/internal/api")
+	attempts, err := meter.Int64Counter("webhook.attempts",
+		metric.WithDescription("Number of webhook delivery attempts by result"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create attempts counter")
+	}
+	disabled, err := meter.Int64Counter("webhook.disabled",
+		metric.WithDescription("Number of webhooks disabled after repeated failures"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create disabled counter")
+	}
+	return &Webhooks{
+		storage:    storage,
+		cfg:        cfg,
+		tracer:     m.TracerProvider().Tracer("example/internal/api"),
+		propagator: m.TextMapPropagator(),
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+		attempts:   attempts,
+		disabled:   disabled,
+	}, nil
+}
+
+// Publish implements EventPublisher, queueing delivery of event to every
+// enabled webhook matching it.
+//
+// Delivery spans are linked to span of ctx.
+func (w *Webhooks) Publish(ctx context.Context, ev Event) error {
+	webhooks, err := w.storage.ListWebhooks(ctx)
+	if err != nil {
+		return errors.Wrap(err, "list webhooks")
+	}
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	NewCloudEvent(ev, w.cfg.CloudEvents).Encode(e)
+
+	carrier := propagation.MapCarrier{}
+	w.propagator.Inject(ctx, carrier)
+	now := w.now()
+	var queued bool
+	for _, wh := range webhooks {
+		if !wh.Enabled || !wh.Filter.Match(ev) {
+			continue
+		}
+		if _, err := w.storage.AddDelivery(ctx, Delivery{
+			WebhookID:   wh.ID,
+			EventID:     ev.ID,
+			EventType:   ev.Type,
+			Payload:     e.Bytes(),
+			Trace:       carrier,
+			Status:      oas.WebhookDeliveryStatusPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}); err != nil && !errors.Is(err, ErrWebhookNotFound) {
+			return errors.Wrapf(err, "add delivery to webhook %d", wh.ID)
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run delivers queued events until ctx is done.
+func (w *Webhooks) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var lastPrune time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.wake:
+		case <-timer.C:
+		}
+		next, err := w.deliverDue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			zctx.From(ctx).Error("Webhook delivery failed", zap.Error(err))
+		}
+		// Wake-ups by publish do not prune more often than polling.
+		if now := w.now(); now.Sub(lastPrune) >= w.cfg.PollInterval {
+			lastPrune = now
+			if err := w.prune(ctx, now); err != nil && ctx.Err() == nil {
+				zctx.From(ctx).Error("Webhook deliveries pruning failed", zap.Error(err))
+			}
+		}
+		wait := w.cfg.PollInterval
+		if !next.IsZero() {
+			wait = min(wait, max(next.Sub(w.now()), 0))
+		}
+		timer.Stop()
+		timer.Reset(wait)
+	}
+}
+
+// prune deletes deliveries finished before retention period.
+func (w *Webhooks) prune(ctx context.Context, now time.Time) error {
+	n, err := w.storage.PruneDeliveries(ctx, now.Add(-w.cfg.Retention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		zctx.From(ctx).Debug("Pruned webhook deliveries", zap.Int("count", n))
+	}
+	return nil
+}
+
+// deliverDue attempts due deliveries and returns time of the next pending
+// one, zero if there is none.
+func (w *Webhooks) deliverDue(ctx context.Context) (time.Time, error) {
+	const batch = 100
+	for {
+		// Every attempt moves delivery out of queue or further in time,
+		// so the loop ends when queue head is not due yet.
+		pending, err := w.storage.PendingDeliveries(ctx, batch)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "pending deliveries")
+		}
+		if len(pending) == 0 {
+			return time.Time{}, nil
+		}
+		for _, d := range pending {
+			if d.NextAttempt.After(w.now()) {
+				return d.NextAttempt, nil
+			}
+			if err := w.deliver(ctx, d); err != nil {
+				return time.Time{}, errors.Wrapf(err, "deliver %d", d.ID)
+			}
+		}
+	}
+}
+
+// deliver makes an attempt of delivery and updates its state.
+func (w *Webhooks) deliver(ctx context.Context, d Delivery) error {
+	wh, err := w.storage.GetWebhook(ctx, d.WebhookID)
+	if err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return errors.Wrap(err, "get webhook")
+	}
+	if err != nil || !wh.Enabled {
+		// Deliveries of deleted webhook are deleted concurrently.
+		if _, err := w.storage.UpdateDelivery(ctx, d.ID, func(d *Delivery) error {
+			d.Status = oas.WebhookDeliveryStatusFailed
+			d.NextAttempt = time.Time{}
+			d.FinishedAt = w.now()
+			return nil
+		}); err != nil && !errors.Is(err, ErrWebhookNotFound) {
+			return errors.Wrap(err, "update delivery")
+		}
+		return nil
+	}
+
+	attempt := w.attempt(ctx, wh, d)
+	result := "success"
+	if attempt.Error != "" {
+		result = "failure"
+	}
+	w.attempts.Add(ctx, 1, metric.WithAttributes(attribute.String("webhook.result", result)))
+
+	if _, err := w.storage.UpdateDelivery(ctx, d.ID, func(d *Delivery) error {
+		d.Attempts = append(d.Attempts, attempt)
+		switch {
+		case attempt.Error == "":
+			d.Status = oas.WebhookDeliveryStatusSucceeded
+			d.NextAttempt = time.Time{}
+			d.FinishedAt = w.now()
+		case len(d.Attempts) >= w.cfg.MaxAttempts:
+			d.Status = oas.WebhookDeliveryStatusFailed
+			d.NextAttempt = time.Time{}
+			d.FinishedAt = w.now()
+		default:
+			d.NextAttempt = attempt.Start.Add(attempt.Duration + w.cfg.backoff(len(d.Attempts)))
+		}
+		return nil
+	}); err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return errors.Wrap(err, "update delivery")
+	}
+
+	if _, err := w.storage.UpdateWebhook(ctx, wh.ID, func(wh *Webhook) error {
+		if attempt.Error == "" {
+			wh.Failures = 0
+			return nil
+		}
+		wh.Failures++
+		if wh.Enabled && wh.Failures >= w.cfg.DisableAfter {
+			wh.Enabled = false
+			wh.DisabledReason = fmt.Sprintf("%d consecutive failed attempts, last: %s", wh.Failures, attempt.Error)
+			w.disabled.Add(ctx, 1)
+			zctx.From(ctx).Warn("Webhook disabled",
+				zap.Int64("webhook", wh.ID),
+				zap.String("reason", wh.DisabledReason),
+			)
+		}
+		return nil
+	}); err != nil && !errors.Is(err, ErrWebhookNotFound) {
+		return errors.Wrap(err, "update webhook")
+	}
+	return nil
+}
+
+// attempt sends delivery request.
+func (w *Webhooks) attempt(ctx context.Context, wh Webhook, d Delivery) (attempt DeliveryAttempt) {
+	// Delivery is a separate trace linked to request that caused event.
+	origin := trace.SpanContextFromContext(w.propagator.Extract(context.Background(), d.Trace))
+	ctx, span := w.tracer.Start(ctx, "webhook.deliver",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithLinks(trace.Link{SpanContext: origin}),
+		trace.WithAttributes(
+			attribute.Int64("webhook.id", wh.ID),
+			attribute.Int64("webhook.delivery.id", d.ID),
+			attribute.Int("webhook.delivery.attempt", len(d.Attempts)+1),
+			attribute.String("event.type", string(d.EventType)),
+		),
+	)
+	defer span.End()
+
+	attempt.Start = w.now()
+	defer func() {
+		attempt.Duration = w.now().Sub(attempt.Start)
+		if attempt.StatusCode != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", attempt.StatusCode))
+		}
+		if attempt.Error != "" {
+			span.SetStatus(codes.Error, attempt.Error)
+		}
+	}()
+
+	header := http.Header{}
+	body := d.Payload
+	if wh.ContentMode == oas.WebhookContentModeBinary {
+		ce, err := ReadCloudEvent(http.Header{"Content-Type": {CloudEventsContentType}}, d.Payload)
+		if err != nil {
+			attempt.Error = err.Error()
+			return attempt
+		}
+		body = ce.WriteHTTP(header)
+	} else {
+		header.Set("Content-Type", CloudEventsContentType)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header = header
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookEventHeader, string(d.EventType))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(attempt.Start.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(wh.Secret, attempt.Start, body))
+	w.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+// ErrWebhookAddressDenied is returned for webhook addresses denied by
+// WebhookPolicy.
+var ErrWebhookAddressDenied = errors.New("webhook address is denied")
+
+// WebhookPolicy restricts addresses webhooks are delivered to, so that
+// webhooks can not be used for server-side request forgery.
+//
+// Loopback, private, link-local, unspecified and multicast addresses are
+// denied unless allowed explicitly.
+type WebhookPolicy struct {
+	// Allow are allowed networks, they take precedence over denied ones.
+	Allow []netip.Prefix
+	// Deny are denied networks in addition to default ones.
+	Deny []netip.Prefix
+}
+
+// Allowed reports whether webhook can be delivered to addr.
+func (p WebhookPolicy) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, n := range p.Allow {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	for _, n := range p.Deny {
+		if n.Contains(addr) {
+			return false
+		}
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+func (p WebhookPolicy) check(addr netip.Addr) error {
+	if !p.Allowed(addr) {
+		return errors.Wrapf(ErrWebhookAddressDenied, "%s", addr)
+	}
+	return nil
+}
+
+// CheckHost checks every address of webhook host, resolving names.
+//
+// Names that can not be resolved are accepted, since addresses are checked
+// again on dial, see Transport.
+func (p WebhookPolicy) CheckHost(ctx context.Context, host string) error {
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if err := p.check(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// control is net.Dialer.Control rejecting connections to denied addresses.
+func (p WebhookPolicy) control(_, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrap(err, "parse address")
+	}
+	return p.check(addr.Addr())
+}
+
+// Transport returns HTTP transport connecting only to allowed addresses.
+//
+// Proxy from environment is not used, since it would connect to any address.
+func (p WebhookPolicy) Transport() *http.Transport {
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	return t
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// allowLoopback allows delivery to test servers.
+var allowLoopback = WebhookPolicy{
+	Allow: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")},
+}
+
+func TestWebhookPolicy(t *testing.T) {
+	p := WebhookPolicy{
+		Allow: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		Deny:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}
+	for addr, allowed := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:2800:220::1": true,
+		"10.1.2.3":         true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"10.2.0.1":         false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1%eth0":     false,
+		"fd00::1":          false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+		"203.0.113.10":     false,
+	} {
+		require.Equal(t, allowed, p.Allowed(netip.MustParseAddr(addr)), addr)
+	}
+
+	ctx := context.Background()
+	require.NoError(t, p.CheckHost(ctx, "93.184.216.34"))
+	require.ErrorIs(t, p.CheckHost(ctx, "127.0.0.1"), ErrWebhookAddressDenied)
+	require.ErrorIs(t, p.CheckHost(ctx, "::1"), ErrWebhookAddressDenied)
+
+	// Checked on dial too, e.g. if name resolves to other address later.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, err := (&http.Client{Transport: WebhookPolicy{}.Transport()}).Get(srv.URL)
+	require.ErrorIs(t, err, ErrWebhookAddressDenied)
+	resp, err := (&http.Client{Transport: allowLoopback.Transport()}).Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+type testMetrics struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+func (m testMetrics) TracerProvider() trace.TracerProvider { return m.tp }
+func (m testMetrics) MeterProvider() metric.MeterProvider  { return m.mp }
+func (m testMetrics) TextMapPropagator() propagation.TextMapPropagator {
+	return propagation.TraceContext{}
+}
+
+// testReceiver is webhook receiver responding with configured status.
+type testReceiver struct {
+	status atomic.Int64
+
+	mux      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mux.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.mux.Unlock()
+	w.WriteHeader(int(r.status.Load()))
+}
+
+func TestWebhookSignature(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	sig := WebhookSignature("0123456789abcdef", ts, body)
+	require.Regexp(t, `^sha256=[0-9a-f]{64}$`, sig)
+	require.NotEqual(t, sig, WebhookSignature("0123456789abcdeg", ts, body))
+	require.NotEqual(t, sig, WebhookSignature("0123456789abcdef", ts.Add(time.Second), body))
+
+	now := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature("secret", now, body))
+	require.NoError(t, VerifyWebhook(req, body, "secret", time.Minute))
+	require.EqualError(t, VerifyWebhook(req, []byte(`{"id":2}`), "secret", time.Minute), "signature mismatch")
+	require.EqualError(t, VerifyWebhook(req, body, "other", time.Minute), "signature mismatch")
+
+	// Replayed request.
+	req.Header.Set(WebhookTimestampHeader, "1700000000")
+	req.Header.Set(WebhookSignatureHeader, sig)
+	require.ErrorContains(t, VerifyWebhook(req, body, "0123456789abcdef", time.Minute), "out of tolerance")
+
+	req.Header.Del(WebhookTimestampHeader)
+	require.ErrorContains(t, VerifyWebhook(req, body, "0123456789abcdef", time.Minute), "parse timestamp")
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	receiver := &testReceiver{}
+	receiver.status.Store(http.StatusInternalServerError)
+	srv := httptest.NewServer(receiver)
+	t.Cleanup(srv.Close)
+
+	provider := httpmiddleware.NewProvider()
+	storage := NewMemoryStorage()
+	w, err := NewWebhooks(storage, testMetrics{tp: provider, mp: sdkmetric.NewMeterProvider()}, WebhooksConfig{
+		MaxAttempts:  3,
+		MinBackoff:   time.Second,
+		MaxBackoff:   2 * time.Second,
+		DisableAfter: 5,
+		Policy:       allowLoopback,
+	})
+	require.NoError(t, err)
+	// Real time is used for signature timestamps, so only offset is faked.
+	var offset time.Duration
+	w.now = func() time.Time { return time.Now().Add(offset) }
+
+	sold, err := storage.AddWebhook(ctx, Webhook{
+		URL: srv.URL,
+		Filter: EventFilter{
+			Events:   []EventType{EventStatusChanged},
+			Statuses: []oas.PetStatus{oas.PetStatusSold},
+		},
+		Secret:  "0123456789abcdef",
+		Enabled: true,
+	})
+	require.NoError(t, err)
+	_, err = storage.AddWebhook(ctx, Webhook{URL: srv.URL, Secret: "0123456789abcdef"})
+	require.NoError(t, err)
+
+	// Delivery is linked to span of request that caused event.
+	ctx, origin := provider.Tracer("test").Start(ctx, "origin")
+	ev := Event{
+		ID:    1,
+		Type:  EventStatusChanged,
+		PetID: 10,
+		Pet:   oas.Pet{ID: oas.NewOptInt64(10), Name: "Tom", Status: oas.NewOptPetStatus(oas.PetStatusSold)},
+		Time:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Trace: origin.SpanContext(),
+	}
+	require.NoError(t, w.Publish(ctx, ev))
+	// Not matching filter of the first webhook, the second is disabled.
+	require.NoError(t, w.Publish(ctx, Event{ID: 2, Type: EventUpdated, PetID: 10}))
+	origin.End()
+	ctx = context.Background()
+
+	deliveries, err := storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	pending, err := storage.PendingDeliveries(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// The first attempt fails and is retried after backoff.
+	next, err := w.deliverDue(ctx)
+	require.NoError(t, err)
+	require.False(t, next.IsZero())
+	require.WithinDuration(t, w.now().Add(time.Second), next, 500*time.Millisecond)
+	next, err = w.deliverDue(ctx)
+	require.NoError(t, err)
+	require.False(t, next.IsZero(), "should not be retried before backoff")
+	require.Len(t, receiver.requests, 1)
+
+	receiver.status.Store(http.StatusNoContent)
+	offset += time.Second
+	next, err = w.deliverDue(ctx)
+	require.NoError(t, err)
+	require.True(t, next.IsZero())
+	require.Len(t, receiver.requests, 2)
+
+	d, err := storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, oas.WebhookDeliveryStatusSucceeded, d[0].Status)
+	require.WithinDuration(t, w.now(), d[0].FinishedAt, time.Second)
+	require.Len(t, d[0].Attempts, 2)
+	require.Equal(t, http.StatusInternalServerError, d[0].Attempts[0].StatusCode)
+	require.Equal(t, "unexpected status 500", d[0].Attempts[0].Error)
+	require.Equal(t, http.StatusNoContent, d[0].Attempts[1].StatusCode)
+	require.Empty(t, d[0].Attempts[1].Error)
+	wh, err := storage.GetWebhook(ctx, sold.ID)
+	require.NoError(t, err)
+	require.Zero(t, wh.Failures, "should be reset on success")
+
+	req, body := receiver.requests[1], receiver.bodies[1]
+	require.Equal(t, CloudEventsContentType, req.Header.Get("Content-Type"))
+	ce, err := ReadCloudEvent(req.Header, body)
+	require.NoError(t, err)
+	require.Equal(t, NewCloudEvent(ev, CloudEventsConfig{}), ce)
+	require.Equal(t, "com.example.pet.status_changed", ce.Type)
+	require.JSONEq(t, `{"id":10,"name":"Tom","status":"sold"}`, string(ce.Data))
+	require.Contains(t, ce.TraceParent, origin.SpanContext().TraceID().String())
+	require.Equal(t, strconv.FormatInt(d[0].ID, 10), req.Header.Get(WebhookIDHeader))
+	require.Equal(t, "status_changed", req.Header.Get(WebhookEventHeader))
+	require.NoError(t, VerifyWebhook(req, body, "0123456789abcdef", time.Minute))
+
+	provider.Flush()
+	var spans int
+	for _, s := range provider.Exporter.GetSpans() {
+		if s.Name != "webhook.deliver" {
+			continue
+		}
+		spans++
+		require.Equal(t, trace.SpanKindClient, s.SpanKind)
+		require.False(t, s.Parent.IsValid())
+		require.Len(t, s.Links, 1)
+		require.Equal(t, origin.SpanContext().TraceID(), s.Links[0].SpanContext.TraceID())
+		require.Equal(t, origin.SpanContext().SpanID(), s.Links[0].SpanContext.SpanID())
+	}
+	require.Equal(t, 2, spans)
+	// Receiver continues delivery trace.
+	sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(req.Header)))
+	require.True(t, sc.IsValid())
+	require.NotEqual(t, origin.SpanContext().TraceID(), sc.TraceID())
+
+	// Delivery fails after MaxAttempts, webhook is disabled after
+	// DisableAfter consecutive failures.
+	receiver.status.Store(http.StatusServiceUnavailable)
+	require.NoError(t, w.Publish(ctx, Event{ID: 3, Type: EventStatusChanged, PetID: 10, Pet: ev.Pet}))
+	require.NoError(t, w.Publish(ctx, Event{ID: 4, Type: EventStatusChanged, PetID: 10, Pet: ev.Pet}))
+	for range 5 {
+		_, err := w.deliverDue(ctx)
+		require.NoError(t, err)
+		offset += 2 * time.Second
+	}
+	d, err = storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, d, 3)
+	for _, d := range d[:2] {
+		require.Equal(t, oas.WebhookDeliveryStatusFailed, d.Status)
+	}
+	// Failed after 3 attempts and 2 attempts before webhook was disabled.
+	require.Len(t, d[1].Attempts, 3)
+	require.Len(t, d[0].Attempts, 2)
+
+	wh, err = storage.GetWebhook(ctx, sold.ID)
+	require.NoError(t, err)
+	require.False(t, wh.Enabled)
+	require.Equal(t, 5, wh.Failures)
+	require.Equal(t, "5 consecutive failed attempts, last: unexpected status 503", wh.DisabledReason)
+
+	// Disabled webhook is not notified.
+	require.NoError(t, w.Publish(ctx, Event{ID: 5, Type: EventStatusChanged, PetID: 10, Pet: ev.Pet}))
+	d, err = storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, d, 3)
+
+	// Finished deliveries are pruned after retention.
+	require.NoError(t, w.prune(ctx, w.now()))
+	d, err = storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, d, 3)
+	require.NoError(t, w.prune(ctx, w.now().Add(w.cfg.Retention+time.Second)))
+	d, err = storage.ListDeliveries(ctx, sold.ID, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, d)
+}
+
+func TestWebhooksBinary(t *testing.T) {
+	ctx := context.Background()
+	receiver := &testReceiver{}
+	receiver.status.Store(http.StatusNoContent)
+	srv := httptest.NewServer(receiver)
+	t.Cleanup(srv.Close)
+
+	storage := NewMemoryStorage()
+	cfg := CloudEventsConfig{
+		Source:     "https://example.com/pet",
+		DataSchema: "https://example.com/openapi.yml#/components/schemas/Pet",
+	}
+	w, err := NewWebhooks(storage, testMetrics{tp: httpmiddleware.NewProvider(), mp: sdkmetric.NewMeterProvider()}, WebhooksConfig{
+		CloudEvents: cfg,
+		Policy:      allowLoopback,
+	})
+	require.NoError(t, err)
+	_, err = storage.AddWebhook(ctx, Webhook{
+		URL:         srv.URL,
+		Secret:      "0123456789abcdef",
+		Enabled:     true,
+		ContentMode: oas.WebhookContentModeBinary,
+	})
+	require.NoError(t, err)
+
+	ev := Event{
+		ID:    1,
+		Type:  EventCreated,
+		PetID: 10,
+		Pet:   oas.Pet{ID: oas.NewOptInt64(10), Name: "Tom"},
+		Time:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, w.Publish(ctx, ev))
+	_, err = w.deliverDue(ctx)
+	require.NoError(t, err)
+	require.Len(t, receiver.requests, 1)
+
+	// Attributes are in headers, pet is a body.
+	req, body := receiver.requests[0], receiver.bodies[0]
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	require.Equal(t, "1.0", req.Header.Get("ce-specversion"))
+	require.Equal(t, "com.example.pet.created", req.Header.Get("ce-type"))
+	require.Equal(t, cfg.DataSchema, req.Header.Get("ce-dataschema"))
+	require.JSONEq(t, `{"id":10,"name":"Tom"}`, string(body))
+	require.NoError(t, VerifyWebhook(req, body, "0123456789abcdef", time.Minute))
+	ce, err := ReadCloudEvent(req.Header, body)
+	require.NoError(t, err)
+	require.Equal(t, NewCloudEvent(ev, cfg), ce)
+}
+
+func TestWebhooksBackoff(t *testing.T) {
+	cfg := WebhooksConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	var got []time.Duration
+	for attempts := 1; attempts <= 6; attempts++ {
+		got = append(got, cfg.backoff(attempts))
+	}
+	require.Equal(t, []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}, got)
+}
+
+func TestHandlerWebhooks(t *testing.T) {
+	ctx := context.Background()
+	h := NewHandler(NewMemoryStorage())
+
+	for _, u := range []string{
+		"/hooks",
+		"ftp://example.com/hooks",
+		"https:///hooks",
+		// Denied by default policy.
+		"http://127.0.0.1:9090/hooks",
+		"http://[::1]/hooks",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		req := &oas.WebhookRequest{Events: []oas.WebhookEventType{oas.WebhookEventTypeCreated}, Secret: "0123456789abcdef"}
+		require.NoError(t, req.URL.UnmarshalBinary([]byte(u)))
+		_, err := h.CreateWebhook(ctx, req, oas.CreateWebhookParams{})
+		require.ErrorIs(t, err, ErrInvalidWebhook, u)
+	}
+
+	req := &oas.WebhookRequest{
+		Events:   []oas.WebhookEventType{oas.WebhookEventTypeStatusChanged},
+		Statuses: []oas.PetStatus{oas.PetStatusSold},
+		Secret:   "0123456789abcdef",
+	}
+	require.NoError(t, req.URL.UnmarshalBinary([]byte("https://example.com/hooks")))
+	wh, err := h.CreateWebhook(ctx, req, oas.CreateWebhookParams{})
+	require.NoError(t, err)
+	require.True(t, wh.Enabled)
+	require.Equal(t, "https://example.com/hooks", wh.URL.String())
+	require.Equal(t, req.Events, wh.Events)
+	require.Equal(t, req.Statuses, wh.Statuses)
+	require.Equal(t, oas.WebhookContentModeStructured, wh.ContentMode)
+
+	req.ContentMode = oas.NewOptWebhookContentMode(oas.WebhookContentModeBinary)
+	binary, err := h.CreateWebhook(ctx, req, oas.CreateWebhookParams{})
+	require.NoError(t, err)
+	require.Equal(t, oas.WebhookContentModeBinary, binary.ContentMode)
+	require.NoError(t, h.storage.DeleteWebhook(ctx, binary.ID))
+
+	_, err = h.storage.UpdateWebhook(ctx, wh.ID, func(wh *Webhook) error {
+		wh.Enabled = false
+		wh.Failures = 20
+		wh.DisabledReason = "20 consecutive failed attempts"
+		return nil
+	})
+	require.NoError(t, err)
+	got, err := h.GetWebhook(ctx, oas.GetWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.Equal(t, oas.NewOptString("20 consecutive failed attempts"), got.(*oas.Webhook).DisabledReason)
+
+	enabled, err := h.EnableWebhook(ctx, oas.EnableWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.Equal(t, wh, enabled)
+
+	list, err := h.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []oas.Webhook{*wh}, list)
+
+	deliveries, err := h.ListWebhookDeliveries(ctx, oas.ListWebhookDeliveriesParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.Empty(t, *deliveries.(*oas.ListWebhookDeliveriesOKApplicationJSON))
+
+	deleted, err := h.DeleteWebhook(ctx, oas.DeleteWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.IsType(t, &oas.DeleteWebhookOK{}, deleted)
+	deleted, err = h.DeleteWebhook(ctx, oas.DeleteWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.IsType(t, &oas.DeleteWebhookNotFound{}, deleted)
+	got, err = h.GetWebhook(ctx, oas.GetWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.IsType(t, &oas.GetWebhookNotFound{}, got)
+	enabledRes, err := h.EnableWebhook(ctx, oas.EnableWebhookParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.IsType(t, &oas.EnableWebhookNotFound{}, enabledRes)
+	deliveries, err = h.ListWebhookDeliveries(ctx, oas.ListWebhookDeliveriesParams{WebhookId: wh.ID})
+	require.NoError(t, err)
+	require.IsType(t, &oas.ListWebhookDeliveriesNotFound{}, deliveries)
+}
@@ -0,0 +1,426 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+// EventFilter selects pet change events, empty fields match any value.
+type EventFilter struct {
+	PetIDs   []int64         `json:"pet_ids,omitempty"`
+	Statuses []oas.PetStatus `json:"statuses,omitempty"`
+	Events   []EventType     `json:"events,omitempty"`
+}
+
+// Validate checks filter values.
+func (f EventFilter) Validate() error {
+	for _, s := range f.Statuses {
+		if err := s.Validate(); err != nil {
+			return errors.Wrap(err, "statuses")
+		}
+	}
+	for _, typ := range f.Events {
+		switch typ {
+		case EventCreated, EventUpdated, EventDeleted, EventStatusChanged:
+		default:
+			return errors.Errorf("events: unknown event type %q", typ)
+		}
+	}
+	return nil
+}
+
+// Match reports whether event matches filter.
+//
+// Deleted pet has no status, so its event does not match filter by status.
+func (f EventFilter) Match(ev Event) bool {
+	if len(f.PetIDs) > 0 && !slices.Contains(f.PetIDs, ev.PetID) {
+		return false
+	}
+	if len(f.Events) > 0 && !slices.Contains(f.Events, ev.Type) {
+		return false
+	}
+	if len(f.Statuses) > 0 {
+		status, ok := ev.Pet.Status.Get()
+		if ev.Type == EventDeleted || !ok || !slices.Contains(f.Statuses, status) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebSocketConfig configures WebSocket.
+type WebSocketConfig struct {
+	// PingInterval is an interval of pings, connection not answering ping
+	// in this interval is closed.
+	//
+	// Defaults to 30s.
+	PingInterval time.Duration
+	// MessageRate limits messages per second received from connection.
+	//
+	// Defaults to 10.
+	MessageRate float64
+	// MessageBurst is a burst of received messages.
+	//
+	// Defaults to 20.
+	MessageBurst int
+	// MaxSubscriptions limits subscriptions per connection.
+	//
+	// Defaults to 32.
+	MaxSubscriptions int
+	// Buffer is a number of events queued per connection. Connection with
+	// full queue is closed with 1013 status, so client retries later.
+	//
+	// Defaults to 64.
+	Buffer int
+	// CloudEvents configures sent events.
+	CloudEvents CloudEventsConfig
+}
+
+func (c *WebSocketConfig) setDefaults() {
+	if c.PingInterval <= 0 {
+		c.PingInterval = 30 * time.Second
+	}
+	if c.MessageRate <= 0 {
+		c.MessageRate = 10
+	}
+	if c.MessageBurst <= 0 {
+		c.MessageBurst = 20
+	}
+	if c.MaxSubscriptions <= 0 {
+		c.MaxSubscriptions = 32
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 64
+	}
+}
+
+// WebSocket serves subscriptions to pet change events over WebSocket.
+//
+// Client sends JSON messages:
+//
+//	{"type":"subscribe","id":"sold","filter":{"pet_ids":[1],"statuses":["sold"],"events":["status_changed"]}}
+//	{"type":"unsubscribe","id":"sold"}
+//
+// Server acknowledges them with "subscribed" and "unsubscribed" messages, or
+// replies with "error" message, and sends matching events as structured
+// CloudEvents:
+//
+//	{"type":"event","subscriptions":["sold"],"event":{"specversion":"1.0","id":"1","type":"com.example.pet.status_changed","subject":"1","data":{...},...}}
+type WebSocket struct {
+	events     *Events
+	cfg        WebSocketConfig
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	active   metric.Int64UpDownCounter
+	received metric.Int64Counter
+	sent     metric.Int64Counter
+}
+
+// NewWebSocket creates new WebSocket.
+func NewWebSocket(events *Events, m httpmiddleware.Metrics, cfg WebSocketConfig) (*WebSocket, error) {
+	cfg.setDefaults()
+	meter := m.MeterProvider().Meter("example/internal/api")
+	active, err := meter.Int64UpDownCounter("ws.connections.active",
+		metric.WithDescription("Number of open WebSocket connections"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create active counter")
+	}
+	received, err := meter.Int64Counter("ws.messages.received",
+		metric.WithDescription("Number of received messages by message type and result"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create received counter")
+	}
+	sent, err := meter.Int64Counter("ws.events.sent",
+		metric.WithDescription("Number of sent events by event type"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create sent counter")
+	}
+	return &WebSocket{
+		events:     events,
+		cfg:        cfg,
+		tracer:     m.TracerProvider().Tracer("example/internal/api"),
+		propagator: m.TextMapPropagator(),
+		active:     active,
+		received:   received,
+		sent:       sent,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *WebSocket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := s.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := s.tracer.Start(ctx, "api.petSubscriptions",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", "/pet/ws"),
+		),
+	)
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		// Accept writes error response.
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "accept")
+		span.End()
+		return
+	}
+	span.SetStatus(codes.Ok, "")
+	span.End()
+
+	s.serve(ctx, conn)
+}
+
+// wsRequest is a message from client.
+type wsRequest struct {
+	Type   string      `json:"type"`
+	ID     string      `json:"id"`
+	Filter EventFilter `json:"filter"`
+}
+
+// wsReplies maps client message type to reply type.
+var wsReplies = map[string]string{
+	"subscribe":   "subscribed",
+	"unsubscribe": "unsubscribed",
+}
+
+// wsConn is a state of WebSocket connection.
+type wsConn struct {
+	conn    *websocket.Conn
+	limiter *rate.Limiter
+
+	mux  sync.Mutex
+	subs map[string]EventFilter
+}
+
+// match returns sorted IDs of subscriptions matching event.
+func (c *wsConn) match(ev Event) []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var ids []string
+	for id, f := range c.subs {
+		if f.Match(ev) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// write writes JSON message.
+func (c *wsConn) write(ctx context.Context, timeout time.Duration, encode func(e *jx.Encoder)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	e.Obj(encode)
+	return c.conn.Write(ctx, websocket.MessageText, e.Bytes())
+}
+
+// reply writes response to client message.
+func (c *wsConn) reply(ctx context.Context, timeout time.Duration, typ, id, msg string) error {
+	return c.write(ctx, timeout, func(e *jx.Encoder) {
+		e.Field("type", func(e *jx.Encoder) { e.Str(typ) })
+		if id != "" {
+			e.Field("id", func(e *jx.Encoder) { e.Str(id) })
+		}
+		if msg != "" {
+			e.Field("error_message", func(e *jx.Encoder) { e.Str(msg) })
+		}
+	})
+}
+
+// serve handles connection until it is closed.
+func (s *WebSocket) serve(ctx context.Context, conn *websocket.Conn) {
+	start := time.Now()
+	ctx, span := s.tracer.Start(ctx, "api.petSubscriptions.connection")
+	defer span.End()
+	s.active.Add(ctx, 1)
+
+	var (
+		sent   int
+		reason string
+	)
+	defer func() {
+		s.active.Add(ctx, -1)
+		span.SetAttributes(
+			attribute.Int("ws.events_sent", sent),
+			attribute.String("ws.close_reason", reason),
+		)
+		zctx.From(ctx).Debug("WebSocket closed",
+			zap.String("reason", reason),
+			zap.Int("sent", sent),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}()
+
+	sub := s.events.Subscribe(0, s.cfg.Buffer)
+	defer sub.Close()
+
+	c := &wsConn{
+		conn:    conn,
+		limiter: rate.NewLimiter(rate.Limit(s.cfg.MessageRate), s.cfg.MessageBurst),
+		subs:    map[string]EventFilter{},
+	}
+	conn.SetReadLimit(4096)
+
+	// Reader also handles pongs and close frames.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	readErr := make(chan error, 1)
+	go func() {
+		defer cancel()
+		readErr <- s.read(ctx, c)
+	}()
+
+	ping := time.NewTicker(s.cfg.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			reason = closeClient
+			if err := <-readErr; err != nil && websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+				reason = closeError
+				span.RecordError(err)
+			}
+			_ = conn.CloseNow()
+			return
+		case <-ping.C:
+			pingCtx, pingCancel := context.WithTimeout(ctx, s.cfg.PingInterval)
+			err := conn.Ping(pingCtx)
+			pingCancel()
+			if err != nil {
+				reason = closePing
+				_ = conn.CloseNow()
+				return
+			}
+		case ev, ok := <-sub.C():
+			if !ok {
+				if errors.Is(sub.Err(), ErrSubscriberLagged) {
+					reason = closeLagged
+					_ = conn.Close(websocket.StatusTryAgainLater, "lagged behind events")
+				} else {
+					reason = closeShutdown
+					_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+				}
+				return
+			}
+			ids := c.match(ev)
+			if len(ids) == 0 {
+				continue
+			}
+			if err := c.write(ctx, s.cfg.PingInterval, func(e *jx.Encoder) {
+				e.Field("type", func(e *jx.Encoder) { e.Str("event") })
+				e.Field("subscriptions", func(e *jx.Encoder) {
+					e.Arr(func(e *jx.Encoder) {
+						for _, id := range ids {
+							e.Str(id)
+						}
+					})
+				})
+				e.Field("event", NewCloudEvent(ev, s.cfg.CloudEvents).Encode)
+			}); err != nil {
+				reason = closeError
+				span.RecordError(err)
+				_ = conn.CloseNow()
+				return
+			}
+			sent++
+			s.sent.Add(ctx, 1, metric.WithAttributes(attribute.String("event.type", string(ev.Type))))
+		}
+	}
+}
+
+// read handles client messages until connection is closed.
+func (s *WebSocket) read(ctx context.Context, c *wsConn) error {
+	for {
+		typ, data, err := c.conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if typ != websocket.MessageText {
+			_ = c.conn.Close(websocket.StatusUnsupportedData, "text messages expected")
+			return nil
+		}
+		var req wsRequest
+		result, msg := "ok", ""
+		switch {
+		case json.Unmarshal(data, &req) != nil:
+			result, msg = "invalid", "invalid message"
+		case !c.limiter.Allow():
+			result, msg = "rate_limited", "rate limit exceeded"
+		default:
+			if err := s.handle(c, req); err != nil {
+				result, msg = "invalid", err.Error()
+			}
+		}
+		replyType, ok := wsReplies[req.Type]
+		if !ok {
+			// Do not use client input as metric attribute.
+			req.Type = "unknown"
+		}
+		s.received.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("ws.message.type", req.Type),
+			attribute.String("ws.message.result", result),
+		))
+
+		if msg != "" {
+			replyType = "error"
+		}
+		if err := c.reply(ctx, s.cfg.PingInterval, replyType, req.ID, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle applies client message.
+func (s *WebSocket) handle(c *wsConn, req wsRequest) error {
+	if req.ID == "" {
+		return errors.New("id: must be set")
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	switch req.Type {
+	case "subscribe":
+		if err := req.Filter.Validate(); err != nil {
+			return errors.Wrap(err, "filter")
+		}
+		if _, ok := c.subs[req.ID]; !ok && len(c.subs) >= s.cfg.MaxSubscriptions {
+			return errors.Errorf("too many subscriptions, limit is %d", s.cfg.MaxSubscriptions)
+		}
+		// Subscribing with the same ID replaces filter.
+		c.subs[req.ID] = req.Filter
+	case "unsubscribe":
+		if _, ok := c.subs[req.ID]; !ok {
+			return errors.Errorf("unknown subscription %q", req.ID)
+		}
+		delete(c.subs, req.ID)
+	default:
+		return errors.Errorf("unknown message type %q", req.Type)
+	}
+	return nil
+}
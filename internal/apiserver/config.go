@@ -0,0 +1,644 @@
+// Package apiserver assembles api-server handler and its configuration.
+package apiserver
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-faster/errors"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+
+	"example/internal/api"
+	"example/internal/httpmiddleware"
+	"example/internal/httprecord"
+)
+
+// EnvPrefix is prefix of environment variables overriding config.
+const EnvPrefix = "API_"
+
+// Config is api-server configuration.
+//
+// Values are loaded from defaults, config file, environment and flags,
+// each source overriding the previous one.
+type Config struct {
+	Listen      ListenConfig      `yaml:"listen" toml:"listen"`
+	Timeouts    TimeoutsConfig    `yaml:"timeouts" toml:"timeouts"`
+	Storage     StorageConfig     `yaml:"storage" toml:"storage"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Middleware  MiddlewareConfig  `yaml:"middleware" toml:"middleware"`
+	Record      RecordConfig      `yaml:"record" toml:"record"`
+	Shadow      ShadowConfig      `yaml:"shadow" toml:"shadow"`
+	Validation  ValidationConfig  `yaml:"validation" toml:"validation"`
+	Chaos       ChaosConfig       `yaml:"chaos" toml:"chaos"`
+	Events      EventsConfig      `yaml:"events" toml:"events"`
+	WebSocket   WebSocketConfig   `yaml:"websocket" toml:"websocket"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" toml:"webhooks"`
+	Outbox      OutboxConfig      `yaml:"outbox" toml:"outbox"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+}
+
+// ListenConfig configures listeners.
+type ListenConfig struct {
+	Addr      string `yaml:"addr" toml:"addr" usage:"listen address"`
+	AdminAddr string `yaml:"admin_addr" toml:"admin_addr" usage:"admin listen address with pprof and runtime controls, empty disables it"`
+}
+
+// TimeoutsConfig configures HTTP server timeouts.
+type TimeoutsConfig struct {
+	ReadHeader time.Duration `yaml:"read_header" toml:"read_header" usage:"read header timeout"`
+	Read       time.Duration `yaml:"read" toml:"read" usage:"read timeout, zero means no timeout"`
+	Write      time.Duration `yaml:"write" toml:"write" usage:"write timeout, zero means no timeout"`
+	Idle       time.Duration `yaml:"idle" toml:"idle" usage:"keep-alive idle timeout"`
+	Shutdown   time.Duration `yaml:"shutdown" toml:"shutdown" usage:"graceful shutdown timeout"`
+}
+
+// StorageConfig configures pet storage.
+type StorageConfig struct {
+	Driver string `yaml:"driver" toml:"driver" usage:"storage driver (memory, bolt)"`
+	Path   string `yaml:"path" toml:"path" usage:"database path for bolt driver"`
+}
+
+// AuthConfig configures API key authentication.
+type AuthConfig struct {
+	Enabled bool     `yaml:"enabled" toml:"enabled" usage:"require API key"`
+	Header  string   `yaml:"header" toml:"header" usage:"API key header"`
+	Keys    []string `yaml:"keys" toml:"keys" usage:"comma-separated list of API keys" secret:"true"`
+}
+
+// RateLimitConfig configures global rate limit.
+type RateLimitConfig struct {
+	Enabled bool    `yaml:"enabled" toml:"enabled" usage:"enable rate limiting"`
+	RPS     float64 `yaml:"rps" toml:"rps" usage:"allowed requests per second"`
+	Burst   int     `yaml:"burst" toml:"burst" usage:"maximum burst size"`
+}
+
+// IdempotencyConfig configures handling of Idempotency-Key header.
+type IdempotencyConfig struct {
+	Enabled bool          `yaml:"enabled" toml:"enabled" usage:"replay responses of requests with the same Idempotency-Key"`
+	TTL     time.Duration `yaml:"ttl" toml:"ttl" usage:"how long responses are kept for replay"`
+	// MaxBodySize limits body of requests with Idempotency-Key, which is
+	// read to memory.
+	MaxBodySize int `yaml:"max_body_size" toml:"max_body_size" usage:"maximum body size of requests with Idempotency-Key in bytes, larger get 413"`
+	MaxBytes    int `yaml:"max_bytes" toml:"max_bytes" usage:"maximum size of stored responses in bytes, the oldest are evicted"`
+}
+
+// MiddlewareConfig toggles optional middlewares.
+type MiddlewareConfig struct {
+	LogRequests bool `yaml:"log_requests" toml:"log_requests" usage:"log incoming requests"`
+	Labeler     bool `yaml:"labeler" toml:"labeler" usage:"add http.route to spans and metrics"`
+}
+
+// RecordConfig configures recording of served requests.
+type RecordConfig struct {
+	Path         string   `yaml:"path" toml:"path" usage:"record requests to JSON lines file, empty disables recording"`
+	RedactFields []string `yaml:"redact_fields" toml:"redact_fields" usage:"comma-separated list of JSON fields redacted in recorded bodies"`
+	// MaxBodySize limits body of recorded requests, which is read to memory.
+	MaxBodySize int `yaml:"max_body_size" toml:"max_body_size" usage:"maximum body size of recorded requests in bytes, larger get 413"`
+}
+
+// ShadowConfig configures mirroring of requests to candidate server.
+type ShadowConfig struct {
+	Target       string   `yaml:"target" toml:"target" usage:"base URL of candidate server, empty disables shadowing"`
+	SampleRate   float64  `yaml:"sample_rate" toml:"sample_rate" usage:"fraction of requests mirrored to candidate"`
+	Operations   []string `yaml:"operations" toml:"operations" usage:"comma-separated per-operation sample rates, e.g. getPetById=1,addPet=0.1"`
+	Writes       bool     `yaml:"writes" toml:"writes" usage:"mirror requests other than GET and HEAD"`
+	IgnoreFields []string `yaml:"ignore_fields" toml:"ignore_fields" usage:"comma-separated list of JSON fields ignored on comparison"`
+	MaxBodySize  int      `yaml:"max_body_size" toml:"max_body_size" usage:"maximum body size of mirrored requests in bytes, larger are not mirrored"`
+}
+
+// OperationRates parses per-operation sample rates.
+func (c ShadowConfig) OperationRates() (map[string]float64, error) {
+	rates := make(map[string]float64, len(c.Operations))
+	for _, op := range c.Operations {
+		id, v, ok := strings.Cut(op, "=")
+		if !ok || id == "" {
+			return nil, errors.Errorf("invalid operation rate %q", op)
+		}
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, errors.Errorf("invalid sample rate of %q: must be in [0, 1]", id)
+		}
+		rates[id] = rate
+	}
+	return rates, nil
+}
+
+// ValidationConfig configures validation of responses against OpenAPI spec.
+type ValidationConfig struct {
+	Responses bool `yaml:"responses" toml:"responses" usage:"validate responses against OpenAPI spec, logging and counting violations"`
+	Strict    bool `yaml:"strict" toml:"strict" usage:"replace responses violating OpenAPI spec with 500"`
+}
+
+// ChaosConfig configures fault injection.
+type ChaosConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" usage:"allow fault injection controlled through admin /chaos endpoint, never enable in production"`
+}
+
+// EventsConfig configures stream of pet change events.
+type EventsConfig struct {
+	History          int           `yaml:"history" toml:"history" usage:"number of last events kept for resumption with Last-Event-ID"`
+	SubscriberBuffer int           `yaml:"subscriber_buffer" toml:"subscriber_buffer" usage:"events queued per subscriber before it is disconnected"`
+	Heartbeat        time.Duration `yaml:"heartbeat" toml:"heartbeat" usage:"interval of heartbeats on idle event stream"`
+	PublicURL        string        `yaml:"public_url" toml:"public_url" usage:"external URL of server, base of CloudEvents source and dataschema"`
+}
+
+// WebSocketConfig configures WebSocket subscriptions to pet change events.
+type WebSocketConfig struct {
+	PingInterval     time.Duration `yaml:"ping_interval" toml:"ping_interval" usage:"interval of pings, connection not answering in this interval is closed"`
+	MessageRate      float64       `yaml:"message_rate" toml:"message_rate" usage:"allowed client messages per second per connection"`
+	MessageBurst     int           `yaml:"message_burst" toml:"message_burst" usage:"maximum burst of client messages per connection"`
+	MaxSubscriptions int           `yaml:"max_subscriptions" toml:"max_subscriptions" usage:"maximum subscriptions per connection"`
+}
+
+// WebhooksConfig configures delivery of pet change events to webhooks.
+type WebhooksConfig struct {
+	Timeout      time.Duration `yaml:"timeout" toml:"timeout" usage:"timeout of webhook request"`
+	MaxAttempts  int           `yaml:"max_attempts" toml:"max_attempts" usage:"attempts before delivery is marked failed"`
+	MinBackoff   time.Duration `yaml:"min_backoff" toml:"min_backoff" usage:"delay before the second attempt, doubled for every next one"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" toml:"max_backoff" usage:"maximum delay between attempts"`
+	DisableAfter int           `yaml:"disable_after" toml:"disable_after" usage:"consecutive failed attempts disabling webhook"`
+	Retention    time.Duration `yaml:"retention" toml:"retention" usage:"how long succeeded and failed deliveries are kept"`
+	// Loopback, private and link-local networks are denied by default.
+	AllowNetworks []string `yaml:"allow_networks" toml:"allow_networks" usage:"comma-separated list of CIDRs webhooks may be delivered to, taking precedence over denied ones"`
+	DenyNetworks  []string `yaml:"deny_networks" toml:"deny_networks" usage:"comma-separated list of CIDRs webhooks may not be delivered to, in addition to loopback, private and link-local ones"`
+}
+
+// Policy parses policy of webhook addresses.
+func (c WebhooksConfig) Policy() (p api.WebhookPolicy, _ error) {
+	for _, list := range []struct {
+		name     string
+		cidrs    []string
+		prefixes *[]netip.Prefix
+	}{
+		{"allow_networks", c.AllowNetworks, &p.Allow},
+		{"deny_networks", c.DenyNetworks, &p.Deny},
+	} {
+		for _, cidr := range list.cidrs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return p, errors.Wrap(err, list.name)
+			}
+			*list.prefixes = append(*list.prefixes, prefix.Masked())
+		}
+	}
+	return p, nil
+}
+
+// OutboxConfig configures relay publishing pet change events from storage outbox.
+type OutboxConfig struct {
+	Interval time.Duration `yaml:"interval" toml:"interval" usage:"interval of checking outbox for unpublished events"`
+	Batch    int           `yaml:"batch" toml:"batch" usage:"number of events read from outbox at once"`
+	File     string        `yaml:"file" toml:"file" usage:"path of JSON lines file events are also appended to, empty disables it"`
+}
+
+// LogConfig configures logging.
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level" usage:"log level, empty means OTEL_LOG_LEVEL or info"`
+}
+
+// DefaultConfig returns default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Listen: ListenConfig{
+			Addr: "0.0.0.0:8080",
+		},
+		Timeouts: TimeoutsConfig{
+			ReadHeader: time.Second,
+			Idle:       time.Minute,
+			Shutdown:   15 * time.Second,
+		},
+		Storage: StorageConfig{
+			Driver: "memory",
+		},
+		Auth: AuthConfig{
+			Header: "X-API-Key",
+		},
+		RateLimit: RateLimitConfig{
+			RPS:   100,
+			Burst: 100,
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled:     true,
+			TTL:         24 * time.Hour,
+			MaxBodySize: httpmiddleware.DefaultIdempotencyMaxBodySize,
+			MaxBytes:    httpmiddleware.DefaultIdempotencyMaxBytes,
+		},
+		Shadow: ShadowConfig{
+			MaxBodySize: httpmiddleware.DefaultShadowMaxBodySize,
+		},
+		Record: RecordConfig{
+			MaxBodySize: httprecord.DefaultMaxBodySize,
+		},
+		Middleware: MiddlewareConfig{
+			LogRequests: true,
+			Labeler:     true,
+		},
+		Events: EventsConfig{
+			History:          1024,
+			SubscriberBuffer: 64,
+			Heartbeat:        15 * time.Second,
+			PublicURL:        "http://localhost:8080",
+		},
+		WebSocket: WebSocketConfig{
+			PingInterval:     30 * time.Second,
+			MessageRate:      10,
+			MessageBurst:     20,
+			MaxSubscriptions: 32,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			MinBackoff:   time.Second,
+			MaxBackoff:   time.Hour,
+			DisableAfter: 20,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Outbox: OutboxConfig{
+			Interval: time.Second,
+			Batch:    100,
+		},
+	}
+}
+
+// Validate checks configuration.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, errors.Errorf(format, args...))
+		}
+	}
+	check(c.Listen.Addr != "", "listen.addr: must be set")
+	for _, t := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read_header", c.Timeouts.ReadHeader},
+		{"read", c.Timeouts.Read},
+		{"write", c.Timeouts.Write},
+		{"idle", c.Timeouts.Idle},
+		{"shutdown", c.Timeouts.Shutdown},
+	} {
+		check(t.value >= 0, "timeouts.%s: must not be negative", t.name)
+	}
+	switch c.Storage.Driver {
+	case "memory":
+	case "bolt":
+		check(c.Storage.Path != "", "storage.path: must be set for bolt driver")
+	default:
+		check(false, "storage.driver: unknown driver %q", c.Storage.Driver)
+	}
+	if c.Auth.Enabled {
+		check(c.Auth.Header != "", "auth.header: must be set")
+		check(len(c.Auth.Keys) > 0, "auth.keys: at least one key required")
+		for i, key := range c.Auth.Keys {
+			check(key != "", "auth.keys[%d]: must not be empty", i)
+		}
+	}
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.RPS > 0, "rate_limit.rps: must be positive")
+		check(c.RateLimit.Burst > 0, "rate_limit.burst: must be positive")
+	}
+	if c.Idempotency.Enabled {
+		check(c.Idempotency.TTL > 0, "idempotency.ttl: must be positive")
+		check(c.Idempotency.MaxBodySize > 0, "idempotency.max_body_size: must be positive")
+		check(c.Idempotency.MaxBytes > 0, "idempotency.max_bytes: must be positive")
+	}
+	if c.Shadow.Target != "" {
+		u, err := url.Parse(c.Shadow.Target)
+		check(err == nil && u.IsAbs() && u.Host != "", "shadow.target: must be absolute URL")
+		check(c.Shadow.SampleRate >= 0 && c.Shadow.SampleRate <= 1, "shadow.sample_rate: must be in [0, 1]")
+		_, err = c.Shadow.OperationRates()
+		check(err == nil, "shadow.operations: %v", err)
+		check(c.Shadow.MaxBodySize > 0, "shadow.max_body_size: must be positive")
+	}
+	check(c.Events.History > 0, "events.history: must be positive")
+	check(c.Events.SubscriberBuffer > 0, "events.subscriber_buffer: must be positive")
+	check(c.Events.Heartbeat > 0, "events.heartbeat: must be positive")
+	publicURL, err := url.Parse(c.Events.PublicURL)
+	check(err == nil && publicURL.IsAbs() && publicURL.Host != "", "events.public_url: must be absolute URL")
+	check(c.WebSocket.PingInterval > 0, "websocket.ping_interval: must be positive")
+	check(c.WebSocket.MessageRate > 0, "websocket.message_rate: must be positive")
+	check(c.WebSocket.MessageBurst > 0, "websocket.message_burst: must be positive")
+	check(c.WebSocket.MaxSubscriptions > 0, "websocket.max_subscriptions: must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout: must be positive")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts: must be positive")
+	check(c.Webhooks.MinBackoff > 0, "webhooks.min_backoff: must be positive")
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.MinBackoff, "webhooks.max_backoff: must not be less than min_backoff")
+	check(c.Webhooks.DisableAfter > 0, "webhooks.disable_after: must be positive")
+	check(c.Webhooks.Retention > 0, "webhooks.retention: must be positive")
+	if _, err := c.Webhooks.Policy(); err != nil {
+		check(false, "webhooks.%v", err)
+	}
+	check(c.Outbox.Interval > 0, "outbox.interval: must be positive")
+	check(c.Outbox.Batch > 0, "outbox.batch: must be positive")
+	check(!c.Validation.Strict || c.Validation.Responses, "validation.strict: requires validation.responses")
+	// HAR is kept in memory until close.
+	check(!httprecord.IsHAR(c.Record.Path), "record.path: HAR is not supported, record JSON lines and convert them")
+	if c.Record.Path != "" {
+		check(c.Record.MaxBodySize > 0, "record.max_body_size: must be positive")
+	}
+	if c.Log.Level != "" {
+		_, err := zapcore.ParseLevel(c.Log.Level)
+		check(err == nil, "log.level: unknown level %q", c.Log.Level)
+	}
+	return errors.Join(errs...)
+}
+
+// diffConfig returns description of changed fields, with secrets redacted.
+func diffConfig(old, new Config) []string {
+	var (
+		oldFields = configFields(&old)
+		newFields = configFields(&new)
+		changes   []string
+	)
+	for i, f := range newFields {
+		prev := oldFields[i]
+		if reflect.DeepEqual(prev.Value.Interface(), f.Value.Interface()) {
+			continue
+		}
+		if f.Secret {
+			changes = append(changes, f.FlagName()+": changed")
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %q -> %q", f.FlagName(), prev.String(), f.String()))
+	}
+	return changes
+}
+
+// configField is a leaf of Config.
+type configField struct {
+	Path   []string
+	Value  reflect.Value
+	Usage  string
+	Secret bool
+}
+
+// FlagName returns flag name of field, like "rate_limit.rps".
+func (f configField) FlagName() string {
+	return strings.Join(f.Path, ".")
+}
+
+// EnvName returns environment variable name of field, like "API_RATE_LIMIT_RPS".
+func (f configField) EnvName() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(f.Path, "_"))
+}
+
+// Set parses s and sets field value.
+func (f configField) Set(s string) error {
+	v := f.Value
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case []string:
+		var list []string
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// String returns field value in the same format as accepted by Set.
+func (f configField) String() string {
+	switch v := f.Value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// configFields returns all leaf fields of c in declaration order.
+func configFields(c *Config) []configField {
+	var fields []configField
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+			fieldPath := append(append([]string(nil), path...), name)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), fieldPath)
+				continue
+			}
+			fields = append(fields, configField{
+				Path:   fieldPath,
+				Value:  v.Field(i),
+				Usage:  sf.Tag.Get("usage"),
+				Secret: sf.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), nil)
+	return fields
+}
+
+// decodeConfigFile decodes YAML or TOML file into c, depending on extension.
+func decodeConfigFile(name string, c *Config) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	switch ext := filepath.Ext(name); ext {
+	case ".yml", ".yaml":
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		if err := d.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return errors.Wrap(err, "decode yaml")
+		}
+		return nil
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return errors.Wrap(err, "decode toml")
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return errors.Errorf("decode toml: unknown field %q", undecoded[0].String())
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported config extension %q", ext)
+	}
+}
+
+// ConfigLoader loads Config from all sources.
+//
+// Loader remembers config file path and flag overrides, so Load
+// can be called again to reload configuration.
+type ConfigLoader struct {
+	// Path is YAML or TOML config file path, empty means no file.
+	Path string
+	// LookupEnv looks up environment variable.
+	//
+	// Defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+
+	// flags are explicitly set flag values, by flag name.
+	flags map[string]string
+}
+
+// Load loads and validates configuration.
+func (l *ConfigLoader) Load() (Config, error) {
+	cfg := DefaultConfig()
+	if l.Path != "" {
+		if err := decodeConfigFile(l.Path, &cfg); err != nil {
+			return cfg, errors.Wrapf(err, "config file %q", l.Path)
+		}
+	}
+	lookupEnv := l.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	for _, f := range configFields(&cfg) {
+		if s, ok := lookupEnv(f.EnvName()); ok {
+			if err := f.Set(s); err != nil {
+				return cfg, errors.Wrapf(err, "env %s", f.EnvName())
+			}
+		}
+	}
+	for _, f := range configFields(&cfg) {
+		if s, ok := l.flags[f.FlagName()]; ok {
+			if err := f.Set(s); err != nil {
+				return cfg, errors.Wrapf(err, "flag -%s", f.FlagName())
+			}
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, errors.Wrap(err, "invalid config")
+	}
+	return cfg, nil
+}
+
+// flagRecorder is flag.Value that records explicitly set values.
+type flagRecorder struct {
+	name  string
+	flags map[string]string
+	bool  bool
+}
+
+func (r *flagRecorder) String() string { return "" }
+
+func (r *flagRecorder) IsBoolFlag() bool { return r.bool }
+
+func (r *flagRecorder) Set(s string) error {
+	r.flags[r.name] = s
+	return nil
+}
+
+// RegisterFlags registers flag for every Config field.
+//
+// Values are recorded to l and applied on Load.
+func (l *ConfigLoader) RegisterFlags(fs *flag.FlagSet) {
+	if l.flags == nil {
+		l.flags = map[string]string{}
+	}
+	defaults := DefaultConfig()
+	for _, f := range configFields(&defaults) {
+		r := &flagRecorder{
+			name:  f.FlagName(),
+			flags: l.flags,
+			bool:  f.Value.Kind() == reflect.Bool,
+		}
+		usage := fmt.Sprintf("%s (env %s)", f.Usage, f.EnvName())
+		fs.Var(r, r.name, usage)
+		if !f.Value.IsZero() && !f.Secret {
+			fs.Lookup(r.name).DefValue = f.String()
+		}
+	}
+	// Short aliases, "-addr" is kept for compatibility.
+	fs.Var(&flagRecorder{name: "listen.addr", flags: l.flags}, "addr", "alias for -listen.addr")
+	fs.Var(&flagRecorder{name: "listen.admin_addr", flags: l.flags}, "admin-addr", "alias for -listen.admin_addr")
+}
+
+// MarshalConfig encodes c to YAML, replacing secrets.
+func MarshalConfig(c Config) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range configFields(&c) {
+		node := root
+		for _, name := range f.Path[:len(f.Path)-1] {
+			node = yamlChild(node, name)
+		}
+		var value yaml.Node
+		switch {
+		case f.Secret && !f.Value.IsZero():
+			value = yaml.Node{Kind: yaml.ScalarNode, Value: "REDACTED"}
+		case f.Value.Type() == reflect.TypeOf(time.Duration(0)):
+			value = yaml.Node{Kind: yaml.ScalarNode, Value: f.String()}
+		default:
+			if err := value.Encode(f.Value.Interface()); err != nil {
+				return nil, errors.Wrap(err, f.FlagName())
+			}
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: f.Path[len(f.Path)-1]},
+			&value,
+		)
+	}
+	return yaml.Marshal(root)
+}
+
+// yamlChild returns mapping node with given key, creating it if needed.
+func yamlChild(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		child,
+	)
+	return child
+}
@@ -0,0 +1,110 @@
+package apiserver
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+listen:
+  addr: "file:8080"
+timeouts:
+  shutdown: 5s
+auth:
+  enabled: true
+  keys: [from-file]
+rate_limit:
+  rps: 10
+`), 0o600))
+
+	l := ConfigLoader{
+		Path: file,
+		LookupEnv: func(key string) (string, bool) {
+			v, ok := map[string]string{
+				"API_LISTEN_ADDR":    "env:8080",
+				"API_RATE_LIMIT_RPS": "20",
+			}[key]
+			return v, ok
+		},
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l.RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"-addr", "flag:8080", "-middleware.labeler=false"}))
+
+	cfg, err := l.Load()
+	require.NoError(t, err)
+	require.Equal(t, "flag:8080", cfg.Listen.Addr)
+	require.Equal(t, 5*time.Second, cfg.Timeouts.Shutdown)
+	require.Equal(t, time.Second, cfg.Timeouts.ReadHeader)
+	require.Equal(t, []string{"from-file"}, cfg.Auth.Keys)
+	require.Equal(t, 20.0, cfg.RateLimit.RPS)
+	require.False(t, cfg.Middleware.Labeler)
+	require.True(t, cfg.Middleware.LogRequests)
+
+	data, err := MarshalConfig(cfg)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "keys: REDACTED")
+	require.NotContains(t, string(data), "from-file")
+}
+
+func TestConfigLoaderTOML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+[storage]
+driver = "bolt"
+path = "pets.db"
+`), 0o600))
+
+	l := ConfigLoader{Path: file, LookupEnv: func(string) (string, bool) { return "", false }}
+	cfg, err := l.Load()
+	require.NoError(t, err)
+	require.Equal(t, StorageConfig{Driver: "bolt", Path: "pets.db"}, cfg.Storage)
+
+	require.NoError(t, os.WriteFile(file, []byte("[storage]\nunknown = 1\n"), 0o600))
+	_, err = l.Load()
+	require.ErrorContains(t, err, "unknown")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Storage.Driver = "redis"
+	cfg.Auth.Enabled = true
+	cfg.Timeouts.Idle = -time.Second
+	cfg.Shadow.Target = "http://candidate:8080"
+	cfg.Shadow.Operations = []string{"getPetById=2"}
+	cfg.Shadow.MaxBodySize = -1
+	cfg.Validation.Strict = true
+	cfg.Events.History = 0
+	cfg.Events.PublicURL = "/api"
+	cfg.WebSocket.MessageRate = 0
+	cfg.Webhooks.MaxBackoff = time.Millisecond
+	cfg.Outbox.Batch = 0
+	cfg.Record.Path = "requests.har"
+	cfg.Record.MaxBodySize = 0
+	cfg.Webhooks.DenyNetworks = []string{"10.0.0.1"}
+	err := cfg.Validate()
+	require.ErrorContains(t, err, "storage.driver")
+	require.ErrorContains(t, err, "auth.keys")
+	require.ErrorContains(t, err, "timeouts.idle")
+	require.ErrorContains(t, err, "shadow.operations")
+	require.ErrorContains(t, err, "shadow.max_body_size")
+	require.ErrorContains(t, err, "validation.strict")
+	require.ErrorContains(t, err, "events.history")
+	require.ErrorContains(t, err, "events.public_url")
+	require.ErrorContains(t, err, "websocket.message_rate")
+	require.ErrorContains(t, err, "webhooks.max_backoff")
+	require.ErrorContains(t, err, "outbox.batch")
+	require.ErrorContains(t, err, "record.path")
+	require.ErrorContains(t, err, "record.max_body_size")
+	require.ErrorContains(t, err, "webhooks.deny_networks")
+}
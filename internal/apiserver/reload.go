@@ -0,0 +1,223 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+
+	"example/internal/httpmiddleware"
+)
+
+// reloadDebounce is delay between config file change and reload,
+// so editors writing file in several steps trigger a single reload.
+const reloadDebounce = 100 * time.Millisecond
+
+// reloader applies runtime configuration changes without restart.
+//
+// Only log level, middleware toggles, auth, rate limit and idempotency
+// settings are applied on reload, other changes require restart.
+type reloader struct {
+	loader  *ConfigLoader
+	lg      *zap.Logger
+	tracer  trace.Tracer
+	level   zap.AtomicLevel
+	find    httpmiddleware.RouteFinder
+	chain   *httpmiddleware.Reloadable
+	limiter *rate.Limiter
+	// idempotency keeps stored responses across reloads.
+	idempotency *httpmiddleware.IdempotencyCache
+
+	mux     sync.Mutex
+	current Config
+}
+
+func newReloader(
+	loader *ConfigLoader,
+	cfg Config,
+	lg *zap.Logger,
+	tracer trace.Tracer,
+	level zap.AtomicLevel,
+	find httpmiddleware.RouteFinder,
+) *reloader {
+	r := &reloader{
+		loader:  loader,
+		lg:      lg,
+		tracer:  tracer,
+		level:   level,
+		find:    find,
+		chain:   httpmiddleware.NewReloadable(),
+		limiter: rate.NewLimiter(rate.Limit(cfg.RateLimit.RPS), cfg.RateLimit.Burst),
+		current: cfg,
+
+		idempotency: httpmiddleware.NewIdempotencyCache(cfg.Idempotency.TTL),
+	}
+	r.apply(cfg)
+	return r
+}
+
+// Middleware returns reloadable part of middleware chain.
+func (r *reloader) Middleware() httpmiddleware.Middleware {
+	return r.chain.Middleware
+}
+
+// apply applies reloadable settings of cfg.
+func (r *reloader) apply(cfg Config) {
+	if cfg.Log.Level != "" {
+		// Already validated.
+		lvl, _ := zapcore.ParseLevel(cfg.Log.Level)
+		r.level.SetLevel(lvl)
+	}
+	r.limiter.SetLimit(rate.Limit(cfg.RateLimit.RPS))
+	r.limiter.SetBurst(cfg.RateLimit.Burst)
+	r.idempotency.SetTTL(cfg.Idempotency.TTL)
+	r.idempotency.SetMaxBodySize(int64(cfg.Idempotency.MaxBodySize))
+	r.idempotency.SetMaxBytes(int64(cfg.Idempotency.MaxBytes))
+
+	var middlewares []httpmiddleware.Middleware
+	if cfg.Middleware.LogRequests {
+		middlewares = append(middlewares, httpmiddleware.LogRequests(r.find))
+	}
+	if cfg.Middleware.Labeler {
+		middlewares = append(middlewares, httpmiddleware.Labeler(r.find))
+	}
+	if cfg.Auth.Enabled {
+		middlewares = append(middlewares, httpmiddleware.APIKeyAuth(cfg.Auth.Header, cfg.Auth.Keys))
+	}
+	if cfg.RateLimit.Enabled {
+		middlewares = append(middlewares, httpmiddleware.RateLimit(r.limiter))
+	}
+	if cfg.Idempotency.Enabled {
+		// After auth, so keys are scoped to caller.
+		middlewares = append(middlewares, r.idempotency.Middleware)
+	}
+	r.chain.Store(middlewares...)
+}
+
+// Reload loads and applies configuration.
+//
+// On error, previous configuration stays active.
+func (r *reloader) Reload(ctx context.Context, reason string) error {
+	ctx, span := r.tracer.Start(ctx, "config.Reload",
+		trace.WithAttributes(attribute.String("config.reload.reason", reason)),
+	)
+	defer span.End()
+
+	lg := r.lg.With(zap.String("reason", reason))
+	cfg, err := r.loader.Load()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "load failed")
+		lg.Error("Config reload failed, keeping previous config", zap.Error(err))
+		return errors.Wrap(err, "load")
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	// Keep settings that can't be changed at runtime.
+	next := cfg
+	next.Listen = r.current.Listen
+	next.Timeouts = r.current.Timeouts
+	next.Storage = r.current.Storage
+	next.Record = r.current.Record
+	next.Shadow = r.current.Shadow
+	next.Validation = r.current.Validation
+	next.Chaos = r.current.Chaos
+	next.Events = r.current.Events
+	next.WebSocket = r.current.WebSocket
+	next.Webhooks = r.current.Webhooks
+	next.Outbox = r.current.Outbox
+
+	var (
+		changes = diffConfig(r.current, next)
+		ignored = diffConfig(next, cfg)
+	)
+	if len(ignored) > 0 {
+		span.AddEvent("config.ignored", trace.WithAttributes(
+			attribute.StringSlice("config.changes", ignored),
+		))
+		lg.Warn("Config changes require restart", zap.Strings("changes", ignored))
+	}
+	if len(changes) == 0 {
+		lg.Info("Config reloaded without changes")
+		return nil
+	}
+
+	r.apply(next)
+	r.current = next
+
+	span.AddEvent("config.changed", trace.WithAttributes(
+		attribute.StringSlice("config.changes", changes),
+	))
+	lg.Info("Config reloaded", zap.Strings("changes", changes))
+	return nil
+}
+
+// Run reloads configuration on SIGHUP or config file change until ctx is done.
+func (r *reloader) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+	)
+	if path := r.loader.Path; path != "" {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return errors.Wrap(err, "create watcher")
+		}
+		defer func() { _ = w.Close() }()
+
+		// Watching directory to handle editors and Kubernetes replacing the file.
+		if err := w.Add(filepath.Dir(path)); err != nil {
+			return errors.Wrap(err, "watch")
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			_ = r.Reload(ctx, "signal")
+		case e := <-events:
+			if !r.isConfigEvent(e) {
+				continue
+			}
+			debounce.Reset(reloadDebounce)
+		case <-debounce.C:
+			_ = r.Reload(ctx, "file")
+		case err := <-errs:
+			r.lg.Warn("Config watcher error", zap.Error(err))
+		}
+	}
+}
+
+func (r *reloader) isConfigEvent(e fsnotify.Event) bool {
+	if !e.Has(fsnotify.Write) && !e.Has(fsnotify.Create) {
+		return false
+	}
+	name := filepath.Clean(e.Name)
+	// Kubernetes ConfigMap volumes atomically swap "..data" symlink.
+	return name == filepath.Clean(r.loader.Path) || filepath.Base(name) == "..data"
+}
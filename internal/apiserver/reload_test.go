@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"example/internal/httpmiddleware"
+)
+
+func TestReloader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yml")
+	write := func(s string) {
+		require.NoError(t, os.WriteFile(file, []byte(s), 0o600))
+	}
+	write("auth: {enabled: true, keys: [old]}\n")
+
+	loader := &ConfigLoader{Path: file, LookupEnv: func(string) (string, bool) { return "", false }}
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	r := newReloader(loader, cfg,
+		zap.New(core),
+		noop.NewTracerProvider().Tracer("test"),
+		level,
+		httpmiddleware.MakeRouteFinder(&testServer{}),
+	)
+	h := httpmiddleware.Wrap(http.NotFoundHandler(), r.Middleware())
+	status := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/pet/1", nil)
+		req.Header.Set("X-API-Key", key)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		return rw.Code
+	}
+	require.Equal(t, http.StatusNotFound, status("old"))
+	require.Equal(t, http.StatusUnauthorized, status("new"))
+
+	ctx := context.Background()
+	write("auth: {enabled: true, keys: [new]}\nlog: {level: debug}\nlisten: {addr: ':1'}\n")
+	require.NoError(t, r.Reload(ctx, "test"))
+	require.Equal(t, http.StatusUnauthorized, status("old"))
+	require.Equal(t, http.StatusNotFound, status("new"))
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+	require.Equal(t, cfg.Listen, r.current.Listen, "listen should require restart")
+
+	entries := logs.FilterMessage("Config reloaded").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, []any{"auth.keys: changed", `log.level: "" -> "debug"`}, entries[0].ContextMap()["changes"])
+	require.Len(t, logs.FilterMessage("Config changes require restart").All(), 1)
+
+	// Invalid config keeps previous one.
+	write("auth: {enabled: true, keys: []}\n")
+	require.Error(t, r.Reload(ctx, "test"))
+	require.Equal(t, http.StatusNotFound, status("new"))
+	require.Len(t, logs.FilterMessage("Config reload failed, keeping previous config").All(), 1)
+}
+
+type testServer struct{}
+
+func (*testServer) FindPath(string, *url.URL) (r testRoute, _ bool) { return r, false }
+
+type testRoute struct{}
+
+func (testRoute) Name() string        { return "" }
+func (testRoute) OperationID() string { return "" }
+func (testRoute) PathPattern() string { return "" }
@@ -0,0 +1,317 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"example"
+	"example/internal/api"
+	"example/internal/httpmiddleware"
+	"example/internal/httprecord"
+	"example/internal/oas"
+	"example/internal/oasspec"
+)
+
+// OpenStorage opens pet storage.
+func OpenStorage(cfg StorageConfig) (api.Storage, error) {
+	switch cfg.Driver {
+	case "bolt":
+		return api.OpenBoltStorage(cfg.Path)
+	default:
+		return api.NewMemoryStorage(), nil
+	}
+}
+
+// Options configures Server.
+type Options struct {
+	// Storage is a pet storage.
+	//
+	// Required.
+	Storage api.Storage
+	// Metrics provides telemetry.
+	//
+	// Required.
+	Metrics httpmiddleware.Metrics
+	// Logger is injected into request context.
+	//
+	// Defaults to no-op logger.
+	Logger *zap.Logger
+	// Level is a log level, changed on reload.
+	//
+	// Defaults to info level.
+	Level zap.AtomicLevel
+	// Loader reloads configuration.
+	//
+	// Defaults to loader without config file.
+	Loader *ConfigLoader
+}
+
+// Server is api-server HTTP handler with full middleware chain.
+type Server struct {
+	handler  http.Handler
+	find     httpmiddleware.RouteFinder
+	reload   *reloader
+	chaos    *httpmiddleware.Chaos
+	events   *api.Events
+	webhooks *api.Webhooks
+	relay    *api.Relay
+	closers  []io.Closer
+}
+
+// New creates new Server.
+func New(cfg Config, opts Options) (_ *Server, rerr error) {
+	if opts.Storage == nil {
+		return nil, errors.New("storage is required")
+	}
+	if opts.Metrics == nil {
+		return nil, errors.New("metrics is required")
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.Level == (zap.AtomicLevel{}) {
+		opts.Level = zap.NewAtomicLevel()
+	}
+	if opts.Loader == nil {
+		opts.Loader = &ConfigLoader{}
+	}
+	m := opts.Metrics
+
+	events := api.NewEvents(cfg.Events.History)
+	base := strings.TrimSuffix(cfg.Events.PublicURL, "/")
+	ce := api.CloudEventsConfig{
+		Source:     base + "/pet",
+		DataSchema: base + "/openapi.yml#/components/schemas/Pet",
+	}
+	// Already validated.
+	policy, _ := cfg.Webhooks.Policy()
+	webhooks, err := api.NewWebhooks(opts.Storage, m, api.WebhooksConfig{
+		Client:       &http.Client{Timeout: cfg.Webhooks.Timeout, Transport: policy.Transport()},
+		Policy:       policy,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		MinBackoff:   cfg.Webhooks.MinBackoff,
+		MaxBackoff:   cfg.Webhooks.MaxBackoff,
+		DisableAfter: cfg.Webhooks.DisableAfter,
+		Retention:    cfg.Webhooks.Retention,
+		CloudEvents:  ce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "webhooks")
+	}
+	s := &Server{
+		events:   events,
+		webhooks: webhooks,
+	}
+	defer func() {
+		if rerr != nil {
+			_ = s.Close()
+		}
+	}()
+	publishers := []api.EventPublisher{api.NewMemoryPublisher(events), webhooks}
+	if path := cfg.Outbox.File; path != "" {
+		file, err := api.OpenFilePublisher(path, ce)
+		if err != nil {
+			return nil, errors.Wrap(err, "outbox file")
+		}
+		s.closers = append(s.closers, file)
+		publishers = append(publishers, file)
+	}
+	s.relay, err = api.NewRelay(opts.Storage, api.NewMultiPublisher(publishers...), m, api.RelayConfig{
+		Interval: cfg.Outbox.Interval,
+		Batch:    cfg.Outbox.Batch,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "relay")
+	}
+
+	oasServer, err := oas.NewServer(api.NewHandler(opts.Storage, api.WithRelay(s.relay), api.WithWebhookPolicy(policy)),
+		oas.WithTracerProvider(m.TracerProvider()),
+		oas.WithMeterProvider(m.MeterProvider()),
+		oas.WithErrorHandler(api.ErrorHandler),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "server init")
+	}
+
+	s.find = httpmiddleware.MakeRouteFinder(oasServer)
+	s.reload = newReloader(opts.Loader, cfg,
+		opts.Logger.Named("config"),
+		m.TracerProvider().Tracer("api-server"),
+		opts.Level,
+		s.find,
+	)
+
+	// Using OpenTelemetry instrumentation for HTTP server.
+	middlewares := []httpmiddleware.Middleware{
+		// Logs bridge core is not filtered by level, so filter explicitly.
+		httpmiddleware.InjectLogger(opts.Logger.WithOptions(zap.IncreaseLevel(opts.Level))),
+		httpmiddleware.Instrument("api", s.find, m),
+	}
+	if cfg.Chaos.Enabled {
+		chaos, err := httpmiddleware.NewChaos(s.find, m)
+		if err != nil {
+			return nil, errors.Wrap(err, "chaos")
+		}
+		opts.Logger.Warn("Fault injection is allowed, faults are toggled through admin API")
+		s.chaos = chaos
+		// Right after instrumentation, so faults are marked on request span
+		// and connection can be hijacked.
+		middlewares = append(middlewares, chaos.Middleware)
+	}
+	if path := cfg.Record.Path; path != "" {
+		sink, err := httprecord.Create(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "record")
+		}
+		s.closers = append(s.closers, sink)
+		redaction := httprecord.DefaultRedaction()
+		redaction.Headers = append(redaction.Headers, cfg.Auth.Header)
+		redaction.Fields = cfg.Record.RedactFields
+		rec := httprecord.Recorder{
+			Sink:        sink,
+			Find:        s.find,
+			Redaction:   redaction,
+			MaxBodySize: int64(cfg.Record.MaxBodySize),
+		}
+		middlewares = append(middlewares, rec.Middleware)
+	}
+	middlewares = append(middlewares, s.reload.Middleware())
+	if c := cfg.Shadow; c.Target != "" {
+		// Already validated.
+		target, _ := url.Parse(c.Target)
+		rates, _ := c.OperationRates()
+		// After reloader, so only authorized requests not replayed by
+		// idempotency cache are mirrored.
+		shadow, err := httpmiddleware.Shadow(s.find, m, httpmiddleware.ShadowConfig{
+			Target:         target,
+			SampleRate:     c.SampleRate,
+			OperationRates: rates,
+			Writes:         c.Writes,
+			IgnoreFields:   c.IgnoreFields,
+			MaxBodySize:    int64(c.MaxBodySize),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "shadow")
+		}
+		middlewares = append(middlewares, shadow)
+	}
+	if c := cfg.Validation; c.Responses {
+		spec, err := oasspec.Parse(example.OpenAPISpec)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse spec")
+		}
+		// Innermost, so only handler responses are validated.
+		validate, err := httpmiddleware.ValidateResponses(s.find, m, httpmiddleware.ResponseValidationConfig{
+			Spec:   spec,
+			Strict: c.Strict,
+			// ogen rejects invalid requests with 400 not declared in spec.
+			IgnoreStatus: []int{http.StatusBadRequest},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "validation")
+		}
+		middlewares = append(middlewares, validate)
+	}
+
+	mux := http.NewServeMux()
+	// Health check for client-side load balancers, not instrumented.
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	// Spec is served for CloudEvents dataschema.
+	mux.HandleFunc("GET /openapi.yml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(example.OpenAPISpec)
+	})
+	stream, err := api.NewEventStream(events, m, api.StreamConfig{
+		Heartbeat:   cfg.Events.Heartbeat,
+		Buffer:      cfg.Events.SubscriberBuffer,
+		CloudEvents: ce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "event stream")
+	}
+	ws, err := api.NewWebSocket(events, m, api.WebSocketConfig{
+		PingInterval:     cfg.WebSocket.PingInterval,
+		MessageRate:      cfg.WebSocket.MessageRate,
+		MessageBurst:     cfg.WebSocket.MessageBurst,
+		MaxSubscriptions: cfg.WebSocket.MaxSubscriptions,
+		Buffer:           cfg.Events.SubscriberBuffer,
+		CloudEvents:      ce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "websocket")
+	}
+	// Not API operations: streams trace themselves, so request span is not
+	// held open for connection lifetime.
+	streamMiddlewares := []httpmiddleware.Middleware{
+		httpmiddleware.InjectLogger(opts.Logger.WithOptions(zap.IncreaseLevel(opts.Level))),
+		s.reload.Middleware(),
+	}
+	mux.Handle("GET /pet/events", httpmiddleware.Wrap(stream, streamMiddlewares...))
+	mux.Handle("GET /pet/ws", httpmiddleware.Wrap(ws, streamMiddlewares...))
+	mux.Handle("/", httpmiddleware.Wrap(oasServer, middlewares...))
+	s.handler = mux
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// RouteFinder returns route finder of API operations.
+func (s *Server) RouteFinder() httpmiddleware.RouteFinder {
+	return s.find
+}
+
+// Chaos returns fault injector, nil if fault injection is not allowed.
+func (s *Server) Chaos() *httpmiddleware.Chaos {
+	return s.chaos
+}
+
+// Relay returns relay publishing events from storage outbox, it should be
+// run alongside Server.
+func (s *Server) Relay() *api.Relay {
+	return s.relay
+}
+
+// CloseStreams closes event streams and WebSocket connections, so graceful
+// shutdown does not wait for them. New streams are closed right after connect.
+func (s *Server) CloseStreams() {
+	s.events.Close()
+}
+
+// Reload loads and applies configuration.
+func (s *Server) Reload(ctx context.Context, reason string) error {
+	return s.reload.Reload(ctx, reason)
+}
+
+// Run reloads configuration on SIGHUP or config file change and delivers
+// webhooks until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.reload.Run(ctx)
+	})
+	g.Go(func() error {
+		return s.webhooks.Run(ctx)
+	})
+	return g.Wait()
+}
+
+// Close releases resources of Server, storage is not closed.
+func (s *Server) Close() error {
+	var errs []error
+	for _, c := range s.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
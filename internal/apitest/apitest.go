@@ -0,0 +1,195 @@
+// Package apitest runs api-server in process for integration tests.
+package apitest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"example/internal/api"
+	"example/internal/apiserver"
+	"example/internal/httpmiddleware"
+	"example/internal/httptransport"
+	"example/internal/oas"
+	"example/internal/oteltest"
+)
+
+// telemetry implements httpmiddleware.Metrics using in-memory exporters.
+type telemetry struct {
+	traces *httpmiddleware.Provider
+	meter  *sdkmetric.MeterProvider
+}
+
+func (t telemetry) TracerProvider() trace.TracerProvider { return t.traces }
+func (t telemetry) MeterProvider() metric.MeterProvider  { return t.meter }
+func (t telemetry) TextMapPropagator() propagation.TextMapPropagator {
+	return propagation.TraceContext{}
+}
+
+// Env is running api-server with client.
+type Env struct {
+	// Server runs api-server handler with full middleware chain.
+	Server *httptest.Server
+	// API is api-server handler.
+	API *apiserver.Server
+	// Client sends requests to Server, using first API key if auth is enabled.
+	Client *oas.Client
+	// Storage is a pet storage of Server.
+	Storage api.Storage
+
+	// Traces collects spans of Server and Client.
+	Traces *httpmiddleware.Provider
+	// Metrics collects metrics of Server and Client.
+	Metrics *sdkmetric.ManualReader
+	// Logs collects Server logs of all levels.
+	Logs *observer.ObservedLogs
+
+	t         testing.TB
+	telemetry telemetry
+}
+
+// New starts api-server with given config and memory storage.
+//
+// Server and its background workers are stopped on test cleanup.
+func New(t testing.TB, cfg apiserver.Config) *Env {
+	t.Helper()
+	require.NoError(t, cfg.Validate())
+
+	reader := sdkmetric.NewManualReader()
+	e := &Env{
+		Storage: api.NewMemoryStorage(),
+		Traces:  httpmiddleware.NewProvider(),
+		Metrics: reader,
+		t:       t,
+	}
+	e.telemetry = telemetry{
+		traces: e.Traces,
+		meter:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = e.telemetry.meter.Shutdown(ctx)
+		_ = e.Traces.Shutdown(ctx)
+	})
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	e.Logs = logs
+	srv, err := apiserver.New(cfg, apiserver.Options{
+		Storage: e.Storage,
+		Metrics: e.telemetry,
+		Logger:  zap.New(core),
+		Level:   zap.NewAtomicLevelAt(zapcore.DebugLevel),
+		// Config is not reloaded from environment.
+		Loader: &apiserver.ConfigLoader{
+			LookupEnv: func(string) (string, bool) { return "", false },
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+	e.API = srv
+
+	// Background workers: outbox relay and webhook delivery.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	go func() { done <- srv.Run(ctx) }()
+	go func() { done <- srv.Relay().Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+		require.NoError(t, <-done)
+	})
+
+	e.Server = httptest.NewServer(srv)
+	t.Cleanup(e.Server.Close)
+
+	var headers http.Header
+	if cfg.Auth.Enabled {
+		headers = http.Header{cfg.Auth.Header: {cfg.Auth.Keys[0]}}
+	}
+	e.Client = e.NewClient(headers)
+	return e
+}
+
+// NewClient creates new client of Server sending given headers.
+func (e *Env) NewClient(headers http.Header, middlewares ...httptransport.Middleware) *oas.Client {
+	e.t.Helper()
+	transport := httptransport.Wrap(e.Server.Client().Transport, middlewares...)
+	c, err := oas.NewClient(e.Server.URL,
+		oas.WithClient(&http.Client{
+			Transport: httptransport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				for k, v := range headers {
+					r.Header[k] = v
+				}
+				e.telemetry.TextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+				return transport.RoundTrip(r)
+			}),
+		}),
+		oas.WithTracerProvider(e.telemetry.TracerProvider()),
+		oas.WithMeterProvider(e.telemetry.MeterProvider()),
+	)
+	require.NoError(e.t, err)
+	return c
+}
+
+// Spans returns finished spans.
+func (e *Env) Spans() tracetest.SpanStubs {
+	e.Traces.Flush()
+	return e.Traces.Exporter.GetSpans()
+}
+
+// Span returns finished span with given name.
+func (e *Env) Span(name string) (tracetest.SpanStub, bool) {
+	for _, s := range e.Spans() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+// RequireSpans fails test if finished spans do not contain expected trees.
+func (e *Env) RequireSpans(want ...oteltest.Span) {
+	e.t.Helper()
+	oteltest.RequireSpans(e.t, e.Spans(), want...)
+}
+
+// CollectMetrics collects metrics of Server and Client.
+func (e *Env) CollectMetrics() oteltest.Metrics {
+	e.t.Helper()
+	return oteltest.Collect(e.t, e.Metrics)
+}
+
+// Metric returns collected metric with given name.
+func (e *Env) Metric(name string) (metricdata.Metrics, bool) {
+	e.t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(e.t, e.Metrics.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// Reset drops collected spans and logs.
+func (e *Env) Reset() {
+	e.Traces.Flush()
+	e.Traces.Reset()
+	_ = e.Logs.TakeAll()
+}
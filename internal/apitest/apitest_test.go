@@ -0,0 +1,415 @@
+package apitest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/ogen-go/ogen/validate"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"example"
+	"example/internal/api"
+	"example/internal/apiserver"
+	"example/internal/oas"
+	"example/internal/oteltest"
+)
+
+func TestEnv(t *testing.T) {
+	ctx := context.Background()
+	cfg := apiserver.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.Keys = []string{"secret"}
+	e := New(t, cfg)
+
+	added, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{
+		IdempotencyKey: oas.NewOptString("key"),
+	})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	replayed, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{
+		IdempotencyKey: oas.NewOptString("key"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, pet, replayed)
+
+	got, err := e.Client.GetPetById(ctx, oas.GetPetByIdParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	require.Equal(t, pet, got)
+
+	_, err = e.NewClient(nil).GetPetById(ctx, oas.GetPetByIdParams{PetId: pet.ID.Value})
+	var statusErr *validate.UnexpectedStatusCodeError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+	// Spec is public, it is referenced by CloudEvents dataschema.
+	resp, err := http.Get(e.Server.URL + "/openapi.yml")
+	require.NoError(t, err)
+	spec, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, example.OpenAPISpec, spec)
+
+	// Server span is child of client span.
+	e.RequireSpans(oteltest.Span{
+		Name: "GetPetById",
+		Kind: trace.SpanKindClient,
+		Children: []oteltest.Span{{
+			Name: "api.getPetById",
+			Kind: trace.SpanKindServer,
+			Attributes: map[string]any{
+				"http.route":                "/pet/{petId}",
+				"http.response.status_code": http.StatusOK,
+			},
+			Children: []oteltest.Span{{
+				Name:       "GetPetById",
+				Kind:       trace.SpanKindServer,
+				Attributes: map[string]any{"oas.operation": "getPetById"},
+			}},
+		}},
+	})
+
+	// Replayed and unauthorized requests do not reach handler.
+	e.CollectMetrics().Require(t, "ogen.server.request_count", nil, 2)
+
+	require.Len(t, e.Logs.FilterMessage("Got request").All(), 4)
+	e.Reset()
+	require.Empty(t, e.Spans())
+	require.Zero(t, e.Logs.Len())
+}
+
+func TestEnvValidation(t *testing.T) {
+	ctx := context.Background()
+	cfg := apiserver.DefaultConfig()
+	cfg.Validation.Responses = true
+	cfg.Validation.Strict = true
+	e := New(t, cfg)
+
+	added, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	_, err = e.Client.GetPetById(ctx, oas.GetPetByIdParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	_, err = e.Client.UpdatePet(ctx, oas.UpdatePetParams{
+		PetId:  pet.ID.Value,
+		Status: oas.NewOptPetStatus(oas.PetStatusSold),
+	})
+	require.NoError(t, err)
+	res, err := e.Client.GetPetById(ctx, oas.GetPetByIdParams{PetId: pet.ID.Value + 1})
+	require.NoError(t, err)
+	require.IsType(t, &oas.GetPetByIdNotFound{}, res)
+
+	// Unknown pet is declared 404.
+	deleted, err := e.Client.DeletePet(ctx, oas.DeletePetParams{PetId: pet.ID.Value + 1})
+	require.NoError(t, err)
+	require.IsType(t, &oas.DeletePetNotFound{}, deleted)
+	updated, err := e.Client.UpdatePet(ctx, oas.UpdatePetParams{PetId: pet.ID.Value + 1})
+	require.NoError(t, err)
+	require.IsType(t, &oas.UpdatePetNotFound{}, updated)
+
+	require.Empty(t, e.Logs.FilterMessage("Response violates spec").All())
+}
+
+// readEvent reads next event of text/event-stream, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (id, typ, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if typ != "" {
+				return id, typ, data
+			}
+		case strings.HasPrefix(line, ":"):
+		default:
+			k, v, _ := strings.Cut(line, ": ")
+			switch k {
+			case "id":
+				id = v
+			case "event":
+				typ = v
+			case "data":
+				data = v
+			}
+		}
+	}
+}
+
+func TestEnvEvents(t *testing.T) {
+	ctx := context.Background()
+	cfg := apiserver.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.Keys = []string{"secret"}
+	cfg.Events.Heartbeat = 10 * time.Millisecond
+	e := New(t, cfg)
+
+	subscribe := func(lastID string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.Server.URL+"/pet/events", nil)
+		require.NoError(t, err)
+		req.Header.Set(cfg.Auth.Header, "secret")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := e.Server.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	resp, err := http.Get(e.Server.URL + "/pet/events")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = subscribe("")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+
+	added, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	_, err = e.Client.UpdatePet(ctx, oas.UpdatePetParams{
+		PetId:  pet.ID.Value,
+		Status: oas.NewOptPetStatus(oas.PetStatusSold),
+	})
+	require.NoError(t, err)
+	_, err = e.Client.DeletePet(ctx, oas.DeletePetParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+
+	id, typ, data := readEvent(t, r)
+	require.Equal(t, "1", id)
+	require.Equal(t, "created", typ)
+	ce, err := api.ReadCloudEvent(http.Header{"Content-Type": {api.CloudEventsContentType}}, []byte(data))
+	require.NoError(t, err)
+	require.Equal(t, "com.example.pet.created", ce.Type)
+	require.Equal(t, "http://localhost:8080/pet", ce.Source)
+	require.Equal(t, "http://localhost:8080/openapi.yml#/components/schemas/Pet", ce.DataSchema)
+	require.JSONEq(t, `{"id":1,"name":"Tom"}`, string(ce.Data))
+	// Consumers continue trace of request that added pet.
+	add, ok := e.Span("api.addPet")
+	require.True(t, ok)
+	require.Contains(t, ce.TraceParent, add.SpanContext.TraceID().String())
+	for _, want := range []string{"updated", "status_changed", "deleted"} {
+		_, typ, _ = readEvent(t, r)
+		require.Equal(t, want, typ)
+	}
+
+	// Resume after second event.
+	resumed := bufio.NewReader(subscribe("2").Body)
+	id, typ, _ = readEvent(t, resumed)
+	require.Equal(t, "3", id)
+	require.Equal(t, "status_changed", typ)
+	id, _, _ = readEvent(t, resumed)
+	require.Equal(t, "4", id)
+
+	require.Equal(t, http.StatusBadRequest, subscribe("x").StatusCode)
+
+	// Streams end on shutdown.
+	e.API.CloseStreams()
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resumed)
+	require.NoError(t, err)
+
+	m := e.CollectMetrics()
+	m.Require(t, "sse.connection.duration", map[string]any{"sse.close_reason": "shutdown"}, 2)
+	m.Require(t, "sse.connections.active", nil, 0)
+	m.Require(t, "sse.events.sent", map[string]any{"event.type": "status_changed"}, 2)
+
+	// Request span ends when stream is established.
+	e.RequireSpans(oteltest.Span{
+		Name:       "api.petEvents",
+		Kind:       trace.SpanKindServer,
+		Status:     codes.Ok,
+		Attributes: map[string]any{"sse.last_event_id": 2, "sse.replayed": 2},
+		Children: []oteltest.Span{{
+			Name:       "api.petEvents.stream",
+			Attributes: map[string]any{"sse.events_sent": 0, "sse.close_reason": "shutdown"},
+		}},
+	})
+}
+
+func TestEnvWebSocket(t *testing.T) {
+	ctx := context.Background()
+	cfg := apiserver.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.Keys = []string{"secret"}
+	cfg.WebSocket.MessageRate = 0.001
+	cfg.WebSocket.MessageBurst = 3
+	e := New(t, cfg)
+
+	_, resp, err := websocket.Dial(ctx, e.Server.URL+"/pet/ws", nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	conn, _, err := websocket.Dial(ctx, e.Server.URL+"/pet/ws", &websocket.DialOptions{
+		HTTPHeader: http.Header{cfg.Auth.Header: {"secret"}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = conn.CloseNow() }()
+	send := func(msg string) {
+		require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(msg)))
+	}
+	read := func() string {
+		_, data, err := conn.Read(ctx)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	added, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+
+	send(`{"type":"subscribe","id":"sold","filter":{"statuses":["sold"]}}`)
+	require.JSONEq(t, `{"type":"subscribed","id":"sold"}`, read())
+	send(`{"type":"subscribe","id":"pet","filter":{"pet_ids":[1],"events":["updated","deleted"]}}`)
+	require.JSONEq(t, `{"type":"subscribed","id":"pet"}`, read())
+	send(`{"type":"subscribe","id":"bad","filter":{"statuses":["lost"]}}`)
+	require.Contains(t, read(), `"type":"error","id":"bad"`)
+
+	_, err = e.Client.UpdatePet(ctx, oas.UpdatePetParams{
+		PetId:  pet.ID.Value,
+		Status: oas.NewOptPetStatus(oas.PetStatusSold),
+	})
+	require.NoError(t, err)
+	require.Contains(t, read(), `"subscriptions":["pet","sold"],"event":{"specversion":"1.0","id":"2","source":"http://localhost:8080/pet","type":"com.example.pet.updated","subject":"1"`)
+	require.Contains(t, read(), `"subscriptions":["sold"],"event":{"specversion":"1.0","id":"3","source":"http://localhost:8080/pet","type":"com.example.pet.status_changed"`)
+
+	// Burst is exhausted.
+	send(`{"type":"unsubscribe","id":"sold"}`)
+	require.JSONEq(t, `{"type":"error","id":"sold","error_message":"rate limit exceeded"}`, read())
+
+	_, err = e.Client.DeletePet(ctx, oas.DeletePetParams{PetId: pet.ID.Value})
+	require.NoError(t, err)
+	require.Contains(t, read(), `"subscriptions":["pet"],"event":{"specversion":"1.0","id":"4","source":"http://localhost:8080/pet","type":"com.example.pet.deleted","subject":"1","traceparent"`)
+
+	// Connection is closed on shutdown.
+	e.API.CloseStreams()
+	_, _, err = conn.Read(ctx)
+	require.Equal(t, websocket.StatusGoingAway, websocket.CloseStatus(err))
+	// Server finishes close handshake after client received close frame.
+	require.Eventually(t, func() bool {
+		_, ok := e.Span("api.petSubscriptions.connection")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	m := e.CollectMetrics()
+	m.Require(t, "ws.events.sent", nil, 3)
+	m.Require(t, "ws.messages.received", map[string]any{"ws.message.result": "rate_limited"}, 1)
+	e.RequireSpans(oteltest.Span{
+		Name:   "api.petSubscriptions",
+		Kind:   trace.SpanKindServer,
+		Status: codes.Ok,
+		Children: []oteltest.Span{{
+			Name:       "api.petSubscriptions.connection",
+			Attributes: map[string]any{"ws.events_sent": 3, "ws.close_reason": "shutdown"},
+		}},
+	})
+}
+
+func TestEnvWebhooks(t *testing.T) {
+	ctx := context.Background()
+	cfg := apiserver.DefaultConfig()
+	cfg.Webhooks.AllowNetworks = []string{"127.0.0.0/8"}
+	e := New(t, cfg)
+
+	const secret = "0123456789abcdef"
+	type delivery struct {
+		header http.Header
+		body   []byte
+		err    error
+	}
+	received := make(chan delivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{header: r.Header, body: body, err: api.VerifyWebhook(r, body, secret, time.Minute)}
+	}))
+	t.Cleanup(receiver.Close)
+
+	req := &oas.WebhookRequest{Events: []oas.WebhookEventType{oas.WebhookEventTypeCreated}, Secret: secret}
+	require.NoError(t, req.URL.UnmarshalBinary([]byte("ftp://example.com")))
+	_, err := e.Client.CreateWebhook(ctx, req, oas.CreateWebhookParams{})
+	var statusErr *validate.UnexpectedStatusCodeError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+
+	req = &oas.WebhookRequest{
+		Events:   []oas.WebhookEventType{oas.WebhookEventTypeStatusChanged},
+		Statuses: []oas.PetStatus{oas.PetStatusSold},
+		Secret:   secret,
+	}
+	require.NoError(t, req.URL.UnmarshalBinary([]byte(receiver.URL)))
+	wh, err := e.Client.CreateWebhook(ctx, req, oas.CreateWebhookParams{})
+	require.NoError(t, err)
+
+	added, err := e.Client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	_, err = e.Client.UpdatePet(ctx, oas.UpdatePetParams{
+		PetId:  pet.ID.Value,
+		Status: oas.NewOptPetStatus(oas.PetStatusSold),
+	})
+	require.NoError(t, err)
+
+	var d delivery
+	select {
+	case d = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook is not delivered")
+	}
+	require.NoError(t, d.err)
+	require.Equal(t, "status_changed", d.header.Get(api.WebhookEventHeader))
+	ce, err := api.ReadCloudEvent(d.header, d.body)
+	require.NoError(t, err)
+	require.Equal(t, "com.example.pet.status_changed", ce.Type)
+	require.JSONEq(t, `{"id":1,"name":"Tom","status":"sold"}`, string(ce.Data))
+
+	var deliveries oas.ListWebhookDeliveriesOKApplicationJSON
+	require.Eventually(t, func() bool {
+		res, err := e.Client.ListWebhookDeliveries(ctx, oas.ListWebhookDeliveriesParams{WebhookId: wh.ID})
+		require.NoError(t, err)
+		deliveries = *res.(*oas.ListWebhookDeliveriesOKApplicationJSON)
+		return len(deliveries) == 1 && deliveries[0].Status == oas.WebhookDeliveryStatusSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, deliveries[0].Attempts, 1)
+	require.Equal(t, oas.NewOptInt(http.StatusOK), deliveries[0].Attempts[0].StatusCode)
+	require.Empty(t, received)
+
+	// Delivery trace is linked to request that changed status.
+	update, ok := e.Span("api.updatePet")
+	require.True(t, ok)
+	var deliver tracetest.SpanStub
+	require.Eventually(t, func() bool {
+		deliver, ok = e.Span("webhook.deliver")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, deliver.Links, 1)
+	require.Equal(t, update.SpanContext.TraceID(), deliver.Links[0].SpanContext.TraceID())
+	// Event is published from outbox in the same trace.
+	var publish tracetest.SpanStub
+	for _, s := range e.Spans() {
+		if s.Name == "outbox.publish" && s.SpanContext.SpanID() == deliver.Links[0].SpanContext.SpanID() {
+			publish = s
+		}
+	}
+	require.Equal(t, update.SpanContext.TraceID(), publish.SpanContext.TraceID())
+	require.Equal(t, trace.SpanKindProducer, publish.SpanKind)
+	require.Contains(t, ce.TraceParent, publish.SpanContext.SpanID().String())
+
+	m := e.CollectMetrics()
+	m.Require(t, "webhook.attempts", map[string]any{"webhook.result": "success"}, 1)
+	m.Require(t, "outbox.published", map[string]any{"outbox.result": "success"}, 3)
+}
@@ -0,0 +1,47 @@
+package httpmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+type callerKey struct{}
+
+// Caller returns caller identity set by APIKeyAuth.
+func Caller(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(callerKey{}).(string)
+	return v, ok
+}
+
+// WithCaller sets caller identity to context.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// KeyIdentity returns non-secret identity of API key.
+func KeyIdentity(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(h[:4])
+}
+
+// APIKeyAuth rejects requests without one of given keys in header.
+//
+// Identity of the key is available through Caller.
+func APIKeyAuth(header string, keys []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(header)
+			for _, key := range keys {
+				if subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1 {
+					ctx := WithCaller(r.Context(), KeyIdentity(key))
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		})
+	}
+}
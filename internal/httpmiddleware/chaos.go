@@ -0,0 +1,413 @@
+package httpmiddleware
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ChaosAnyOperation is a key of ChaosConfig.Operations rule applied to
+// operations without own rule.
+const ChaosAnyOperation = "*"
+
+// Chaos fault kinds.
+const (
+	chaosLatency  = "latency"
+	chaosError    = "error"
+	chaosReset    = "reset"
+	chaosTruncate = "truncate"
+	chaosSlow     = "slow"
+)
+
+// ChaosDuration is a time.Duration encoded in JSON as string like "100ms".
+type ChaosDuration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d ChaosDuration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *ChaosDuration) UnmarshalText(data []byte) error {
+	v, err := time.ParseDuration(string(data))
+	if err != nil {
+		return err
+	}
+	*d = ChaosDuration(v)
+	return nil
+}
+
+// ChaosLatency configures added latency.
+type ChaosLatency struct {
+	// Rate is a probability of adding latency.
+	Rate float64 `json:"rate"`
+	// Distribution of latency: "fixed" (default), "uniform", "normal" or "exponential".
+	Distribution string `json:"distribution,omitempty"`
+	// Mean is a mean latency.
+	Mean ChaosDuration `json:"mean"`
+	// Jitter is a half-width of uniform and standard deviation of normal distribution.
+	Jitter ChaosDuration `json:"jitter,omitempty"`
+}
+
+// sample returns random latency.
+func (l ChaosLatency) sample(rnd func() float64) time.Duration {
+	mean, jitter := float64(l.Mean), float64(l.Jitter)
+	var d float64
+	switch l.Distribution {
+	case "uniform":
+		d = mean - jitter + 2*jitter*rnd()
+	case "normal":
+		// Box-Muller transform.
+		u1, u2 := 1-rnd(), rnd()
+		d = mean + jitter*math.Sqrt(-2*math.Log(u1))*math.Cos(2*math.Pi*u2)
+	case "exponential":
+		d = -mean * math.Log(1-rnd())
+	default:
+		d = mean
+	}
+	return time.Duration(max(d, 0))
+}
+
+// ChaosRule configures faults injected into requests of operation.
+//
+// Faults are tried in order with their probabilities: latency, connection
+// reset, error status, then truncated or slowly written body.
+type ChaosRule struct {
+	Latency ChaosLatency `json:"latency"`
+	// ErrorRate is a probability of responding with one of ErrorStatus.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+	// ErrorStatus lists status codes chosen uniformly, 500 if empty.
+	ErrorStatus []int `json:"error_status,omitempty"`
+	// ResetRate is a probability of resetting connection without response.
+	ResetRate float64 `json:"reset_rate,omitempty"`
+	// TruncateRate is a probability of closing connection in the middle of body.
+	TruncateRate float64 `json:"truncate_rate,omitempty"`
+	// SlowRate is a probability of writing body in chunks with delay.
+	SlowRate float64 `json:"slow_rate,omitempty"`
+	// SlowChunk is a size of slowly written chunk, defaults to 16 bytes.
+	SlowChunk int `json:"slow_chunk,omitempty"`
+	// SlowInterval is a delay between slowly written chunks, defaults to 100ms.
+	SlowInterval ChaosDuration `json:"slow_interval,omitempty"`
+}
+
+func (r *ChaosRule) setDefaults() {
+	if r.SlowChunk <= 0 {
+		r.SlowChunk = 16
+	}
+	if r.SlowInterval <= 0 {
+		r.SlowInterval = ChaosDuration(100 * time.Millisecond)
+	}
+}
+
+// Validate checks rule.
+func (r ChaosRule) Validate() error {
+	var errs []error
+	for _, rate := range []struct {
+		name  string
+		value float64
+	}{
+		{"latency.rate", r.Latency.Rate},
+		{"error_rate", r.ErrorRate},
+		{"reset_rate", r.ResetRate},
+		{"truncate_rate", r.TruncateRate},
+		{"slow_rate", r.SlowRate},
+	} {
+		if rate.value < 0 || rate.value > 1 {
+			errs = append(errs, errors.Errorf("%s: must be in [0, 1]", rate.name))
+		}
+	}
+	switch r.Latency.Distribution {
+	case "", "fixed", "uniform", "normal", "exponential":
+	default:
+		errs = append(errs, errors.Errorf("latency.distribution: unknown distribution %q", r.Latency.Distribution))
+	}
+	if r.Latency.Mean < 0 || r.Latency.Jitter < 0 {
+		errs = append(errs, errors.New("latency: must not be negative"))
+	}
+	for _, code := range r.ErrorStatus {
+		if code < 400 || code > 599 {
+			errs = append(errs, errors.Errorf("error_status: %d is not an error status", code))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ChaosConfig configures Chaos.
+type ChaosConfig struct {
+	// Enabled toggles fault injection.
+	Enabled bool `json:"enabled"`
+	// Operations are rules by operation ID, ChaosAnyOperation rule is applied
+	// to other operations.
+	Operations map[string]ChaosRule `json:"operations,omitempty"`
+}
+
+// Validate checks config.
+func (c ChaosConfig) Validate() error {
+	ops := make([]string, 0, len(c.Operations))
+	for op := range c.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var errs []error
+	for _, op := range ops {
+		if err := c.Operations[op].Validate(); err != nil {
+			errs = append(errs, errors.Wrap(err, op))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Chaos injects faults into responses for resilience testing of clients.
+//
+// Faults are disabled until enabled by SetConfig, e.g. through admin API
+// served by ServeHTTP. Injected faults are added as "chaos" events to
+// request span and counted by operation ID.
+type Chaos struct {
+	find   RouteFinder
+	faults metric.Int64Counter
+
+	mux sync.RWMutex
+	cfg ChaosConfig
+
+	// rand and sleep are replaced in tests.
+	rand  func() float64
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewChaos creates new disabled Chaos.
+func NewChaos(find RouteFinder, m Metrics) (*Chaos, error) {
+	faults, err := m.MeterProvider().Meter("example/internal/httpmiddleware").Int64Counter(
+		"http.server.chaos.faults",
+		metric.WithDescription("Number of injected faults by fault kind"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "faults counter")
+	}
+	return &Chaos{
+		find:   find,
+		faults: faults,
+		// #nosec G404
+		rand:  rand.Float64,
+		sleep: sleepContext,
+	}, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Config returns current config.
+func (c *Chaos) Config() ChaosConfig {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.cfg
+}
+
+// SetConfig validates and applies config.
+func (c *Chaos) SetConfig(cfg ChaosConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.cfg = cfg
+	return nil
+}
+
+// rule returns rule of operation, if faults are enabled.
+func (c *Chaos) rule(op string) (ChaosRule, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if !c.cfg.Enabled {
+		return ChaosRule{}, false
+	}
+	rule, ok := c.cfg.Operations[op]
+	if !ok {
+		rule, ok = c.cfg.Operations[ChaosAnyOperation]
+	}
+	rule.setDefaults()
+	return rule, ok
+}
+
+// ServeHTTP serves config as JSON on GET and replaces it on PUT.
+func (c *Chaos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg ChaosConfig
+		d := json.NewDecoder(r.Body)
+		d.DisallowUnknownFields()
+		if err := d.Decode(&cfg); err != nil {
+			http.Error(w, "decode config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	_ = e.Encode(c.Config())
+}
+
+// inject marks fault on span and counts it.
+func (c *Chaos) inject(ctx context.Context, op, kind string, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Bool("chaos.injected", true))
+	span.AddEvent("chaos", trace.WithAttributes(
+		append([]attribute.KeyValue{attribute.String("chaos.fault", kind)}, attrs...)...,
+	))
+	c.faults.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("oas.operation", op),
+		attribute.String("chaos.fault", kind),
+	))
+}
+
+// Middleware implements Middleware.
+//
+// It should be placed right after instrumentation, before middlewares
+// wrapping http.ResponseWriter, so connection can be hijacked.
+func (c *Chaos) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := c.find(r.Method, r.URL)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := route.OperationID()
+		rule, ok := c.rule(op)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+
+		if rule.Latency.Rate > 0 && c.rand() < rule.Latency.Rate {
+			d := rule.Latency.sample(c.rand)
+			c.inject(ctx, op, chaosLatency, attribute.Int64("chaos.latency_ms", d.Milliseconds()))
+			if err := c.sleep(ctx, d); err != nil {
+				return
+			}
+		}
+		if rule.ResetRate > 0 && c.rand() < rule.ResetRate {
+			c.inject(ctx, op, chaosReset)
+			resetConnection(w)
+			return
+		}
+		if rule.ErrorRate > 0 && c.rand() < rule.ErrorRate {
+			status := http.StatusInternalServerError
+			if n := len(rule.ErrorStatus); n > 0 {
+				status = rule.ErrorStatus[int(c.rand()*float64(n))%n]
+			}
+			c.inject(ctx, op, chaosError, attribute.Int("chaos.status", status))
+			writeChaosError(w, status)
+			return
+		}
+
+		var (
+			truncate = rule.TruncateRate > 0 && c.rand() < rule.TruncateRate
+			slow     = !truncate && rule.SlowRate > 0 && c.rand() < rule.SlowRate
+		)
+		if !truncate && !slow {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buf := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		body := buf.body.Bytes()
+		for k, v := range buf.header {
+			w.Header()[k] = v
+		}
+		// Client expects full body, so truncation is detected.
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buf.status)
+
+		if truncate {
+			c.inject(ctx, op, chaosTruncate, attribute.Int("chaos.written", len(body)/2))
+			_, _ = w.Write(body[:len(body)/2])
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			// Aborts response, closing connection.
+			panic(http.ErrAbortHandler)
+		}
+
+		c.inject(ctx, op, chaosSlow,
+			attribute.Int("chaos.chunk", rule.SlowChunk),
+			attribute.Int64("chaos.interval_ms", time.Duration(rule.SlowInterval).Milliseconds()),
+		)
+		for len(body) > 0 {
+			n := min(rule.SlowChunk, len(body))
+			if _, err := w.Write(body[:n]); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			body = body[n:]
+			if len(body) == 0 {
+				break
+			}
+			if err := c.sleep(ctx, time.Duration(rule.SlowInterval)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// resetConnection closes connection without response, sending TCP RST if possible.
+func resetConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+func writeChaosError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	e.ObjStart()
+	e.FieldStart("error_message")
+	e.Str("chaos: injected fault")
+	e.ObjEnd()
+
+	_, _ = w.Write(e.Bytes())
+}
@@ -0,0 +1,181 @@
+package httpmiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestChaos(t *testing.T) {
+	provider := NewProvider()
+	c, err := NewChaos(testShadowFinder, testMetrics{
+		tp: provider,
+		mp: sdkmetric.NewMeterProvider(),
+	})
+	require.NoError(t, err)
+	var (
+		mux   sync.Mutex
+		slept []time.Duration
+	)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		mux.Lock()
+		defer mux.Unlock()
+		slept = append(slept, d)
+		return nil
+	}
+	// Every fault with non-zero rate is injected.
+	c.rand = func() float64 { return 0 }
+
+	const body = `{"id":1,"name":"Tom","photoUrls":["a","b"]}`
+	srv := httptest.NewServer(Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, body)
+		}),
+		Instrument("api", testShadowFinder, testMetrics{tp: provider, mp: sdkmetric.NewMeterProvider()}),
+		c.Middleware,
+	))
+	t.Cleanup(srv.Close)
+	// Transport retries GET after reset of reused connection.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func() (*http.Response, error) {
+		return client.Get(srv.URL + "/pet/1")
+	}
+	set := func(rule ChaosRule) {
+		require.NoError(t, c.SetConfig(ChaosConfig{
+			Enabled:    true,
+			Operations: map[string]ChaosRule{"getPetById": rule},
+		}))
+	}
+	faults := func() (r []string) {
+		provider.Flush()
+		defer provider.Reset()
+		for _, s := range provider.Exporter.GetSpans() {
+			for _, e := range s.Events {
+				if e.Name != "chaos" {
+					continue
+				}
+				for _, a := range e.Attributes {
+					if a.Key == "chaos.fault" {
+						r = append(r, a.Value.AsString())
+					}
+				}
+			}
+		}
+		return r
+	}
+
+	// Disabled by default.
+	resp, err := get()
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, faults())
+
+	set(ChaosRule{
+		Latency:     ChaosLatency{Rate: 1, Mean: ChaosDuration(time.Second)},
+		ErrorRate:   1,
+		ErrorStatus: []int{http.StatusServiceUnavailable},
+	})
+	resp, err = get()
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, []time.Duration{time.Second}, slept)
+	require.Equal(t, []string{"latency", "error"}, faults())
+
+	set(ChaosRule{ResetRate: 1})
+	_, err = get()
+	require.Error(t, err)
+	require.Equal(t, []string{"reset"}, faults())
+
+	set(ChaosRule{TruncateRate: 1})
+	resp, err = get()
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, body[:len(body)/2], string(data))
+	require.Equal(t, []string{"truncate"}, faults())
+
+	slept = nil
+	set(ChaosRule{SlowRate: 1, SlowChunk: 10, SlowInterval: ChaosDuration(time.Millisecond)})
+	resp, err = get()
+	require.NoError(t, err)
+	data, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, body, string(data))
+	require.Len(t, slept, (len(body)-1)/10)
+	require.Equal(t, []string{"slow"}, faults())
+
+	// Rule of other operation is not applied.
+	require.NoError(t, c.SetConfig(ChaosConfig{
+		Enabled:    true,
+		Operations: map[string]ChaosRule{"addPet": {ErrorRate: 1}},
+	}))
+	resp, err = get()
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, faults())
+}
+
+func TestChaosLatency(t *testing.T) {
+	mean := ChaosDuration(100 * time.Millisecond)
+	jitter := ChaosDuration(10 * time.Millisecond)
+	half := func() float64 { return 0.5 }
+	require.Equal(t, 100*time.Millisecond, ChaosLatency{Mean: mean}.sample(half))
+	require.Equal(t, 100*time.Millisecond, ChaosLatency{Distribution: "uniform", Mean: mean, Jitter: jitter}.sample(half))
+	require.Equal(t, 90*time.Millisecond, ChaosLatency{Distribution: "uniform", Mean: mean, Jitter: jitter}.sample(func() float64 { return 0 }))
+	require.InDelta(t, float64(69*time.Millisecond), float64(ChaosLatency{Distribution: "exponential", Mean: mean}.sample(half)), float64(time.Millisecond))
+	d := ChaosLatency{Distribution: "normal", Mean: mean, Jitter: jitter}.sample(half)
+	require.InDelta(t, float64(100*time.Millisecond), float64(d), float64(3*jitter))
+}
+
+func TestChaosAdmin(t *testing.T) {
+	c, err := NewChaos(testShadowFinder, testMetrics{mp: sdkmetric.NewMeterProvider()})
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	c.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/chaos", strings.NewReader(`{
+		"enabled": true,
+		"operations": {
+			"*": {"latency": {"rate": 0.5, "distribution": "normal", "mean": "200ms", "jitter": "50ms"}},
+			"addPet": {"error_rate": 0.1, "error_status": [502, 503]}
+		}
+	}`)))
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	cfg := c.Config()
+	require.True(t, cfg.Enabled)
+	require.Equal(t, ChaosDuration(200*time.Millisecond), cfg.Operations["*"].Latency.Mean)
+	require.Equal(t, []int{502, 503}, cfg.Operations["addPet"].ErrorStatus)
+
+	rule, ok := c.rule("getPetById")
+	require.True(t, ok)
+	require.Equal(t, 0.5, rule.Latency.Rate)
+
+	rw = httptest.NewRecorder()
+	c.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/chaos", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Contains(t, rw.Body.String(), `"mean": "200ms"`)
+
+	rw = httptest.NewRecorder()
+	c.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/chaos", strings.NewReader(`{
+		"enabled": true,
+		"operations": {"addPet": {"error_rate": 2, "error_status": [200]}}
+	}`)))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	require.Contains(t, rw.Body.String(), "addPet: error_rate: must be in [0, 1]")
+	require.Contains(t, rw.Body.String(), "error_status: 200 is not an error status")
+	// Previous config is kept.
+	require.Equal(t, cfg, c.Config())
+}
@@ -3,10 +3,14 @@ package httpmiddleware
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-faster/sdk/zctx"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +20,9 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
+
+	"example/internal/oteltest"
 )
 
 type testHandler struct{}
@@ -176,7 +183,198 @@ func TestInstrumentation(t *testing.T) {
 	provider.Flush()
 	spans := provider.Exporter.GetSpans()
 	assert.Len(t, spans, 3)
-	for _, s := range spans {
-		t.Logf("%s [%s]", s.Name, s.SpanContext.TraceID())
+	oteltest.RequireSpans(t, spans, oteltest.Span{
+		Name: "otelhttp.Middleware",
+		Kind: trace.SpanKindServer,
+		Attributes: map[string]any{
+			"http.request.method":       http.MethodGet,
+			"http.response.status_code": http.StatusOK,
+		},
+		Children: []oteltest.Span{
+			{Name: "After", Children: []oteltest.Span{{Name: "Handler"}}},
+		},
+	})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	var caller string
+	h := Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller, _ = Caller(r.Context())
+		}),
+		APIKeyAuth("X-API-Key", []string{"foo", "bar"}),
+	)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, http.StatusUnauthorized, rw.Code)
+	require.Empty(t, caller)
+
+	rw = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("X-API-Key", "bar")
+	h.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, KeyIdentity("bar"), caller)
+	require.NotContains(t, caller, "bar")
+}
+
+func TestRateLimit(t *testing.T) {
+	h := Wrap(&testHandler{},
+		RateLimit(rate.NewLimiter(rate.Every(time.Hour), 1)),
+	)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, http.StatusTooManyRequests, rw.Code)
+	require.Equal(t, "3600", rw.Header().Get("Retry-After"))
+}
+
+func TestReloadable(t *testing.T) {
+	header := func(v string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", v)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	r := NewReloadable(header("first"))
+	h := Wrap(&testHandler{}, r.Middleware)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, "first", rw.Header().Get("X-Test"))
+
+	r.Store(header("second"))
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, "second", rw.Header().Get("X-Test"))
+
+	r.Store()
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Empty(t, rw.Header().Get("X-Test"))
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	var (
+		calls   int
+		release = make(chan struct{})
+		started = make(chan struct{}, 1)
+	)
+	c := NewIdempotencyCache(time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	h := Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.URL.Path == "/slow" {
+				started <- struct{}{}
+				<-release
+			}
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Call", strconv.Itoa(calls))
+			_, _ = w.Write(body)
+		}),
+		c.Middleware,
+	)
+	do := func(path, key, body string, caller string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if caller != "" {
+			req = req.WithContext(WithCaller(req.Context(), caller))
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		return rw
 	}
+
+	rw := do("/pet", "a", "tom", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "1", rw.Header().Get("X-Call"))
+
+	// Replay.
+	rw = do("/pet", "a", "tom", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "1", rw.Header().Get("X-Call"))
+	require.Equal(t, "true", rw.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, "tom", rw.Body.String())
+
+	// Different body.
+	rw = do("/pet", "a", "jerry", "")
+	require.Equal(t, http.StatusUnprocessableEntity, rw.Code)
+
+	// Key is scoped to caller.
+	rw = do("/pet", "a", "jerry", "key:1")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "2", rw.Header().Get("X-Call"))
+
+	// No key.
+	rw = do("/pet", "", "tom", "")
+	require.Equal(t, "3", rw.Header().Get("X-Call"))
+
+	// Expired.
+	now = now.Add(2 * time.Minute)
+	rw = do("/pet", "a", "tom", "")
+	require.Equal(t, "4", rw.Header().Get("X-Call"))
+	require.Empty(t, rw.Header().Get(IdempotentReplayedHeader))
+
+	// Concurrent duplicate.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- do("/slow", "b", "tom", "") }()
+	<-started
+	rw = do("/slow", "b", "tom", "")
+	require.Equal(t, http.StatusConflict, rw.Code)
+	close(release)
+	require.Equal(t, http.StatusOK, (<-done).Code)
+
+	// Too large body.
+	c.SetMaxBodySize(3)
+	rw = do("/pet", "c", "jerry", "")
+	require.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+	rw = do("/pet", "c", "tom", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestIdempotencyCacheEviction(t *testing.T) {
+	var calls int
+	c := NewIdempotencyCache(time.Minute)
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	do := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pet", http.NoBody)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		return rw
+	}
+
+	// Room for two responses.
+	c.SetMaxBytes(250)
+	for _, key := range []string{"a", "b", "c"} {
+		do(key)
+	}
+	require.Equal(t, 3, calls)
+	require.Len(t, c.entries, 2)
+	require.LessOrEqual(t, c.bytes, int64(250))
+
+	// The oldest is evicted, others are replayed.
+	require.Equal(t, "true", do("c").Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, "true", do("b").Header().Get(IdempotentReplayedHeader))
+	require.Empty(t, do("a").Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, 4, calls)
+
+	// Shrinking limit evicts immediately.
+	c.SetMaxBytes(0)
+	require.Empty(t, c.entries)
+	require.Zero(t, c.bytes)
 }
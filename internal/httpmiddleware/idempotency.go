@@ -0,0 +1,300 @@
+package httpmiddleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+const (
+	// IdempotencyKeyHeader is a header carrying idempotency key of request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyMaxBodySize is a default limit of request body.
+	DefaultIdempotencyMaxBodySize = 1 << 20
+	// DefaultIdempotencyMaxBytes is a default limit of stored responses size.
+	DefaultIdempotencyMaxBytes = 64 << 20
+
+	maxIdempotencyKeyLength = 255
+)
+
+type idempotencyEntry struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	inFlight    bool
+	expires     time.Time
+
+	status int
+	header http.Header
+	body   []byte
+
+	// size is an approximate size of stored response and elem is its
+	// element in IdempotencyCache.order, set when request is finished.
+	size int64
+	elem *list.Element
+}
+
+// IdempotencyCache stores first response per idempotency key and replays it
+// for repeated requests.
+//
+// Keys are scoped to caller identity, see Caller. If size of stored
+// responses exceeds the limit, the oldest ones are evicted.
+type IdempotencyCache struct {
+	now func() time.Time
+
+	mux      sync.Mutex
+	ttl      time.Duration
+	maxBody  int64
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*idempotencyEntry
+	// order are finished entries, the oldest first.
+	order *list.List
+}
+
+// NewIdempotencyCache creates new IdempotencyCache keeping responses for ttl.
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:      ttl,
+		maxBody:  DefaultIdempotencyMaxBodySize,
+		maxBytes: DefaultIdempotencyMaxBytes,
+		now:      time.Now,
+		entries:  map[string]*idempotencyEntry{},
+		order:    list.New(),
+	}
+}
+
+// SetTTL sets how long new responses are kept.
+func (c *IdempotencyCache) SetTTL(ttl time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.ttl = ttl
+}
+
+// SetMaxBodySize sets limit of request body, larger requests are rejected
+// with 413.
+func (c *IdempotencyCache) SetMaxBodySize(n int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.maxBody = n
+}
+
+// SetMaxBytes sets limit of stored responses size, evicting the oldest
+// ones if needed.
+func (c *IdempotencyCache) SetMaxBytes(n int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.maxBytes = n
+	c.evict()
+}
+
+// remove removes entry, must be called with c.mux held.
+func (c *IdempotencyCache) remove(e *idempotencyEntry) {
+	delete(c.entries, e.key)
+	if e.elem != nil {
+		c.order.Remove(e.elem)
+		c.bytes -= e.size
+	}
+}
+
+// evict removes the oldest entries while size exceeds the limit, must be
+// called with c.mux held.
+func (c *IdempotencyCache) evict() {
+	for c.bytes > c.maxBytes && c.order.Len() > 0 {
+		c.remove(c.order.Front().Value.(*idempotencyEntry))
+	}
+}
+
+// sweep removes expired entries from the oldest ones, must be called with
+// c.mux held.
+func (c *IdempotencyCache) sweep(now time.Time) {
+	for c.order.Len() > 0 {
+		e := c.order.Front().Value.(*idempotencyEntry)
+		if !now.After(e.expires) {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+// begin registers request with given key, returning existing entry if any.
+func (c *IdempotencyCache) begin(key string, fingerprint [sha256.Size]byte) (existing idempotencyEntry, ok bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+	if e, ok := c.entries[key]; ok {
+		if e.inFlight || now.Before(e.expires) {
+			return *e, true
+		}
+		c.remove(e)
+	}
+	c.entries[key] = &idempotencyEntry{
+		key:         key,
+		fingerprint: fingerprint,
+		inFlight:    true,
+	}
+	return existing, false
+}
+
+// finish stores response of request with given key.
+//
+// Server errors are not stored, so request can be retried.
+func (c *IdempotencyCache) finish(key string, rec *ResponseRecorder) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	if rec.status >= http.StatusInternalServerError {
+		c.remove(e)
+		return
+	}
+	e.inFlight = false
+	e.expires = c.now().Add(c.ttl)
+	e.status = rec.status
+	e.header = rec.Header().Clone()
+	e.body = rec.body.Bytes()
+
+	e.size = int64(len(e.key) + len(e.body))
+	for k, values := range e.header {
+		for _, v := range values {
+			e.size += int64(len(k) + len(v))
+		}
+	}
+	e.elem = c.order.PushBack(e)
+	c.bytes += e.size
+	c.evict()
+}
+
+// abort removes in-flight entry, e.g. if handler panicked.
+func (c *IdempotencyCache) abort(key string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[key]; ok && e.inFlight {
+		c.remove(e)
+	}
+}
+
+// Middleware handles POST requests having an idempotency key.
+//
+// Concurrent request with the same key is rejected with 409 and reuse of
+// the key with a different request is rejected with 422.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+
+		c.mux.Lock()
+		maxBody := c.maxBody
+		c.mux.Unlock()
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		caller, _ := Caller(r.Context())
+		scoped := caller + "\x00" + key
+
+		h := sha256.New()
+		_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+		_, _ = h.Write(body)
+		var fingerprint [sha256.Size]byte
+		h.Sum(fingerprint[:0])
+
+		e, ok := c.begin(scoped, fingerprint)
+		switch {
+		case !ok:
+		case e.fingerprint != fingerprint:
+			http.Error(w, "idempotency key reused with different request", http.StatusUnprocessableEntity)
+			return
+		case e.inFlight:
+			http.Error(w, "request with the same idempotency key is in progress", http.StatusConflict)
+			return
+		default:
+			for k, v := range e.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.Header().Set("Content-Length", strconv.Itoa(len(e.body)))
+			w.WriteHeader(e.status)
+			_, _ = w.Write(e.body)
+			return
+		}
+
+		rec := NewResponseRecorder(w)
+		defer func() {
+			if rec.done {
+				c.finish(scoped, rec)
+			} else {
+				c.abort(scoped)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		rec.done = true
+	})
+}
+
+// ResponseRecorder writes response while recording it.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	done        bool
+}
+
+// NewResponseRecorder creates new ResponseRecorder writing to w.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns written status code.
+func (r *ResponseRecorder) Status() int { return r.status }
+
+// Body returns written body.
+func (r *ResponseRecorder) Body() []byte { return r.body.Bytes() }
+
+func (r *ResponseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *ResponseRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,30 @@
+package httpmiddleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit rejects requests exceeding given limiter with 429.
+func RateLimit(limiter *rate.Limiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Reserve()
+			if delay := res.Delay(); delay > 0 {
+				res.Cancel()
+				retryAfter := int(math.Ceil(delay.Seconds()))
+				if delay == rate.InfDuration {
+					retryAfter = int(time.Minute.Seconds())
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
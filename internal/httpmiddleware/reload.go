@@ -0,0 +1,56 @@
+package httpmiddleware
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Reloadable is a middleware chain that can be atomically replaced at runtime.
+//
+// Use Reloadable.Middleware in Wrap and Reloadable.Store to swap the chain.
+type Reloadable struct {
+	mux         sync.Mutex
+	middlewares []Middleware
+	handlers    []*reloadableHandler
+}
+
+type reloadableHandler struct {
+	next    http.Handler
+	current atomic.Pointer[http.Handler]
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
+
+// NewReloadable creates new Reloadable with given initial chain.
+func NewReloadable(middlewares ...Middleware) *Reloadable {
+	return &Reloadable{middlewares: middlewares}
+}
+
+// Middleware wraps next with current chain.
+func (r *Reloadable) Middleware(next http.Handler) http.Handler {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	h := &reloadableHandler{next: next}
+	wrapped := Wrap(next, r.middlewares...)
+	h.current.Store(&wrapped)
+	r.handlers = append(r.handlers, h)
+	return h
+}
+
+// Store replaces current chain.
+//
+// Requests in flight finish with the previous chain.
+func (r *Reloadable) Store(middlewares ...Middleware) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.middlewares = middlewares
+	for _, h := range r.handlers {
+		wrapped := Wrap(h.next, middlewares...)
+		h.current.Store(&wrapped)
+	}
+}
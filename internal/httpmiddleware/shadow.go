@@ -0,0 +1,383 @@
+package httpmiddleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// ShadowHeader is set on mirrored requests, so candidate can tell them apart.
+const ShadowHeader = "X-Shadow-Request"
+
+// maxShadowDiffs is a maximum number of reported differences per response.
+const maxShadowDiffs = 10
+
+// DefaultShadowMaxBodySize is a default limit of mirrored request body.
+const DefaultShadowMaxBodySize = 1 << 20
+
+// ShadowConfig configures Shadow.
+type ShadowConfig struct {
+	// Target is base URL of candidate server.
+	//
+	// Required.
+	Target *url.URL
+	// SampleRate is a fraction of requests mirrored to candidate.
+	SampleRate float64
+	// OperationRates overrides SampleRate per operation ID.
+	OperationRates map[string]float64
+	// Writes enables mirroring of requests other than GET and HEAD.
+	//
+	// Candidate should use separate storage, otherwise writes are applied twice.
+	Writes bool
+	// IgnoreFields are JSON object field names ignored on comparison,
+	// at any depth.
+	IgnoreFields []string
+	// MaxInFlight is a maximum number of concurrent mirrored requests,
+	// requests over it are not mirrored.
+	//
+	// Defaults to 64.
+	MaxInFlight int
+	// MaxBodySize limits body of mirrored requests, which is read to memory,
+	// larger requests are not mirrored.
+	//
+	// Defaults to DefaultShadowMaxBodySize.
+	MaxBodySize int64
+	// Client sends mirrored requests.
+	//
+	// Defaults to client with 10s timeout.
+	Client *http.Client
+}
+
+func (c *ShadowConfig) setDefaults() {
+	if c.MaxInFlight <= 0 {
+		c.MaxInFlight = 64
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = DefaultShadowMaxBodySize
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+}
+
+// shadow mirrors requests to candidate.
+type shadow struct {
+	cfg        ShadowConfig
+	find       RouteFinder
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	requests   metric.Int64Counter
+	sem        chan struct{}
+	// wait is called when mirrored request is done, used in tests.
+	wait func()
+}
+
+// Shadow asynchronously mirrors sampled requests to candidate server
+// and compares its responses with primary ones.
+//
+// Primary response is never affected. Differences of status and JSON body
+// are logged and counted by operation ID. Mirrored request has its own trace
+// linked to the primary one.
+func Shadow(find RouteFinder, m Metrics, cfg ShadowConfig) (Middleware, error) {
+	s, err := newShadow(find, m, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s.Middleware, nil
+}
+
+func newShadow(find RouteFinder, m Metrics, cfg ShadowConfig) (*shadow, error) {
+	cfg.setDefaults()
+	if cfg.Target == nil {
+		return nil, errors.New("target is required")
+	}
+	requests, err := m.MeterProvider().Meter("example/internal/httpmiddleware").Int64Counter(
+		"http.server.shadow.requests",
+		metric.WithDescription("Number of mirrored requests by comparison result"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "requests counter")
+	}
+	return &shadow{
+		cfg:        cfg,
+		find:       find,
+		tracer:     m.TracerProvider().Tracer("example/internal/httpmiddleware"),
+		propagator: m.TextMapPropagator(),
+		requests:   requests,
+		sem:        make(chan struct{}, cfg.MaxInFlight),
+		wait:       func() {},
+	}, nil
+}
+
+// sampled reports whether request of operation should be mirrored.
+func (s *shadow) sampled(r *http.Request, op string) bool {
+	if !s.cfg.Writes && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	rate, ok := s.cfg.OperationRates[op]
+	if !ok {
+		rate = s.cfg.SampleRate
+	}
+	// #nosec G404
+	return rate > 0 && rand.Float64() < rate
+}
+
+func (s *shadow) count(ctx context.Context, op, result string) {
+	s.requests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("oas.operation", op),
+		attribute.String("shadow.result", result),
+	))
+}
+
+// Middleware implements Middleware.
+func (s *shadow) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := s.find(r.Method, r.URL)
+		if !ok || !s.sampled(r, route.OperationID()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op := route.OperationID()
+		ctx := r.Context()
+
+		select {
+		case s.sem <- struct{}{}:
+		default:
+			s.count(ctx, op, "dropped")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok, err := s.readBody(r)
+		if err != nil {
+			<-s.sem
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			<-s.sem
+			s.count(ctx, op, "too_large")
+			next.ServeHTTP(w, r)
+			return
+		}
+		req, err := s.request(r, body)
+		if err != nil {
+			<-s.sem
+			zctx.From(ctx).Warn("Failed to create shadow request", zap.Error(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Shadow trace is linked to primary and vice versa.
+		primary := trace.SpanFromContext(ctx)
+		shadowCtx, span := s.tracer.Start(context.WithoutCancel(ctx), "shadow "+op,
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithLinks(trace.LinkFromContext(ctx)),
+			trace.WithAttributes(attribute.String("oas.operation", op)),
+		)
+		primary.AddEvent("shadow", trace.WithAttributes(
+			attribute.String("shadow.trace_id", span.SpanContext().TraceID().String()),
+		))
+
+		rec := NewResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		go func() {
+			defer s.wait()
+			defer func() { <-s.sem }()
+			defer span.End()
+			s.compare(shadowCtx, op, req.WithContext(shadowCtx), primary.SpanContext(), rec)
+		}()
+	})
+}
+
+// readBody reads request body up to MaxBodySize, so that primary handler can
+// read it again. Larger body is not buffered and not mirrored.
+func (s *shadow) readBody(r *http.Request) ([]byte, bool, error) {
+	if r.ContentLength > s.cfg.MaxBodySize {
+		return nil, false, nil
+	}
+	prefix, err := io.ReadAll(io.LimitReader(r.Body, s.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	rest := r.Body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), rest), rest}
+	if int64(len(prefix)) > s.cfg.MaxBodySize {
+		return nil, false, nil
+	}
+	return prefix, true, nil
+}
+
+// request creates mirrored request without context.
+func (s *shadow) request(r *http.Request, body []byte) (*http.Request, error) {
+	// Escaped path is kept as is, so that escaped slashes in path
+	// parameters are not decoded.
+	u := *s.cfg.Target
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + r.URL.EscapedPath()
+	p, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unescape path")
+	}
+	u.Path = p
+	u.RawQuery = r.URL.RawQuery
+	req, err := http.NewRequest(r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range []string{
+		"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+		"Traceparent", "Tracestate",
+	} {
+		req.Header.Del(h)
+	}
+	req.Header.Set(ShadowHeader, "true")
+	return req, nil
+}
+
+// compare sends mirrored request and compares response with primary one.
+func (s *shadow) compare(
+	ctx context.Context,
+	op string,
+	req *http.Request,
+	primary trace.SpanContext,
+	rec *ResponseRecorder,
+) {
+	span := trace.SpanFromContext(ctx)
+	lg := zctx.From(ctx).With(
+		zap.String("operation", op),
+		zap.Stringer("trace_id", primary.TraceID()),
+		zap.Stringer("shadow.trace_id", span.SpanContext().TraceID()),
+	)
+	s.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "request failed")
+		s.count(ctx, op, "error")
+		lg.Warn("Shadow request failed", zap.Error(err))
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "read body")
+		s.count(ctx, op, "error")
+		lg.Warn("Failed to read shadow response", zap.Error(err))
+		return
+	}
+
+	var diffs []string
+	if rec.status != resp.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status: %d != %d", rec.status, resp.StatusCode))
+	}
+	diffs = append(diffs, diffBodies(rec.body.Bytes(), body, s.cfg.IgnoreFields)...)
+	if len(diffs) == 0 {
+		s.count(ctx, op, "match")
+		return
+	}
+	if len(diffs) > maxShadowDiffs {
+		diffs = append(diffs[:maxShadowDiffs], fmt.Sprintf("and %d more", len(diffs)-maxShadowDiffs))
+	}
+	span.AddEvent("shadow.diff", trace.WithAttributes(attribute.StringSlice("shadow.diff", diffs)))
+	s.count(ctx, op, "diff")
+	lg.Warn("Shadow response differs", zap.Strings("diff", diffs))
+}
+
+// diffBodies returns differences of JSON bodies, non-JSON bodies are compared
+// byte by byte.
+func diffBodies(primary, candidate []byte, ignore []string) []string {
+	decode := func(data []byte) (any, bool) {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		var v any
+		if err := d.Decode(&v); err != nil || d.More() {
+			return nil, false
+		}
+		return v, true
+	}
+	a, okA := decode(primary)
+	b, okB := decode(candidate)
+	if !okA || !okB {
+		if bytes.Equal(primary, candidate) {
+			return nil
+		}
+		return []string{"body"}
+	}
+	var diffs []string
+	diffJSON("$", a, b, ignore, &diffs)
+	return diffs
+}
+
+// diffJSON appends paths of differing values to diffs.
+func diffJSON(path string, a, b any, ignore []string, diffs *[]string) {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if slices.Contains(ignore, k) {
+				continue
+			}
+			diffJSON(path+"."+k, a[k], b[k], ignore, diffs)
+		}
+		return
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		if len(a) != len(b) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: length %d != %d", path, len(a), len(b)))
+			return
+		}
+		for i := range a {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), a[i], b[i], ignore, diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, path)
+	}
+}
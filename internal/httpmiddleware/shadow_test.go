@@ -0,0 +1,272 @@
+package httpmiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testMetrics struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+func (m testMetrics) TracerProvider() trace.TracerProvider { return m.tp }
+func (m testMetrics) MeterProvider() metric.MeterProvider  { return m.mp }
+func (m testMetrics) TextMapPropagator() propagation.TextMapPropagator {
+	return propagation.TraceContext{}
+}
+
+type testShadowRoute string
+
+func (r testShadowRoute) Name() string        { return string(r) }
+func (r testShadowRoute) OperationID() string { return string(r) }
+func (r testShadowRoute) PathPattern() string { return "/pet" }
+
+func testShadowFinder(method string, u *url.URL) (Route, bool) {
+	switch {
+	case method == http.MethodGet && strings.HasPrefix(u.Path, "/pet/"):
+		return testShadowRoute("getPetById"), true
+	case method == http.MethodPost && u.Path == "/pet":
+		return testShadowRoute("addPet"), true
+	default:
+		return nil, false
+	}
+}
+
+func TestShadow(t *testing.T) {
+	var (
+		mux       sync.Mutex
+		mirrored  []*http.Request
+		responses = map[string]string{
+			"/pet/1": `{"id":1,"name":"Tom","updated":"candidate"}`,
+			"/pet/2": `{"id":2,"name":"Spike","tags":["dog"]}`,
+		}
+	)
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		mirrored = append(mirrored, r)
+		mux.Unlock()
+		_, _ = io.WriteString(w, responses[r.URL.Path])
+	}))
+	t.Cleanup(candidate.Close)
+	target, err := url.Parse(candidate.URL)
+	require.NoError(t, err)
+
+	provider := NewProvider()
+	reader := sdkmetric.NewManualReader()
+	s, err := newShadow(testShadowFinder, testMetrics{
+		tp: provider,
+		mp: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	}, ShadowConfig{
+		Target:       target,
+		SampleRate:   1,
+		IgnoreFields: []string{"updated"},
+	})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	s.wait = wg.Done
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	tracer := provider.Tracer("test")
+	h := Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/pet/1":
+				_, _ = io.WriteString(w, `{"id":1,"name":"Tom","updated":"primary"}`)
+			case "/pet/2":
+				_, _ = io.WriteString(w, `{"id":2,"name":"Spike","tags":["dog","bulldog"]}`)
+			default:
+				w.WriteHeader(http.StatusCreated)
+			}
+		}),
+		InjectLogger(zap.New(core)),
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx, span := tracer.Start(r.Context(), "primary")
+				defer span.End()
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		},
+		s.Middleware,
+	)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	wg.Add(2)
+	require.Equal(t, `{"id":1,"name":"Tom","updated":"primary"}`, serve(http.MethodGet, "/pet/1?q=1", "").Body.String())
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/pet/2", "").Code)
+	// Writes are not mirrored by default.
+	require.Equal(t, http.StatusCreated, serve(http.MethodPost, "/pet", `{"name":"Tom"}`).Code)
+	wg.Wait()
+
+	mux.Lock()
+	require.Len(t, mirrored, 2)
+	for _, r := range mirrored {
+		require.Equal(t, "true", r.Header.Get(ShadowHeader))
+		require.NotEmpty(t, r.Header.Get("Traceparent"))
+	}
+	mux.Unlock()
+
+	// Only the second response differs.
+	entries := logs.FilterMessage("Shadow response differs").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "getPetById", fields["operation"])
+	require.Equal(t, []any{"$.tags: length 2 != 1"}, fields["diff"])
+	require.NotEqual(t, fields["trace_id"], fields["shadow.trace_id"])
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	results := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				v, _ := dp.Attributes.Value("shadow.result")
+				results[v.AsString()] += dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{"match": 1, "diff": 1}, results)
+
+	// Primary and shadow traces are linked.
+	provider.Flush()
+	var primary, shadow []trace.SpanContext
+	for _, span := range provider.Exporter.GetSpans() {
+		switch span.Name {
+		case "primary":
+			if len(span.Events) > 0 {
+				primary = append(primary, span.SpanContext)
+			}
+		case "shadow getPetById":
+			require.Len(t, span.Links, 1)
+			shadow = append(shadow, span.Links[0].SpanContext)
+		}
+	}
+	require.Len(t, primary, 2)
+	require.ElementsMatch(t, primary, shadow)
+}
+
+func TestDiffBodies(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want []string
+	}{
+		{`{"a":1}`, `{"a":1.0}`, []string{"$.a"}},
+		{`{"a":1,"b":[1,2]}`, `{"b":[1,2],"a":1}`, nil},
+		{`{"a":{"b":1}}`, `{"a":{"c":1}}`, []string{"$.a.b", "$.a.c"}},
+		{`[{"a":1}]`, `[{"a":2}]`, []string{"$[0].a"}},
+		{`not json`, `not json`, nil},
+		{`not json`, `{}`, []string{"body"}},
+	} {
+		require.Equal(t, tt.want, diffBodies([]byte(tt.a), []byte(tt.b), nil), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestShadowMaxBodySize(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		mirrored []string
+	)
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mux.Lock()
+		mirrored = append(mirrored, string(body))
+		mux.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(candidate.Close)
+	target, err := url.Parse(candidate.URL)
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	s, err := newShadow(testShadowFinder, testMetrics{
+		tp: NewProvider(),
+		mp: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	}, ShadowConfig{
+		Target:      target,
+		SampleRate:  1,
+		Writes:      true,
+		MaxBodySize: 3,
+	})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	s.wait = wg.Done
+
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Primary handler reads the whole body regardless of limit.
+		_, _ = io.Copy(w, r.Body)
+	}))
+	serve := func(body string, length int64) string {
+		req := httptest.NewRequest(http.MethodPost, "/pet", strings.NewReader(body))
+		req.ContentLength = length
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	wg.Add(1)
+	require.Equal(t, "tom", serve("tom", 3))
+	wg.Wait()
+	// Declared and actual length over limit.
+	require.Equal(t, "jerry", serve("jerry", 5))
+	require.Equal(t, "spike", serve("spike", -1))
+
+	mux.Lock()
+	require.Equal(t, []string{"tom"}, mirrored)
+	mux.Unlock()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	results := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				v, _ := dp.Attributes.Value("shadow.result")
+				results[v.AsString()] += dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{"diff": 1, "too_large": 2}, results)
+}
+
+func TestShadowRequestPath(t *testing.T) {
+	for _, tt := range []struct {
+		target, path, want string
+	}{
+		{"http://candidate", "/pet/1?q=1", "http://candidate/pet/1?q=1"},
+		{"http://candidate/api/", "/pet/1", "http://candidate/api/pet/1"},
+		// Escaped path parameters are kept.
+		{"http://candidate", "/pet/a%2Fb", "http://candidate/pet/a%2Fb"},
+		{"http://candidate/api", "/pet/100%25", "http://candidate/api/pet/100%25"},
+	} {
+		target, err := url.Parse(tt.target)
+		require.NoError(t, err)
+		s, err := newShadow(testShadowFinder, testMetrics{
+			tp: NewProvider(),
+			mp: sdkmetric.NewMeterProvider(),
+		}, ShadowConfig{Target: target})
+		require.NoError(t, err)
+
+		req, err := s.request(httptest.NewRequest(http.MethodGet, tt.path, http.NoBody), nil)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, req.URL.String(), tt.path)
+	}
+}
@@ -0,0 +1,235 @@
+package httpmiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+	"github.com/go-faster/sdk/zctx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"example/internal/oasspec"
+)
+
+// ResponseValidationConfig configures ValidateResponses.
+type ResponseValidationConfig struct {
+	// Spec is a spec responses are validated against.
+	//
+	// Required.
+	Spec *oasspec.Spec
+	// Strict replaces invalid responses with 500.
+	Strict bool
+	// IgnoreStatus lists undeclared status codes that are not violations,
+	// e.g. 400 returned by ogen for invalid requests.
+	IgnoreStatus []int
+}
+
+// Response violation kinds.
+const (
+	violationStatus      = "status"
+	violationContentType = "content_type"
+	violationBody        = "body"
+)
+
+// responseViolation is a mismatch of response and spec.
+type responseViolation struct {
+	Kind string
+	Msg  string
+}
+
+type responseValidator struct {
+	cfg        ResponseValidationConfig
+	find       RouteFinder
+	violations metric.Int64Counter
+}
+
+// ValidateResponses validates status code, content type and JSON body of
+// responses against spec, logging and counting violations by operation ID.
+//
+// Intended for development and staging: non-strict mode records response
+// while writing it, strict mode buffers the whole response.
+func ValidateResponses(find RouteFinder, m Metrics, cfg ResponseValidationConfig) (Middleware, error) {
+	if cfg.Spec == nil {
+		return nil, errors.New("spec is required")
+	}
+	violations, err := m.MeterProvider().Meter("example/internal/httpmiddleware").Int64Counter(
+		"http.server.response.violations",
+		metric.WithDescription("Number of responses violating spec by violation kind"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "violations counter")
+	}
+	v := &responseValidator{
+		cfg:        cfg,
+		find:       find,
+		violations: violations,
+	}
+	return v.Middleware, nil
+}
+
+// Middleware implements Middleware.
+func (v *responseValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := v.find(r.Method, r.URL)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op, ok := v.cfg.Spec.Operation(route.OperationID())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !v.cfg.Strict {
+			rec := NewResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+			v.report(r.Context(), op, rec.status, v.validate(op, rec.status, rec.Header(), rec.body.Bytes()))
+			return
+		}
+
+		buf := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		violations := v.validate(op, buf.status, buf.header, buf.body.Bytes())
+		v.report(r.Context(), op, buf.status, violations)
+		if len(violations) > 0 {
+			writeViolationError(w)
+			return
+		}
+		buf.flush(w)
+	})
+}
+
+// validate returns violations of response to operation.
+func (v *responseValidator) validate(op *oasspec.Operation, status int, header http.Header, body []byte) []responseViolation {
+	declared, ok := op.Response(status)
+	if !ok {
+		if slices.Contains(v.cfg.IgnoreStatus, status) {
+			return nil
+		}
+		return []responseViolation{{
+			Kind: violationStatus,
+			Msg:  fmt.Sprintf("status %d is not declared", status),
+		}}
+	}
+	if declared.Schema == nil {
+		if len(body) > 0 {
+			return []responseViolation{{
+				Kind: violationBody,
+				Msg:  "body is not declared",
+			}}
+		}
+		return nil
+	}
+
+	contentType := header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != declared.ContentType {
+		return []responseViolation{{
+			Kind: violationContentType,
+			Msg:  fmt.Sprintf("content type %q, expected %q", contentType, declared.ContentType),
+		}}
+	}
+	value, err := oasspec.DecodeJSON(body)
+	if err != nil {
+		return []responseViolation{{
+			Kind: violationBody,
+			Msg:  fmt.Sprintf("decode: %v", err),
+		}}
+	}
+	err = v.cfg.Spec.Validate(declared.Schema, value)
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	violations := make([]responseViolation, 0, len(errs))
+	for _, e := range errs {
+		violations = append(violations, responseViolation{
+			Kind: violationBody,
+			Msg:  e.Error(),
+		})
+	}
+	return violations
+}
+
+func (v *responseValidator) report(ctx context.Context, op *oasspec.Operation, status int, violations []responseViolation) {
+	if len(violations) == 0 {
+		return
+	}
+	msgs := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		msgs = append(msgs, violation.Msg)
+		v.violations.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("oas.operation", op.ID),
+			attribute.String("violation.kind", violation.Kind),
+		))
+	}
+	trace.SpanFromContext(ctx).AddEvent("response.violation", trace.WithAttributes(
+		attribute.StringSlice("response.violations", msgs),
+	))
+	zctx.From(ctx).Warn("Response violates spec",
+		zap.String("operation", op.ID),
+		zap.Int("status", status),
+		zap.Bool("strict", v.cfg.Strict),
+		zap.Strings("violations", msgs),
+	)
+}
+
+// writeViolationError writes 500 replacing invalid response.
+func writeViolationError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	e := jx.GetEncoder()
+	defer jx.PutEncoder(e)
+	e.ObjStart()
+	e.FieldStart("error_message")
+	e.Str("response violates spec")
+	e.ObjEnd()
+
+	_, _ = w.Write(e.Bytes())
+}
+
+// bufferedResponse buffers whole response until flush.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if !b.wroteHeader {
+		b.WriteHeader(http.StatusOK)
+	}
+	return b.body.Write(p)
+}
+
+// flush writes buffered response to w.
+func (b *bufferedResponse) flush(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	_, _ = w.Write(b.body.Bytes())
+}
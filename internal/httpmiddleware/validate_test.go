@@ -0,0 +1,118 @@
+package httpmiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-faster/sdk/zctx"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"example"
+	"example/internal/oasspec"
+)
+
+func TestValidateResponses(t *testing.T) {
+	spec, err := oasspec.Parse(example.OpenAPISpec)
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pet/1":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = io.WriteString(w, `{"id":1,"name":"Tom"}`)
+		case "/pet/2":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"id":2,"status":"lost"}`)
+		case "/pet/3":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = io.WriteString(w, `Tom`)
+		case "/pet/4":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusConflict)
+		}
+	})
+
+	for _, strict := range []bool{false, true} {
+		core, logs := observer.New(zapcore.WarnLevel)
+		reader := sdkmetric.NewManualReader()
+		mw, err := ValidateResponses(testShadowFinder, testMetrics{
+			tp: NewProvider(),
+			mp: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		}, ResponseValidationConfig{
+			Spec:         spec,
+			Strict:       strict,
+			IgnoreStatus: []int{http.StatusBadRequest},
+		})
+		require.NoError(t, err)
+		h := mw(handler)
+
+		for _, tt := range []struct {
+			Path      string
+			Status    int
+			Violation string
+		}{
+			{"/pet/1", http.StatusOK, ""},
+			{"/pet/2", http.StatusOK, `$: required field "name" is missing`},
+			{"/pet/3", http.StatusOK, `content type "text/plain", expected "application/json"`},
+			{"/pet/4", http.StatusBadRequest, ""},
+			{"/pet/5", http.StatusConflict, "status 409 is not declared"},
+		} {
+			_ = logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, tt.Path, nil)
+			req = req.WithContext(zctx.Base(req.Context(), zap.New(core)))
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			entries := logs.FilterMessage("Response violates spec").All()
+			if tt.Violation == "" {
+				require.Empty(t, entries, tt.Path)
+				require.Equal(t, tt.Status, rw.Code, tt.Path)
+				continue
+			}
+			require.Len(t, entries, 1, tt.Path)
+			fields := entries[0].ContextMap()
+			require.Equal(t, "getPetById", fields["operation"])
+			require.Contains(t, fields["violations"], tt.Violation)
+
+			if strict {
+				require.Equal(t, http.StatusInternalServerError, rw.Code, tt.Path)
+				require.JSONEq(t, `{"error_message":"response violates spec"}`, rw.Body.String())
+			} else {
+				require.Equal(t, tt.Status, rw.Code, tt.Path)
+			}
+		}
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		counts := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "http.server.response.violations" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					kind, _ := dp.Attributes.Value("violation.kind")
+					counts[kind.AsString()] += dp.Value
+				}
+			}
+		}
+		require.Equal(t, map[string]int64{
+			"body":         2,
+			"content_type": 1,
+			"status":       1,
+		}, counts)
+	}
+
+	_, err = ValidateResponses(testShadowFinder, testMetrics{mp: noop.NewMeterProvider()}, ResponseValidationConfig{})
+	require.Error(t, err)
+}
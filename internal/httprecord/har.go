@@ -0,0 +1,197 @@
+package httprecord
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HAR 1.2 types, see http://www.softwareishard.com/blog/har-12-spec/.
+//
+// Only fields needed to replay entries are read back, operation ID
+// is stored in custom "_operationId" field.
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	OperationID     string      `json:"_operationId,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	r := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			r = append(r, harNameValue{Name: name, Value: v})
+		}
+	}
+	return r
+}
+
+func fromHARHeaders(values []harNameValue) http.Header {
+	if len(values) == 0 {
+		return nil
+	}
+	h := http.Header{}
+	for _, v := range values {
+		h.Add(v.Name, v.Value)
+	}
+	return h
+}
+
+func harQuery(rawURL string) []harNameValue {
+	r := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			r = append(r, harNameValue{Name: name, Value: v})
+		}
+	}
+	return r
+}
+
+func newHAREntry(e Entry) harEntry {
+	ms := float64(e.Duration) / float64(time.Millisecond)
+	req := harRequest{
+		Method:      e.Request.Method,
+		URL:         e.Request.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(e.Request.Header),
+		QueryString: harQuery(e.Request.URL),
+		HeadersSize: -1,
+		BodySize:    len(e.Request.Body),
+	}
+	if e.Request.Body != "" {
+		req.PostData = &harPostData{
+			MimeType: e.Request.Header.Get("Content-Type"),
+			Text:     e.Request.Body,
+		}
+	}
+	return harEntry{
+		StartedDateTime: e.Time,
+		Time:            ms,
+		Request:         req,
+		Response: harResponse{
+			Status:      e.Response.Status,
+			StatusText:  http.StatusText(e.Response.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.Response.Header),
+			Content: harContent{
+				Size:     len(e.Response.Body),
+				MimeType: e.Response.Header.Get("Content-Type"),
+				Text:     e.Response.Body,
+			},
+			HeadersSize: -1,
+			BodySize:    len(e.Response.Body),
+		},
+		Timings:     harTimings{Wait: ms},
+		OperationID: e.OperationID,
+	}
+}
+
+func newHARLog(entries []Entry) harFile {
+	log := harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "example/internal/httprecord", Version: "1.0"},
+		Entries: make([]harEntry, 0, len(entries)),
+	}
+	for _, e := range entries {
+		log.Entries = append(log.Entries, newHAREntry(e))
+	}
+	return harFile{Log: log}
+}
+
+func (l harLog) entries() []Entry {
+	entries := make([]Entry, 0, len(l.Entries))
+	for _, h := range l.Entries {
+		e := Entry{
+			Time:        h.StartedDateTime,
+			Duration:    time.Duration(h.Time * float64(time.Millisecond)),
+			OperationID: h.OperationID,
+			Request: Request{
+				Method: h.Request.Method,
+				URL:    h.Request.URL,
+				Header: fromHARHeaders(h.Request.Headers),
+			},
+			Response: Response{
+				Status: h.Response.Status,
+				Header: fromHARHeaders(h.Response.Headers),
+				Body:   h.Response.Content.Text,
+			},
+		}
+		if h.Request.PostData != nil {
+			e.Request.Body = h.Request.PostData.Text
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
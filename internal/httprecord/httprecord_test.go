@@ -0,0 +1,163 @@
+package httprecord
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example/internal/api"
+	"example/internal/httpmiddleware"
+	"example/internal/oas"
+)
+
+// nopCloser is io.WriteCloser writing to buffer.
+type nopCloser struct {
+	*bytes.Buffer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	srv, err := oas.NewServer(api.NewHandler(api.NewMemoryStorage()))
+	require.NoError(t, err)
+	find := httpmiddleware.MakeRouteFinder(srv)
+
+	var serverLog bytes.Buffer
+	server := Recorder{
+		Sink: NewJSONL(nopCloser{&serverLog}),
+		Find: find,
+		Redaction: Redaction{
+			Headers: DefaultRedaction().Headers,
+			Fields:  []string{"photoUrls"},
+		},
+	}
+	s := httptest.NewServer(server.Middleware(srv))
+	t.Cleanup(s.Close)
+
+	// Record client traffic to HAR file.
+	harPath := filepath.Join(t.TempDir(), "traffic.har")
+	sink, err := Create(harPath)
+	require.NoError(t, err)
+	client := Recorder{
+		Sink:      sink,
+		Find:      find,
+		Redaction: DefaultRedaction(),
+	}
+	c, err := oas.NewClient(s.URL, oas.WithClient(&http.Client{
+		Transport: client.Transport(http.DefaultTransport),
+	}))
+	require.NoError(t, err)
+
+	added, err := c.AddPet(ctx, &oas.Pet{Name: "Tom", PhotoUrls: []string{"secret"}}, oas.AddPetParams{})
+	require.NoError(t, err)
+	tom := added.(*oas.Pet)
+	jerry, err := c.AddPet(ctx, &oas.Pet{Name: "Jerry"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	got, err := c.GetPetById(ctx, oas.GetPetByIdParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	_, err = c.DeletePet(ctx, oas.DeletePetParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	missing, err := c.GetPetById(ctx, oas.GetPetByIdParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	serverEntries, err := Read(&serverLog)
+	require.NoError(t, err)
+	require.Len(t, serverEntries, 5)
+	require.Equal(t, "addPet", serverEntries[0].OperationID)
+	require.Equal(t, "/pet", serverEntries[0].Request.URL)
+	require.Contains(t, serverEntries[0].Request.Body, `"photoUrls":"REDACTED"`)
+	require.NotContains(t, serverEntries[0].Response.Body, "secret")
+
+	entries, err := Load(harPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+	var ops []string
+	for _, e := range entries {
+		ops = append(ops, e.OperationID)
+	}
+	require.Equal(t, []string{"addPet", "addPet", "getPetById", "deletePet", "getPetById"}, ops)
+
+	// Replay without server.
+	replayer, err := NewReplayer(find, entries)
+	require.NoError(t, err)
+	offline, err := oas.NewClient("http://offline.invalid", oas.WithClient(&http.Client{
+		Transport: replayer,
+	}))
+	require.NoError(t, err)
+
+	// Bodies select matching recording regardless of order.
+	replayedJerry, err := offline.AddPet(ctx, &oas.Pet{Name: "Jerry"}, oas.AddPetParams{})
+	require.NoError(t, err)
+	require.Equal(t, jerry, replayedJerry)
+	replayedTom, err := offline.AddPet(ctx, &oas.Pet{Name: "Tom", PhotoUrls: []string{"secret"}}, oas.AddPetParams{})
+	require.NoError(t, err)
+	require.Equal(t, tom, replayedTom)
+
+	// Repeated requests are served in recording order.
+	replayed, err := offline.GetPetById(ctx, oas.GetPetByIdParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	require.Equal(t, got, replayed)
+	_, err = offline.DeletePet(ctx, oas.DeletePetParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	replayed, err = offline.GetPetById(ctx, oas.GetPetByIdParams{PetId: tom.ID.Value})
+	require.NoError(t, err)
+	require.Equal(t, missing, replayed)
+
+	_, err = offline.GetPetById(ctx, oas.GetPetByIdParams{PetId: 100500})
+	var noRecording *NoRecordingError
+	require.ErrorAs(t, err, &noRecording)
+}
+
+func TestRedaction(t *testing.T) {
+	r := Redaction{
+		Headers: []string{"x-api-key"},
+		Fields:  []string{"token"},
+	}
+	e := r.Apply(Entry{
+		Request: Request{
+			Header: http.Header{"X-Api-Key": {"secret"}, "Accept": {"application/json"}},
+			Body:   `{"items":[{"token":"secret","name":"a"}]}`,
+		},
+		Response: Response{Body: "not json"},
+	})
+	require.Equal(t, http.Header{"X-Api-Key": {Redacted}, "Accept": {"application/json"}}, e.Request.Header)
+	require.Equal(t, `{"items":[{"name":"a","token":"REDACTED"}]}`, e.Request.Body)
+	require.Equal(t, "not json", e.Response.Body)
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	var log bytes.Buffer
+	rec := Recorder{
+		Sink:        NewJSONL(nopCloser{&log}),
+		Find:        func(string, *url.URL) (httpmiddleware.Route, bool) { return nil, false },
+		MaxBodySize: 3,
+	}
+	h := rec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/pet", strings.NewReader("tom")))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "tom", rw.Body.String())
+
+	// Too large body is neither buffered nor recorded.
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/pet", strings.NewReader("jerry")))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+
+	entries, err := Read(&log)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "tom", entries[0].Request.Body)
+}
@@ -0,0 +1,123 @@
+package httprecord
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/sdk/zctx"
+	"go.uber.org/zap"
+
+	"example/internal/httpmiddleware"
+	"example/internal/httptransport"
+)
+
+// DefaultMaxBodySize is a default limit of served request body.
+const DefaultMaxBodySize = 1 << 20
+
+// Recorder writes redacted request and response pairs to Sink.
+type Recorder struct {
+	Sink      Sink
+	Find      httpmiddleware.RouteFinder
+	Redaction Redaction
+	// MaxBodySize limits body of served requests, which is read to memory,
+	// larger requests are rejected with 413. Zero means DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+func (rec Recorder) write(r *http.Request, reqURL string, reqBody []byte, start time.Time, resp Response) {
+	e := Entry{
+		Time:     start,
+		Duration: time.Since(start),
+		Request: Request{
+			Method: r.Method,
+			URL:    reqURL,
+			Header: r.Header,
+			Body:   string(reqBody),
+		},
+		Response: resp,
+	}
+	if route, ok := rec.Find(r.Method, r.URL); ok {
+		e.OperationID = route.OperationID()
+	}
+	if err := rec.Sink.Write(rec.Redaction.Apply(e)); err != nil {
+		zctx.From(r.Context()).Warn("Failed to record request", zap.Error(err))
+	}
+}
+
+// readBody reads body and replaces it with a copy.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	_ = (*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, err
+}
+
+// Middleware records served requests.
+func (rec Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		maxBody := rec.MaxBodySize
+		if maxBody <= 0 {
+			maxBody = DefaultMaxBodySize
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		}
+		body, err := readBody(&r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		rw := httpmiddleware.NewResponseRecorder(w)
+		next.ServeHTTP(rw, r)
+		rec.write(r, r.URL.RequestURI(), body, start, Response{
+			Status: rw.Status(),
+			Header: w.Header().Clone(),
+			Body:   string(rw.Body()),
+		})
+	})
+}
+
+// Transport records requests sent by client.
+//
+// Transport errors are not recorded.
+func (rec Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	return httptransport.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		var body []byte
+		if r.GetBody != nil {
+			b, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			if body, err = readBody(&b); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+		respBody, err := readBody(&resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		rec.write(r, r.URL.String(), body, start, Response{
+			Status: resp.StatusCode,
+			Header: resp.Header.Clone(),
+			Body:   string(respBody),
+		})
+		return resp, nil
+	})
+}
@@ -0,0 +1,248 @@
+// Package httprecord records HTTP traffic and replays it.
+package httprecord
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+)
+
+// Entry is a recorded request and response pair.
+type Entry struct {
+	Time        time.Time     `json:"time"`
+	Duration    time.Duration `json:"duration"`
+	OperationID string        `json:"operationId,omitempty"`
+	Request     Request       `json:"request"`
+	Response    Response      `json:"response"`
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Redacted replaces redacted values.
+const Redacted = "REDACTED"
+
+// Redaction configures what is redacted from recorded entries.
+type Redaction struct {
+	// Headers are redacted header names.
+	Headers []string
+	// Fields are redacted JSON object field names, at any depth.
+	Fields []string
+}
+
+// DefaultRedaction redacts credentials.
+func DefaultRedaction() Redaction {
+	return Redaction{
+		Headers: []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key"},
+	}
+}
+
+func (r Redaction) header(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range r.Headers {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := h[name]; ok {
+			h[name] = []string{Redacted}
+		}
+	}
+	return h
+}
+
+func (r Redaction) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if slices.Contains(r.Fields, k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = r.redactValue(field)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = r.redactValue(elem)
+		}
+	}
+	return v
+}
+
+func (r Redaction) body(body []byte) string {
+	if len(r.Fields) == 0 || !json.Valid(body) {
+		return string(body)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	data, err := json.Marshal(r.redactValue(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+// Apply returns redacted copy of entry.
+func (r Redaction) Apply(e Entry) Entry {
+	e.Request.Header = r.header(e.Request.Header)
+	e.Request.Body = r.body([]byte(e.Request.Body))
+	e.Response.Header = r.header(e.Response.Header)
+	e.Response.Body = r.body([]byte(e.Response.Body))
+	return e
+}
+
+// Sink writes recorded entries.
+type Sink interface {
+	Write(e Entry) error
+	Close() error
+}
+
+// JSONL writes entries as JSON lines.
+type JSONL struct {
+	mux sync.Mutex
+	w   io.WriteCloser
+}
+
+// NewJSONL creates new JSONL sink.
+func NewJSONL(w io.WriteCloser) *JSONL {
+	return &JSONL{w: w}
+}
+
+// Write implements Sink.
+func (s *JSONL) Write(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close implements Sink.
+func (s *JSONL) Close() error {
+	return s.w.Close()
+}
+
+// HAR collects entries and writes them as HAR 1.2 log on Close.
+//
+// Entries are kept in memory, so HAR suits short runs only. Record long
+// running traffic as JSONL and convert it with WriteHAR.
+type HAR struct {
+	mux     sync.Mutex
+	w       io.WriteCloser
+	entries []Entry
+}
+
+// NewHAR creates new HAR sink.
+func NewHAR(w io.WriteCloser) *HAR {
+	return &HAR{w: w}
+}
+
+// Write implements Sink.
+func (s *HAR) Write(e Entry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Close implements Sink.
+func (s *HAR) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := WriteHAR(s.w, s.entries); err != nil {
+		_ = s.w.Close()
+		return err
+	}
+	return s.w.Close()
+}
+
+// WriteHAR writes entries as HAR 1.2 log.
+func WriteHAR(w io.Writer, entries []Entry) error {
+	data, err := json.MarshalIndent(newHARLog(entries), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode")
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// IsHAR reports whether file should use HAR format.
+func IsHAR(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".har")
+}
+
+// Create creates sink writing to file, HAR if file has ".har" extension
+// and JSONL otherwise.
+func Create(name string) (Sink, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if IsHAR(name) {
+		return NewHAR(f), nil
+	}
+	return NewJSONL(f), nil
+}
+
+// Read reads entries from HAR or JSONL data.
+func Read(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	// Single JSON line is valid JSON too, so check HAR version.
+	if json.Valid(trimmed) {
+		var h harFile
+		if err := json.Unmarshal(trimmed, &h); err == nil && h.Log.Version != "" {
+			return h.Log.entries(), nil
+		}
+	}
+
+	entries := []Entry{}
+	for i, line := range bytes.Split(trimmed, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, errors.Wrapf(err, "line %d", i+1)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Load reads entries from HAR or JSONL file.
+func Load(name string) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return Read(f)
+}
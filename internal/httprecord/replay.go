@@ -0,0 +1,137 @@
+package httprecord
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-faster/errors"
+
+	"example/internal/httpmiddleware"
+)
+
+// NoRecordingError is returned by Replayer if there is no recorded
+// response for request.
+type NoRecordingError struct {
+	Method string
+	URL    string
+}
+
+func (e *NoRecordingError) Error() string {
+	return fmt.Sprintf("no recorded response for %s %s", e.Method, e.URL)
+}
+
+// replayQueue is a list of recorded entries of the same request.
+type replayQueue struct {
+	entries []Entry
+	used    []bool
+	last    int
+}
+
+// take returns first unused entry, preferring one with the same body.
+//
+// If every entry is used, last returned entry is repeated.
+func (q *replayQueue) take(body string) Entry {
+	idx := -1
+	for i, e := range q.entries {
+		if q.used[i] {
+			continue
+		}
+		if e.Request.Body == body {
+			idx = i
+			break
+		}
+		if idx < 0 {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return q.entries[q.last]
+	}
+	q.used[idx] = true
+	q.last = idx
+	return q.entries[idx]
+}
+
+// Replayer is http.RoundTripper serving recorded responses.
+//
+// Requests are matched by operation ID, path and query parameters.
+// Repeated requests get recorded responses in recording order.
+type Replayer struct {
+	find httpmiddleware.RouteFinder
+
+	mux    sync.Mutex
+	queues map[string]*replayQueue
+}
+
+// NewReplayer creates new Replayer.
+func NewReplayer(find httpmiddleware.RouteFinder, entries []Entry) (*Replayer, error) {
+	r := &Replayer{
+		find:   find,
+		queues: map[string]*replayQueue{},
+	}
+	for i, e := range entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "entry %d: parse url", i)
+		}
+		key := r.key(e.Request.Method, u)
+		q, ok := r.queues[key]
+		if !ok {
+			q = &replayQueue{}
+			r.queues[key] = q
+		}
+		q.entries = append(q.entries, e)
+		q.used = append(q.used, false)
+	}
+	return r, nil
+}
+
+// key returns matching key of request.
+func (r *Replayer) key(method string, u *url.URL) string {
+	op := method
+	if route, ok := r.find(method, u); ok {
+		op = route.OperationID()
+	}
+	// Encode sorts query parameters by key.
+	return strings.Join([]string{op, u.EscapedPath(), u.Query().Encode()}, " ")
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body string
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+
+	r.mux.Lock()
+	q, ok := r.queues[r.key(req.Method, req.URL)]
+	var e Entry
+	if ok {
+		e = q.take(body)
+	}
+	r.mux.Unlock()
+	if !ok {
+		return nil, &NoRecordingError{Method: req.Method, URL: req.URL.String()}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, http.StatusText(e.Response.Status)),
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(e.Response.Body)),
+		ContentLength: int64(len(e.Response.Body)),
+		Request:       req,
+	}, nil
+}
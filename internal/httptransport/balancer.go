@@ -0,0 +1,421 @@
+package httptransport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BalanceStrategy selects endpoint for request.
+type BalanceStrategy string
+
+// Balancing strategies.
+const (
+	RoundRobin       BalanceStrategy = "round-robin"
+	LeastOutstanding BalanceStrategy = "least-outstanding"
+)
+
+// BalancerConfig configures Balancer.
+type BalancerConfig struct {
+	// Strategy selects endpoint for request.
+	//
+	// Defaults to RoundRobin.
+	Strategy BalanceStrategy
+	// ConsecutiveFailures is a number of consecutive failures
+	// ejecting endpoint.
+	//
+	// Defaults to 5.
+	ConsecutiveFailures int
+	// EjectionTime is how long endpoint stays ejected.
+	//
+	// Defaults to 30s.
+	EjectionTime time.Duration
+	// HealthCheckInterval is an interval of active health checks,
+	// zero disables them.
+	HealthCheckInterval time.Duration
+	// HealthCheckPath is a path of health check endpoint.
+	//
+	// Defaults to "/healthz".
+	HealthCheckPath string
+	// HealthCheckClient is used for health checks.
+	//
+	// Defaults to http.DefaultClient.
+	HealthCheckClient *http.Client
+	// KeepHost sends Host of target to endpoints, set it if endpoints are
+	// addresses of target, see ResolveEndpoints. Otherwise, Host of endpoint
+	// is used.
+	KeepHost bool
+}
+
+func (c *BalancerConfig) setDefaults() {
+	if c.Strategy == "" {
+		c.Strategy = RoundRobin
+	}
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.EjectionTime <= 0 {
+		c.EjectionTime = 30 * time.Second
+	}
+	if c.HealthCheckPath == "" {
+		c.HealthCheckPath = "/healthz"
+	}
+	if c.HealthCheckClient == nil {
+		c.HealthCheckClient = http.DefaultClient
+	}
+}
+
+type endpoint struct {
+	url *url.URL
+
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+	unhealthy    bool
+}
+
+// Balancer spreads requests to target URL across endpoints.
+//
+// Requests to other URLs, e.g. set by oas.WithServerURL, are passed as is.
+type Balancer struct {
+	target *url.URL
+	cfg    BalancerConfig
+	now    func() time.Time
+
+	mux       sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+// NewBalancer creates new Balancer of requests to target across endpoints.
+func NewBalancer(target *url.URL, endpoints []*url.URL, cfg BalancerConfig) (*Balancer, error) {
+	cfg.setDefaults()
+	switch cfg.Strategy {
+	case RoundRobin, LeastOutstanding:
+	default:
+		return nil, errors.Errorf("unknown strategy %q", cfg.Strategy)
+	}
+	b := &Balancer{
+		target: target,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+	b.SetEndpoints(endpoints)
+	return b, nil
+}
+
+// SetEndpoints replaces endpoints, keeping state of existing ones.
+func (b *Balancer) SetEndpoints(urls []*url.URL) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, u := range urls {
+		idx := slices.IndexFunc(b.endpoints, func(e *endpoint) bool {
+			return e.url.String() == u.String()
+		})
+		if idx >= 0 {
+			endpoints = append(endpoints, b.endpoints[idx])
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{url: u})
+	}
+	b.endpoints = endpoints
+}
+
+// Endpoints returns current endpoints.
+func (b *Balancer) Endpoints() []*url.URL {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	urls := make([]*url.URL, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		urls = append(urls, e.url)
+	}
+	return urls
+}
+
+// usedEndpoints collects endpoints used by attempts of single request,
+// so hedged attempts go to other endpoints.
+type usedEndpoints struct {
+	mux  sync.Mutex
+	urls map[string]struct{}
+}
+
+func (u *usedEndpoints) has(e *endpoint) bool {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	_, ok := u.urls[e.url.String()]
+	return ok
+}
+
+func (u *usedEndpoints) add(e *endpoint) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	u.urls[e.url.String()] = struct{}{}
+}
+
+type usedEndpointsKey struct{}
+
+// withUsedEndpoints makes balancer prefer endpoints not used by
+// other requests with derived context.
+func withUsedEndpoints(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usedEndpointsKey{}, &usedEndpoints{
+		urls: map[string]struct{}{},
+	})
+}
+
+// pick selects endpoint and increments its outstanding requests.
+//
+// If every endpoint is ejected or unhealthy, all of them are considered.
+// Endpoints from used are skipped if there are others.
+func (b *Balancer) pick(used *usedEndpoints) (*endpoint, bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := b.now()
+	available := make([]*endpoint, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		if !e.unhealthy && !now.Before(e.ejectedUntil) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		available = b.endpoints
+	}
+	if len(available) == 0 {
+		return nil, false
+	}
+	if used != nil {
+		unused := slices.DeleteFunc(slices.Clone(available), used.has)
+		if len(unused) > 0 {
+			available = unused
+		}
+	}
+
+	start := b.next % len(available)
+	b.next++
+	picked := available[start]
+	if b.cfg.Strategy == LeastOutstanding {
+		for i := range available {
+			e := available[(start+i)%len(available)]
+			if e.outstanding < picked.outstanding {
+				picked = e
+			}
+		}
+	}
+	picked.outstanding++
+	if used != nil {
+		used.add(picked)
+	}
+	return picked, true
+}
+
+// done records result of request to endpoint.
+func (b *Balancer) done(ctx context.Context, e *endpoint, failed bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	e.outstanding--
+	if !failed {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= b.cfg.ConsecutiveFailures {
+		e.failures = 0
+		e.ejectedUntil = b.now().Add(b.cfg.EjectionTime)
+		trace.SpanFromContext(ctx).AddEvent("balancer.ejected", trace.WithAttributes(
+			attribute.String("balancer.endpoint", e.url.String()),
+		))
+	}
+}
+
+// matches reports whether u is relative to target URL.
+func (b *Balancer) matches(u *url.URL) bool {
+	return u.Scheme == b.target.Scheme &&
+		u.Host == b.target.Host &&
+		strings.HasPrefix(u.Path, b.target.Path)
+}
+
+// rewrite returns URL u of target relative to endpoint base.
+func (b *Balancer) rewrite(u, base *url.URL) *url.URL {
+	r := *u
+	r.Scheme = base.Scheme
+	r.Host = base.Host
+	r.Path = strings.TrimSuffix(base.Path, "/") + strings.TrimPrefix(u.Path, b.target.Path)
+	if u.RawPath != "" {
+		// Keep escaping of path, e.g. "%2F" in parameter.
+		r.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + strings.TrimPrefix(u.EscapedPath(), b.target.EscapedPath())
+	}
+	return &r
+}
+
+// Middleware sends requests to selected endpoint.
+//
+// Host header of target is kept only with KeepHost, so endpoint can be an
+// address of target, see EndpointTLSConfig. Endpoint is recorded as span
+// attribute.
+func (b *Balancer) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if !b.matches(r.URL) {
+			return next.RoundTrip(r)
+		}
+		ctx := r.Context()
+		used, _ := ctx.Value(usedEndpointsKey{}).(*usedEndpoints)
+		e, ok := b.pick(used)
+		if !ok {
+			return nil, errors.New("no endpoints")
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("balancer.endpoint", e.url.String()))
+
+		req := r.Clone(ctx)
+		req.URL = b.rewrite(r.URL, e.url)
+		switch {
+		case b.cfg.KeepHost && req.Host == "":
+			req.Host = r.URL.Host
+		case !b.cfg.KeepHost && req.Host == r.URL.Host:
+			// Set from target URL by http.NewRequest, endpoint is
+			// separate server.
+			req.Host = ""
+		}
+		resp, err := next.RoundTrip(req)
+		b.done(ctx, e, isFailure(resp, err))
+		return resp, err
+	})
+}
+
+// checkHealth checks endpoints once.
+func (b *Balancer) checkHealth(ctx context.Context) {
+	b.mux.Lock()
+	endpoints := slices.Clone(b.endpoints)
+	b.mux.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthy := b.probe(ctx, e.url)
+
+			b.mux.Lock()
+			defer b.mux.Unlock()
+			e.unhealthy = !healthy
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *Balancer) probe(ctx context.Context, base *url.URL) bool {
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.HealthCheckInterval)
+	defer cancel()
+
+	u := *base
+	u.Path = b.cfg.HealthCheckPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return false
+	}
+	if b.cfg.KeepHost {
+		req.Host = b.target.Host
+	}
+	resp, err := b.cfg.HealthCheckClient.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Run performs active health checks until ctx is done.
+//
+// Does nothing if health checks are disabled.
+func (b *Balancer) Run(ctx context.Context) error {
+	if b.cfg.HealthCheckInterval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(b.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		b.checkHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// EndpointTLSConfig returns copy of cfg verifying endpoints by hostname of
+// target, so HTTPS works with endpoints from ResolveEndpoints.
+//
+// Transport dials endpoint address, so without it SNI and certificate
+// are checked against IP address.
+func EndpointTLSConfig(target *url.URL, cfg *tls.Config) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	cfg.ServerName = target.Hostname()
+	return cfg
+}
+
+// ResolveEndpoints resolves host of u to endpoint per address.
+func ResolveEndpoints(ctx context.Context, resolver *net.Resolver, u *url.URL) ([]*url.URL, error) {
+	addrs, err := resolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return nil, errors.Wrapf(err, "lookup %q", u.Hostname())
+	}
+	slices.Sort(addrs)
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	endpoints := make([]*url.URL, 0, len(addrs))
+	for _, addr := range addrs {
+		e := *u
+		e.Host = net.JoinHostPort(addr, port)
+		endpoints = append(endpoints, &e)
+	}
+	return endpoints, nil
+}
+
+// WatchDNS periodically resolves host of target URL and updates endpoints
+// until ctx is done.
+//
+// Resolve errors keep previous endpoints and are passed to onError.
+func (b *Balancer) WatchDNS(ctx context.Context, resolver *net.Resolver, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		endpoints, err := ResolveEndpoints(ctx, resolver, b.target)
+		switch {
+		case err != nil:
+			onError(err)
+		case len(endpoints) == 0:
+			onError(errors.Errorf("no addresses for %q", b.target.Hostname()))
+		default:
+			b.SetEndpoints(endpoints)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
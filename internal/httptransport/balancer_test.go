@@ -0,0 +1,205 @@
+package httptransport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testReplica struct {
+	*httptest.Server
+	URL     *url.URL
+	calls   atomic.Int32
+	status  atomic.Int32
+	healthy atomic.Bool
+	host    atomic.Value
+}
+
+func newTestReplica(t *testing.T) *testReplica {
+	r := &testReplica{}
+	r.status.Store(http.StatusOK)
+	r.healthy.Store(true)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/healthz" {
+			if !r.healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		r.calls.Add(1)
+		r.host.Store(req.Host)
+		w.WriteHeader(int(r.status.Load()))
+	}))
+	t.Cleanup(r.Server.Close)
+	u, err := url.Parse(r.Server.URL)
+	require.NoError(t, err)
+	r.URL = u
+	return r
+}
+
+func TestBalancer(t *testing.T) {
+	target := &url.URL{Scheme: "http", Host: "api"}
+	newBalancer := func(t *testing.T, cfg BalancerConfig, replicas ...*testReplica) (*Balancer, *http.Client) {
+		var urls []*url.URL
+		for _, r := range replicas {
+			urls = append(urls, r.URL)
+		}
+		b, err := NewBalancer(target, urls, cfg)
+		require.NoError(t, err)
+		return b, &http.Client{Transport: b.Middleware(http.DefaultTransport)}
+	}
+	get := func(t *testing.T, client *http.Client, u string) int {
+		resp, err := client.Get(u)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		a, b := newTestReplica(t), newTestReplica(t)
+		_, client := newBalancer(t, BalancerConfig{}, a, b)
+		for i := 0; i < 10; i++ {
+			get(t, client, "http://api/pet/1")
+		}
+		require.Equal(t, int32(5), a.calls.Load())
+		require.Equal(t, int32(5), b.calls.Load())
+	})
+	t.Run("Ejection", func(t *testing.T) {
+		a, b := newTestReplica(t), newTestReplica(t)
+		a.status.Store(http.StatusServiceUnavailable)
+		bal, client := newBalancer(t, BalancerConfig{ConsecutiveFailures: 2, EjectionTime: time.Minute}, a, b)
+		now := time.Now()
+		bal.now = func() time.Time { return now }
+		for i := 0; i < 10; i++ {
+			get(t, client, "http://api/pet/1")
+		}
+		require.Equal(t, int32(2), a.calls.Load())
+		require.Equal(t, int32(8), b.calls.Load())
+
+		// Back after ejection time.
+		now = now.Add(time.Minute)
+		for i := 0; i < 2; i++ {
+			get(t, client, "http://api/pet/1")
+		}
+		require.Equal(t, int32(3), a.calls.Load())
+	})
+	t.Run("AllEjected", func(t *testing.T) {
+		a := newTestReplica(t)
+		a.status.Store(http.StatusServiceUnavailable)
+		_, client := newBalancer(t, BalancerConfig{ConsecutiveFailures: 1}, a)
+		for i := 0; i < 3; i++ {
+			require.Equal(t, http.StatusServiceUnavailable, get(t, client, "http://api/pet/1"))
+		}
+		require.Equal(t, int32(3), a.calls.Load())
+	})
+	t.Run("HealthCheck", func(t *testing.T) {
+		a, b := newTestReplica(t), newTestReplica(t)
+		a.healthy.Store(false)
+		bal, client := newBalancer(t, BalancerConfig{HealthCheckInterval: time.Second}, a, b)
+		bal.checkHealth(context.Background())
+		for i := 0; i < 4; i++ {
+			get(t, client, "http://api/pet/1")
+		}
+		require.Zero(t, a.calls.Load())
+		require.Equal(t, int32(4), b.calls.Load())
+	})
+	t.Run("LeastOutstanding", func(t *testing.T) {
+		a, b := newTestReplica(t), newTestReplica(t)
+		bal, _ := newBalancer(t, BalancerConfig{Strategy: LeastOutstanding}, a, b)
+		first, ok := bal.pick(nil)
+		require.True(t, ok)
+		for i := 0; i < 3; i++ {
+			e, ok := bal.pick(nil)
+			require.True(t, ok)
+			require.NotEqual(t, first.url, e.url)
+			bal.done(context.Background(), e, false)
+		}
+	})
+	t.Run("Host", func(t *testing.T) {
+		a, b := newTestReplica(t), newTestReplica(t)
+		// Explicit replicas get their own Host.
+		_, client := newBalancer(t, BalancerConfig{}, a, b)
+		for i := 0; i < 2; i++ {
+			get(t, client, "http://api/pet/1")
+		}
+		require.Equal(t, a.URL.Host, a.host.Load())
+		require.Equal(t, b.URL.Host, b.host.Load())
+
+		// Resolved endpoints are addresses of target.
+		_, client = newBalancer(t, BalancerConfig{KeepHost: true}, a)
+		get(t, client, "http://api/pet/1")
+		require.Equal(t, "api", a.host.Load())
+	})
+	t.Run("OtherURL", func(t *testing.T) {
+		a, other := newTestReplica(t), newTestReplica(t)
+		_, client := newBalancer(t, BalancerConfig{}, a)
+		get(t, client, other.Server.URL+"/pet/1")
+		require.Zero(t, a.calls.Load())
+		require.Equal(t, int32(1), other.calls.Load())
+	})
+	t.Run("UnknownStrategy", func(t *testing.T) {
+		_, err := NewBalancer(target, nil, BalancerConfig{Strategy: "random"})
+		require.Error(t, err)
+	})
+}
+
+func TestBalancerRewrite(t *testing.T) {
+	b, err := NewBalancer(&url.URL{Scheme: "http", Host: "api", Path: "/v3"}, nil, BalancerConfig{})
+	require.NoError(t, err)
+	u, err := url.Parse("http://api/v3/pet/1?name=tom")
+	require.NoError(t, err)
+	require.True(t, b.matches(u))
+	base, err := url.Parse("https://10.0.0.1:8443/api/")
+	require.NoError(t, err)
+	require.Equal(t, "https://10.0.0.1:8443/api/pet/1?name=tom", b.rewrite(u, base).String())
+
+	// Escaped path is kept.
+	u, err = url.Parse("http://api/v3/pet/a%2Fb")
+	require.NoError(t, err)
+	require.Equal(t, "https://10.0.0.1:8443/api/pet/a%2Fb", b.rewrite(u, base).String())
+}
+
+func TestBalancerTLS(t *testing.T) {
+	var host, serverName atomic.Value
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host.Store(r.Host)
+		serverName.Store(r.TLS.ServerName)
+	}))
+	t.Cleanup(srv.Close)
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	// Endpoint is an address of target, certificate is issued for target.
+	target := &url.URL{Scheme: "https", Host: "example.com:" + endpoint.Port()}
+	b, err := NewBalancer(target, []*url.URL{endpoint}, BalancerConfig{KeepHost: true})
+	require.NoError(t, err)
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig = EndpointTLSConfig(target, transport.TLSClientConfig)
+	client := &http.Client{Transport: b.Middleware(transport)}
+
+	resp, err := client.Get(target.String() + "/pet/1")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, target.Host, host.Load())
+	require.Equal(t, "example.com", serverName.Load())
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	u, err := url.Parse("http://localhost:8080/v3")
+	require.NoError(t, err)
+	endpoints, err := ResolveEndpoints(context.Background(), net.DefaultResolver, u)
+	require.NoError(t, err)
+	require.NotEmpty(t, endpoints)
+	for _, e := range endpoints {
+		require.Equal(t, "8080", e.Port())
+		require.Equal(t, "/v3", e.Path)
+	}
+}
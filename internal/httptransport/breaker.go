@@ -0,0 +1,290 @@
+package httptransport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+)
+
+// BreakerState is a state of circuit breaker.
+type BreakerState int
+
+// Circuit breaker states.
+const (
+	// BreakerClosed passes requests, counting failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects requests until cool-down passes.
+	BreakerOpen
+	// BreakerHalfOpen passes single probe request.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned for requests rejected by open circuit breaker.
+type CircuitOpenError struct {
+	OperationID string
+	// RetryAfter is remaining cool-down time.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %q is open, retry after %s", e.OperationID, e.RetryAfter)
+}
+
+// BreakerConfig configures circuit breaker.
+type BreakerConfig struct {
+	// FailureRatio is a ratio of failed requests opening the circuit.
+	//
+	// Defaults to 0.5.
+	FailureRatio float64
+	// MinRequests is a minimum number of requests in window before
+	// the circuit can open.
+	//
+	// Defaults to 10.
+	MinRequests int
+	// Window is an interval of counting failures.
+	//
+	// Defaults to 10s.
+	Window time.Duration
+	// CoolDown is how long circuit stays open before probe request.
+	//
+	// Defaults to 5s.
+	CoolDown time.Duration
+	// IsFailure reports whether request failed.
+	//
+	// Defaults to transport errors and 5xx responses. Canceled requests,
+	// e.g. lost hedged attempts, are not counted at all and canceled probe
+	// leaves the circuit half-open.
+	IsFailure func(resp *http.Response, err error) bool
+	// MeterProvider is used to export state transitions.
+	//
+	// Defaults to global MeterProvider.
+	MeterProvider metric.MeterProvider
+}
+
+func (c *BreakerConfig) setDefaults() {
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = 5 * time.Second
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = isFailure
+	}
+	if c.MeterProvider == nil {
+		c.MeterProvider = otel.GetMeterProvider()
+	}
+}
+
+// isFailure reports whether request failed due to server or network.
+func isFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= 500
+}
+
+type breaker struct {
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// Breakers is a set of circuit breakers keyed by operation ID.
+type Breakers struct {
+	cfg  BreakerConfig
+	find httpmiddleware.RouteFinder
+	now  func() time.Time
+
+	transitions metric.Int64Counter
+	rejected    metric.Int64Counter
+
+	mux      sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewBreakers creates new Breakers.
+func NewBreakers(find httpmiddleware.RouteFinder, cfg BreakerConfig) (*Breakers, error) {
+	cfg.setDefaults()
+	meter := cfg.MeterProvider.Meter("example/internal/httptransport")
+	transitions, err := meter.Int64Counter("http.client.circuit_breaker.transitions",
+		metric.WithDescription("Number of circuit breaker state transitions"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "transitions counter")
+	}
+	rejected, err := meter.Int64Counter("http.client.circuit_breaker.rejected",
+		metric.WithDescription("Number of requests rejected by open circuit breaker"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "rejected counter")
+	}
+	return &Breakers{
+		cfg:         cfg,
+		find:        find,
+		now:         time.Now,
+		transitions: transitions,
+		rejected:    rejected,
+		breakers:    map[string]*breaker{},
+	}, nil
+}
+
+// State returns state of breaker of given operation.
+func (b *Breakers) State(operationID string) BreakerState {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if br, ok := b.breakers[operationID]; ok {
+		return br.state
+	}
+	return BreakerClosed
+}
+
+// setState must be called with b.mux held.
+func (b *Breakers) setState(r *http.Request, op string, br *breaker, to BreakerState, now time.Time) {
+	from := br.state
+	br.state = to
+	br.requests, br.failures = 0, 0
+	br.windowStart = now
+	br.probing = false
+	if to == BreakerOpen {
+		br.openedAt = now
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("oas.operation", op),
+		attribute.String("circuit_breaker.from", from.String()),
+		attribute.String("circuit_breaker.to", to.String()),
+	}
+	b.transitions.Add(r.Context(), 1, metric.WithAttributes(attrs...))
+	trace.SpanFromContext(r.Context()).AddEvent("circuit_breaker.transition", trace.WithAttributes(attrs...))
+}
+
+// allow checks whether request can be sent.
+//
+// Returns whether request is a half-open probe.
+func (b *Breakers) allow(r *http.Request, op string) (probe bool, _ error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := b.now()
+	br, ok := b.breakers[op]
+	if !ok {
+		br = &breaker{windowStart: now}
+		b.breakers[op] = br
+	}
+	switch br.state {
+	case BreakerOpen:
+		if wait := br.openedAt.Add(b.cfg.CoolDown).Sub(now); wait > 0 {
+			return false, &CircuitOpenError{OperationID: op, RetryAfter: wait}
+		}
+		b.setState(r, op, br, BreakerHalfOpen, now)
+		br.probing = true
+		return true, nil
+	case BreakerHalfOpen:
+		if br.probing {
+			return false, &CircuitOpenError{OperationID: op}
+		}
+		br.probing = true
+		return true, nil
+	default:
+		if now.Sub(br.windowStart) >= b.cfg.Window {
+			br.windowStart = now
+			br.requests, br.failures = 0, 0
+		}
+		return false, nil
+	}
+}
+
+// release releases request without result, e.g. canceled one.
+func (b *Breakers) release(op string, probe bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if br := b.breakers[op]; probe && br.state == BreakerHalfOpen {
+		br.probing = false
+	}
+}
+
+// record records result of request.
+func (b *Breakers) record(r *http.Request, op string, probe, failed bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := b.now()
+	br := b.breakers[op]
+	if probe {
+		if failed {
+			b.setState(r, op, br, BreakerOpen, now)
+		} else {
+			b.setState(r, op, br, BreakerClosed, now)
+		}
+		return
+	}
+	if br.state != BreakerClosed {
+		return
+	}
+	br.requests++
+	if failed {
+		br.failures++
+	}
+	if br.requests >= b.cfg.MinRequests &&
+		float64(br.failures) >= b.cfg.FailureRatio*float64(br.requests) {
+		b.setState(r, op, br, BreakerOpen, now)
+	}
+}
+
+// Middleware rejects requests with CircuitOpenError while circuit of
+// operation is open.
+func (b *Breakers) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		op := "unknown"
+		if route, ok := b.find(r.Method, r.URL); ok {
+			op = route.OperationID()
+		}
+
+		probe, err := b.allow(r, op)
+		if err != nil {
+			b.rejected.Add(r.Context(), 1, metric.WithAttributes(attribute.String("oas.operation", op)))
+			trace.SpanFromContext(r.Context()).AddEvent("circuit_breaker.rejected")
+			return nil, err
+		}
+		resp, err := next.RoundTrip(r)
+		if errors.Is(err, context.Canceled) {
+			b.release(op, probe)
+			return resp, err
+		}
+		b.record(r, op, probe, b.cfg.IsFailure(resp, err))
+		return resp, err
+	})
+}
@@ -0,0 +1,113 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestBreakers(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	b, err := NewBreakers(testFinder, BreakerConfig{
+		FailureRatio:  0.5,
+		MinRequests:   4,
+		Window:        time.Minute,
+		CoolDown:      time.Second,
+		MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	var (
+		status   = http.StatusOK
+		canceled bool
+		calls    int
+	)
+	client := &http.Client{
+		Transport: b.Middleware(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			if canceled {
+				return nil, context.Canceled
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody, Request: r}, nil
+		})),
+	}
+	get := func() error {
+		resp, err := client.Get("http://example.com/pet")
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	// Not enough requests to open.
+	require.NoError(t, get())
+	status = http.StatusServiceUnavailable
+	require.NoError(t, get())
+	require.NoError(t, get())
+	require.Equal(t, BreakerClosed, b.State("getPetById"))
+
+	// 3 of 4 failed.
+	require.NoError(t, get())
+	require.Equal(t, BreakerOpen, b.State("getPetById"))
+
+	// Fast fail while open.
+	err = get()
+	var openErr *CircuitOpenError
+	require.True(t, errors.As(err, &openErr))
+	require.Equal(t, "getPetById", openErr.OperationID)
+	require.Equal(t, time.Second, openErr.RetryAfter)
+	require.Equal(t, 4, calls)
+
+	// Other operations are not affected.
+	resp, err := client.Post("http://example.com/pet", "application/json", http.NoBody)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// Failed probe opens circuit again.
+	now = now.Add(time.Second)
+	require.NoError(t, get())
+	require.Equal(t, BreakerOpen, b.State("getPetById"))
+	require.Error(t, get())
+
+	// Canceled probe, e.g. lost hedge, leaves circuit half-open.
+	now = now.Add(time.Second)
+	canceled = true
+	require.ErrorIs(t, get(), context.Canceled)
+	require.Equal(t, BreakerHalfOpen, b.State("getPetById"))
+	canceled = false
+
+	// Successful probe closes circuit.
+	status = http.StatusOK
+	require.NoError(t, get())
+	require.Equal(t, BreakerClosed, b.State("getPetById"))
+	require.NoError(t, get())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counters := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				counters[m.Name] += dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{
+		// closed -> open -> half-open -> open -> half-open -> closed
+		"http.client.circuit_breaker.transitions": 5,
+		"http.client.circuit_breaker.rejected":    2,
+	}, counters)
+}
+
+func TestBreakerState(t *testing.T) {
+	require.Equal(t, "half-open", BreakerHalfOpen.String())
+	require.Equal(t, "BreakerState(10)", BreakerState(10).String())
+}
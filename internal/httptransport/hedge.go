@@ -0,0 +1,310 @@
+package httptransport
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+)
+
+// hedgeSamples is a number of recent latencies used to compute hedge delay.
+const hedgeSamples = 512
+
+// HedgeConfig configures Hedge.
+type HedgeConfig struct {
+	// Hedgeable reports whether request is safe to hedge.
+	//
+	// Required.
+	Hedgeable func(r *http.Request) bool
+	// Percentile of recent latencies of operation after which
+	// hedged attempt is sent.
+	//
+	// Defaults to 0.95.
+	Percentile float64
+	// InitialDelay is a hedge delay used until enough latencies are observed.
+	//
+	// Defaults to 100ms.
+	InitialDelay time.Duration
+	// MinDelay is a lower bound of hedge delay.
+	//
+	// Defaults to 1ms.
+	MinDelay time.Duration
+	// MaxHedges is a maximum number of hedged attempts per request.
+	//
+	// Defaults to 1.
+	MaxHedges int
+	// TracerProvider is used to create per-attempt spans.
+	//
+	// Defaults to global TracerProvider.
+	TracerProvider trace.TracerProvider
+	// MeterProvider is used to count hedges.
+	//
+	// Defaults to global MeterProvider.
+	MeterProvider metric.MeterProvider
+}
+
+func (c *HedgeConfig) setDefaults() {
+	if c.Percentile <= 0 || c.Percentile >= 1 {
+		c.Percentile = 0.95
+	}
+	if c.InitialDelay <= 0 {
+		c.InitialDelay = 100 * time.Millisecond
+	}
+	if c.MinDelay <= 0 {
+		c.MinDelay = time.Millisecond
+	}
+	if c.MaxHedges <= 0 {
+		c.MaxHedges = 1
+	}
+	if c.TracerProvider == nil {
+		c.TracerProvider = otel.GetTracerProvider()
+	}
+	if c.MeterProvider == nil {
+		c.MeterProvider = otel.GetMeterProvider()
+	}
+}
+
+// latencyWindow keeps recent latencies and their percentile.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	delay   time.Duration
+}
+
+// Hedger sends hedged attempts of slow requests.
+type Hedger struct {
+	cfg    HedgeConfig
+	find   httpmiddleware.RouteFinder
+	tracer trace.Tracer
+
+	hedges metric.Int64Counter
+	wins   metric.Int64Counter
+
+	mux       sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+// NewHedger creates new Hedger.
+func NewHedger(find httpmiddleware.RouteFinder, cfg HedgeConfig) (*Hedger, error) {
+	cfg.setDefaults()
+	if cfg.Hedgeable == nil {
+		return nil, errors.New("hedgeable is required")
+	}
+	meter := cfg.MeterProvider.Meter("example/internal/httptransport")
+	hedges, err := meter.Int64Counter("http.client.hedge.requests",
+		metric.WithDescription("Number of hedged attempts sent"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "hedges counter")
+	}
+	wins, err := meter.Int64Counter("http.client.hedge.wins",
+		metric.WithDescription("Number of requests won by hedged attempt"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "wins counter")
+	}
+	return &Hedger{
+		cfg:       cfg,
+		find:      find,
+		tracer:    cfg.TracerProvider.Tracer("example/internal/httptransport"),
+		hedges:    hedges,
+		wins:      wins,
+		latencies: map[string]*latencyWindow{},
+	}, nil
+}
+
+// Delay returns current hedge delay of operation.
+func (h *Hedger) Delay(operationID string) time.Duration {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	w, ok := h.latencies[operationID]
+	if !ok || w.delay == 0 {
+		return h.cfg.InitialDelay
+	}
+	return max(w.delay, h.cfg.MinDelay)
+}
+
+// observe records latency of successful request.
+func (h *Hedger) observe(op string, latency time.Duration) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	w, ok := h.latencies[op]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, 0, hedgeSamples)}
+		h.latencies[op] = w
+	}
+	if len(w.samples) < hedgeSamples {
+		w.samples = append(w.samples, latency)
+	} else {
+		w.samples[w.next] = latency
+		w.next = (w.next + 1) % hedgeSamples
+	}
+	// Recompute periodically, sorting on every request is wasteful.
+	if n := len(w.samples); n >= 20 && (n < hedgeSamples || w.next%16 == 0) {
+		sorted := slices.Clone(w.samples)
+		slices.Sort(sorted)
+		idx := int(math.Ceil(h.cfg.Percentile*float64(len(sorted)))) - 1
+		w.delay = sorted[max(idx, 0)]
+	}
+}
+
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+	cancel  context.CancelFunc
+}
+
+// cancelOnClose cancels attempt context when response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// Middleware sends hedged attempt if response is not received after
+// hedge delay, taking first successful response and canceling the rest.
+//
+// Every attempt gets its own child span.
+func (h *Hedger) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		rewindable := r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+		if !rewindable || !h.cfg.Hedgeable(r) {
+			return next.RoundTrip(r)
+		}
+		op := "unknown"
+		if route, ok := h.find(r.Method, r.URL); ok {
+			op = route.OperationID()
+		}
+		opAttr := metric.WithAttributes(attribute.String("oas.operation", op))
+
+		var (
+			ctx     = withUsedEndpoints(r.Context())
+			results = make(chan hedgeResult, h.cfg.MaxHedges+1)
+			start   = time.Now()
+		)
+		var cancels []context.CancelFunc
+		send := func(n int) {
+			attemptCtx, cancel := context.WithCancel(ctx)
+			cancels = append(cancels, cancel)
+			attemptCtx, span := h.tracer.Start(attemptCtx, "http.hedge.attempt",
+				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithAttributes(attribute.Int("hedge.attempt", n)),
+			)
+			req := r.Clone(attemptCtx)
+			if n > 0 && r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					span.End()
+					results <- hedgeResult{attempt: n, err: errors.Wrap(err, "get body"), cancel: cancel}
+					return
+				}
+				req.Body = body
+			}
+			go func() {
+				defer span.End()
+				resp, err := next.RoundTrip(req)
+				switch {
+				case err != nil:
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "request failed")
+				default:
+					span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+				}
+				results <- hedgeResult{attempt: n, resp: resp, err: err, cancel: cancel}
+			}()
+		}
+
+		send(0)
+		var (
+			sent    = 1
+			pending = 1
+			timer   = time.NewTimer(h.Delay(op))
+			last    hedgeResult
+		)
+		defer timer.Stop()
+
+		// discard cancels attempts other than winner and closes their responses.
+		discard := func(winner, pending int) {
+			for i, cancel := range cancels {
+				if i != winner {
+					cancel()
+				}
+			}
+			go func() {
+				for i := 0; i < pending; i++ {
+					res := <-results
+					if res.resp != nil {
+						_ = res.resp.Body.Close()
+					}
+				}
+			}()
+		}
+		hedge := func() {
+			h.hedges.Add(ctx, 1, opAttr)
+			trace.SpanFromContext(ctx).AddEvent("hedge", trace.WithAttributes(
+				attribute.Int("hedge.attempt", sent),
+			))
+			send(sent)
+			sent++
+			pending++
+		}
+
+		for {
+			select {
+			case <-timer.C:
+				if sent <= h.cfg.MaxHedges {
+					hedge()
+					timer.Reset(h.Delay(op))
+				}
+			case res := <-results:
+				pending--
+				if res.err == nil && res.resp.StatusCode < 500 {
+					h.observe(op, time.Since(start))
+					if res.attempt > 0 {
+						h.wins.Add(ctx, 1, opAttr)
+					}
+					discard(res.attempt, pending)
+					res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: res.cancel}
+					return res.resp, nil
+				}
+				if last.resp != nil {
+					_ = last.resp.Body.Close()
+				}
+				if last.cancel != nil {
+					last.cancel()
+				}
+				last = res
+				if pending > 0 {
+					continue
+				}
+				// All attempts failed, failures are handled by retries.
+				if last.resp != nil {
+					last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: last.cancel}
+				} else {
+					last.cancel()
+				}
+				return last.resp, last.err
+			}
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"example/internal/httpmiddleware"
+)
+
+func TestHedger(t *testing.T) {
+	provider := httpmiddleware.NewProvider()
+	reader := sdkmetric.NewManualReader()
+	h, err := NewHedger(testFinder, HedgeConfig{
+		Hedgeable:      MatchOperations(testFinder, "getPetById"),
+		InitialDelay:   200 * time.Millisecond,
+		TracerProvider: provider,
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	require.NoError(t, err)
+
+	var (
+		calls    atomic.Int32
+		canceled = make(chan struct{})
+		slow     atomic.Bool
+	)
+	client := &http.Client{
+		Transport: h.Middleware(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if calls.Add(1) == 1 && slow.Load() {
+				<-r.Context().Done()
+				close(canceled)
+				return nil, r.Context().Err()
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		})),
+	}
+	get := func() {
+		resp, err := client.Get("http://example.com/pet")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// Fast response, no hedge.
+	get()
+	require.Equal(t, int32(1), calls.Load())
+
+	// Slow first attempt, hedge wins and first attempt is canceled.
+	calls.Store(0)
+	slow.Store(true)
+	provider.Flush()
+	provider.Reset()
+	get()
+	require.Equal(t, int32(2), calls.Load())
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt is not canceled")
+	}
+
+	// Lost attempt span ends after response is returned.
+	require.Eventually(t, func() bool {
+		provider.Flush()
+		return len(provider.Exporter.GetSpans()) == 2
+	}, time.Second, 10*time.Millisecond)
+	for _, s := range provider.Exporter.GetSpans() {
+		require.Equal(t, "http.hedge.attempt", s.Name)
+	}
+
+	// Unsafe operations are not hedged.
+	calls.Store(0)
+	slow.Store(false)
+	resp, err := client.Post("http://example.com/pet", "application/json", http.NoBody)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, int32(1), calls.Load())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counters := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				counters[m.Name] += dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{
+		"http.client.hedge.requests": 1,
+		"http.client.hedge.wins":     1,
+	}, counters)
+}
+
+func TestHedgerDelay(t *testing.T) {
+	h, err := NewHedger(testFinder, HedgeConfig{
+		Hedgeable:    func(*http.Request) bool { return true },
+		Percentile:   0.9,
+		InitialDelay: time.Second,
+	})
+	require.NoError(t, err)
+	require.Equal(t, time.Second, h.Delay("getPetById"))
+	for i := 1; i <= 100; i++ {
+		h.observe("getPetById", time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 90*time.Millisecond, h.Delay("getPetById"))
+	require.Equal(t, time.Second, h.Delay("addPet"))
+}
+
+func TestHedgerBalancer(t *testing.T) {
+	a, b := newTestReplica(t), newTestReplica(t)
+	bal, err := NewBalancer(&url.URL{Scheme: "http", Host: "api"}, []*url.URL{a.URL, b.URL}, BalancerConfig{})
+	require.NoError(t, err)
+	h, err := NewHedger(testFinder, HedgeConfig{
+		Hedgeable:    func(*http.Request) bool { return true },
+		InitialDelay: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	var (
+		mux   sync.Mutex
+		hosts []string
+	)
+	client := &http.Client{
+		Transport: Wrap(
+			RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				mux.Lock()
+				hosts = append(hosts, r.URL.Host)
+				first := len(hosts) == 1
+				mux.Unlock()
+				if first {
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+			}),
+			h.Middleware,
+			bal.Middleware,
+		),
+	}
+	resp, err := client.Get("http://api/pet")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, hosts, 2)
+	require.NotEqual(t, hosts[0], hosts[1], "hedge must go to other endpoint")
+}
@@ -0,0 +1,25 @@
+// Package httptransport contains HTTP client transports.
+package httptransport
+
+import "net/http"
+
+// Middleware is a net/http client middleware.
+type Middleware = func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is a function implementing http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Wrap transport using given middlewares.
+//
+// First middleware is the outermost one.
+func Wrap(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
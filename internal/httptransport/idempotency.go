@@ -0,0 +1,49 @@
+package httptransport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"example/internal/httpmiddleware"
+)
+
+// MatchOperations returns function matching requests of given operations.
+func MatchOperations(find httpmiddleware.RouteFinder, operationIDs ...string) func(r *http.Request) bool {
+	ids := make(map[string]struct{}, len(operationIDs))
+	for _, id := range operationIDs {
+		ids[id] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		route, ok := find(r.Method, r.URL)
+		if !ok {
+			return false
+		}
+		_, ok = ids[route.OperationID()]
+		return ok
+	}
+}
+
+// newIdempotencyKey returns random idempotency key.
+func newIdempotencyKey() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// IdempotencyKey sets random idempotency key on matching requests not having one,
+// so they can be safely retried.
+//
+// It should be placed before Retry, so all attempts share the same key.
+func IdempotencyKey(match func(r *http.Request) bool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if HasIdempotencyKey(r) || !match(r) {
+				return next.RoundTrip(r)
+			}
+			r = r.Clone(r.Context())
+			r.Header.Set(httpmiddleware.IdempotencyKeyHeader, newIdempotencyKey())
+			return next.RoundTrip(r)
+		})
+	}
+}
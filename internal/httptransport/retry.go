@@ -0,0 +1,259 @@
+package httptransport
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+)
+
+// RetryBudget limits retries to a fraction of requests to avoid retry storms.
+//
+// Every request deposits ratio tokens and every retry withdraws one token.
+type RetryBudget struct {
+	mux    sync.Mutex
+	ratio  float64
+	max    float64
+	tokens float64
+}
+
+// NewRetryBudget creates budget allowing ratio retries per request,
+// but no more than burst retries in a row.
+func NewRetryBudget(ratio, burst float64) *RetryBudget {
+	return &RetryBudget{
+		ratio:  ratio,
+		max:    burst,
+		tokens: burst,
+	}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RetryPolicy configures Retry.
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of attempts, including the first one.
+	//
+	// Defaults to 3.
+	MaxAttempts int
+	// BaseDelay is a backoff delay before the first retry.
+	//
+	// Defaults to 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps backoff delay. Responses asking to retry later
+	// than MaxDelay via Retry-After are not retried.
+	//
+	// Defaults to 5s.
+	MaxDelay time.Duration
+	// Budget limits retries, nil means no limit.
+	Budget *RetryBudget
+	// Retryable reports whether request is safe to retry.
+	//
+	// Defaults to requests having an idempotency key.
+	Retryable func(r *http.Request) bool
+	// TracerProvider is used to create per-attempt spans.
+	//
+	// Defaults to global TracerProvider.
+	TracerProvider trace.TracerProvider
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 5 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = HasIdempotencyKey
+	}
+	if p.TracerProvider == nil {
+		p.TracerProvider = otel.GetTracerProvider()
+	}
+}
+
+// HasIdempotencyKey reports whether request has an idempotency key.
+func HasIdempotencyKey(r *http.Request) bool {
+	return r.Header.Get(httpmiddleware.IdempotencyKeyHeader) != ""
+}
+
+// IdempotentOperations returns Retryable function allowing retries of given
+// operations and of any request having an idempotency key.
+func IdempotentOperations(find httpmiddleware.RouteFinder, operationIDs ...string) func(r *http.Request) bool {
+	match := MatchOperations(find, operationIDs...)
+	return func(r *http.Request) bool {
+		return HasIdempotencyKey(r) || match(r)
+	}
+}
+
+// backoff returns capped exponential backoff delay with full jitter.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.MaxDelay
+	if attempt < 32 {
+		limit = min(limit, p.BaseDelay<<attempt)
+	}
+	if limit <= 0 {
+		limit = p.MaxDelay
+	}
+	return rand.N(limit) + 1
+}
+
+// parseRetryAfter parses Retry-After header value given in seconds or as HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// retryDelay returns delay before next attempt and whether attempt should be retried.
+func (p *RetryPolicy) retryDelay(resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	if err != nil {
+		var openErr *CircuitOpenError
+		if errors.Is(err, context.Canceled) ||
+			errors.Is(err, context.DeadlineExceeded) ||
+			errors.As(err, &openErr) {
+			return 0, "", false
+		}
+		return p.backoff(attempt), "connection error", true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+	default:
+		return 0, "", false
+	}
+	reason := strconv.Itoa(resp.StatusCode)
+	delay := p.backoff(attempt)
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		if retryAfter > p.MaxDelay {
+			return 0, "", false
+		}
+		delay = max(delay, retryAfter)
+	}
+	return delay, reason, true
+}
+
+// Retry retries failed requests according to given policy.
+//
+// Requests are retried on connection errors and on 429, 502, 503 and 504
+// responses, honouring Retry-After. Every attempt gets its own child span.
+func Retry(p RetryPolicy) Middleware {
+	p.setDefaults()
+	tracer := p.TracerProvider.Tracer("example/internal/httptransport")
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		attempt := func(r *http.Request, n int) (*http.Response, error) {
+			ctx, span := tracer.Start(r.Context(), "http.attempt",
+				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithAttributes(attribute.Int("http.request.resend_count", n)),
+			)
+			defer span.End()
+
+			resp, err := next.RoundTrip(r.WithContext(ctx))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "request failed")
+				return nil, err
+			}
+			span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+			if resp.StatusCode >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+			}
+			return resp, nil
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if p.Budget != nil {
+				p.Budget.deposit()
+			}
+			ctx := req.Context()
+			rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			canRetry := rewindable && p.Retryable(req)
+
+			for n := 0; ; n++ {
+				r := req
+				if n > 0 {
+					r = req.Clone(ctx)
+					if req.GetBody != nil {
+						body, err := req.GetBody()
+						if err != nil {
+							return nil, errors.Wrap(err, "get body")
+						}
+						r.Body = body
+					}
+				}
+
+				resp, err := attempt(r, n)
+				if !canRetry || n+1 >= p.MaxAttempts {
+					return resp, err
+				}
+				delay, reason, ok := p.retryDelay(resp, err, n)
+				if !ok {
+					return resp, err
+				}
+				if p.Budget != nil && !p.Budget.withdraw() {
+					trace.SpanFromContext(ctx).AddEvent("retry.budget_exhausted")
+					return resp, err
+				}
+				if resp != nil {
+					_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+					_ = resp.Body.Close()
+				}
+				trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+					attribute.String("retry.reason", reason),
+					attribute.Int("retry.attempt", n+1),
+					attribute.Int64("retry.delay_ms", delay.Milliseconds()),
+				))
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package httptransport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"example/internal/httpmiddleware"
+)
+
+type testRoute struct {
+	id string
+}
+
+func (r testRoute) Name() string        { return r.id }
+func (r testRoute) OperationID() string { return r.id }
+func (r testRoute) PathPattern() string { return "/" + r.id }
+
+func testFinder(method string, u *url.URL) (httpmiddleware.Route, bool) {
+	switch {
+	case method == http.MethodGet && u.Path == "/pet":
+		return testRoute{id: "getPetById"}, true
+	case method == http.MethodPost && u.Path == "/pet":
+		return testRoute{id: "addPet"}, true
+	default:
+		return nil, false
+	}
+}
+
+// flakyServer responds with given statuses, then with 200.
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s, &calls
+}
+
+func TestRetry(t *testing.T) {
+	provider := httpmiddleware.NewProvider()
+	newClient := func(budget *RetryBudget) *http.Client {
+		return &http.Client{
+			Transport: Wrap(http.DefaultTransport, Retry(RetryPolicy{
+				MaxAttempts:    3,
+				BaseDelay:      time.Millisecond,
+				MaxDelay:       10 * time.Millisecond,
+				Budget:         budget,
+				Retryable:      IdempotentOperations(testFinder, "getPetById"),
+				TracerProvider: provider,
+			})),
+		}
+	}
+
+	t.Run("Idempotent", func(t *testing.T) {
+		provider.Reset()
+		s, calls := flakyServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		resp, err := newClient(nil).Get(s.URL + "/pet")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, int32(3), calls.Load())
+
+		provider.Flush()
+		spans := provider.Exporter.GetSpans()
+		require.Len(t, spans, 3)
+		for _, s := range spans {
+			require.Equal(t, "http.attempt", s.Name)
+		}
+	})
+	t.Run("GiveUp", func(t *testing.T) {
+		s, calls := flakyServer(t, 502, 502, 502, 502)
+		resp, err := newClient(nil).Get(s.URL + "/pet")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		require.Equal(t, int32(3), calls.Load())
+	})
+	t.Run("NotRetryableStatus", func(t *testing.T) {
+		s, calls := flakyServer(t, http.StatusInternalServerError)
+		resp, err := newClient(nil).Get(s.URL + "/pet")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		require.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("NonIdempotent", func(t *testing.T) {
+		s, calls := flakyServer(t, http.StatusServiceUnavailable)
+		resp, err := newClient(nil).Post(s.URL+"/pet", "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("IdempotencyKey", func(t *testing.T) {
+		s, calls := flakyServer(t, http.StatusServiceUnavailable)
+		req, err := http.NewRequest(http.MethodPost, s.URL+"/pet", strings.NewReader(`{"name":"Tom"}`))
+		require.NoError(t, err)
+		req.Header.Set(httpmiddleware.IdempotencyKeyHeader, "key")
+
+		resp, err := newClient(nil).Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, int32(2), calls.Load())
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"name":"Tom"}`, string(body), "body must be replayed")
+	})
+	t.Run("GeneratedKey", func(t *testing.T) {
+		var keys []string
+		client := &http.Client{
+			Transport: Wrap(
+				RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					keys = append(keys, r.Header.Get(httpmiddleware.IdempotencyKeyHeader))
+					status := http.StatusServiceUnavailable
+					if len(keys) > 1 {
+						status = http.StatusOK
+					}
+					return &http.Response{StatusCode: status, Body: http.NoBody, Request: r}, nil
+				}),
+				IdempotencyKey(MatchOperations(testFinder, "addPet")),
+				Retry(RetryPolicy{BaseDelay: time.Millisecond}),
+			),
+		}
+		resp, err := client.Post("http://example.com/pet", "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, keys, 2)
+		require.NotEmpty(t, keys[0])
+		require.Equal(t, keys[0], keys[1], "attempts must share key")
+	})
+	t.Run("Budget", func(t *testing.T) {
+		s, calls := flakyServer(t, 503, 503, 503)
+		resp, err := newClient(NewRetryBudget(0, 1)).Get(s.URL + "/pet")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.Equal(t, int32(2), calls.Load())
+	})
+	t.Run("ConnectionError", func(t *testing.T) {
+		var calls atomic.Int32
+		client := &http.Client{
+			Transport: Wrap(
+				RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					calls.Add(1)
+					return nil, io.ErrUnexpectedEOF
+				}),
+				Retry(RetryPolicy{
+					BaseDelay: time.Millisecond,
+					Retryable: func(*http.Request) bool { return true },
+				}),
+			),
+		}
+		_, err := client.Get("http://example.com/pet")
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Equal(t, int32(3), calls.Load())
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		s, calls := flakyServer(t, 503, 503)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/pet", http.NoBody)
+		require.NoError(t, err)
+		_, err = newClient(nil).Do(req)
+		require.ErrorIs(t, err, context.Canceled)
+		require.LessOrEqual(t, calls.Load(), int32(1))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		Value string
+		Delay time.Duration
+		OK    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	} {
+		delay, ok := parseRetryAfter(tt.Value, now)
+		require.Equal(t, tt.OK, ok, tt.Value)
+		require.Equal(t, tt.Delay, delay, tt.Value)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt := 0; attempt < 100; attempt++ {
+		d := p.backoff(attempt)
+		require.Positive(t, d)
+		require.LessOrEqual(t, d, 50*time.Millisecond)
+	}
+}
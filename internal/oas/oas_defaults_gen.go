@@ -0,0 +1,19 @@
+// Code generated by ogen, DO NOT EDIT.
+
+package oas
+
+// setDefaults set default value of fields.
+func (s *Webhook) setDefaults() {
+	{
+		val := WebhookContentMode("structured")
+		s.ContentMode = val
+	}
+}
+
+// setDefaults set default value of fields.
+func (s *WebhookRequest) setDefaults() {
+	{
+		val := WebhookContentMode("structured")
+		s.ContentMode.SetTo(val)
+	}
+}
@@ -1,6 +1,34 @@
 // Code generated by ogen, DO NOT EDIT.
 package oas
 
+type AddPetRes interface {
+	addPetRes()
+}
+
+type DeletePetRes interface {
+	deletePetRes()
+}
+
+type DeleteWebhookRes interface {
+	deleteWebhookRes()
+}
+
+type EnableWebhookRes interface {
+	enableWebhookRes()
+}
+
 type GetPetByIdRes interface {
 	getPetByIdRes()
 }
+
+type GetWebhookRes interface {
+	getWebhookRes()
+}
+
+type ListWebhookDeliveriesRes interface {
+	listWebhookDeliveriesRes()
+}
+
+type UpdatePetRes interface {
+	updatePetRes()
+}
@@ -6,8 +6,14 @@ package oas
 type OperationName = string
 
 const (
-	AddPetOperation     OperationName = "AddPet"
-	DeletePetOperation  OperationName = "DeletePet"
-	GetPetByIdOperation OperationName = "GetPetById"
-	UpdatePetOperation  OperationName = "UpdatePet"
+	AddPetOperation                OperationName = "AddPet"
+	CreateWebhookOperation         OperationName = "CreateWebhook"
+	DeletePetOperation             OperationName = "DeletePet"
+	DeleteWebhookOperation         OperationName = "DeleteWebhook"
+	EnableWebhookOperation         OperationName = "EnableWebhook"
+	GetPetByIdOperation            OperationName = "GetPetById"
+	GetWebhookOperation            OperationName = "GetWebhook"
+	ListWebhookDeliveriesOperation OperationName = "ListWebhookDeliveries"
+	ListWebhooksOperation          OperationName = "ListWebhooks"
+	UpdatePetOperation             OperationName = "UpdatePet"
 )
@@ -3,17 +3,158 @@
 package oas
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/go-faster/errors"
 )
 
+// AddPetBadRequest is response for AddPet operation.
+type AddPetBadRequest struct{}
+
+func (*AddPetBadRequest) addPetRes() {}
+
+// AddPetConflict is response for AddPet operation.
+type AddPetConflict struct{}
+
+func (*AddPetConflict) addPetRes() {}
+
+// DeletePetNotFound is response for DeletePet operation.
+type DeletePetNotFound struct{}
+
+func (*DeletePetNotFound) deletePetRes() {}
+
 // DeletePetOK is response for DeletePet operation.
 type DeletePetOK struct{}
 
+func (*DeletePetOK) deletePetRes() {}
+
+// DeleteWebhookNotFound is response for DeleteWebhook operation.
+type DeleteWebhookNotFound struct{}
+
+func (*DeleteWebhookNotFound) deleteWebhookRes() {}
+
+// DeleteWebhookOK is response for DeleteWebhook operation.
+type DeleteWebhookOK struct{}
+
+func (*DeleteWebhookOK) deleteWebhookRes() {}
+
+// EnableWebhookNotFound is response for EnableWebhook operation.
+type EnableWebhookNotFound struct{}
+
+func (*EnableWebhookNotFound) enableWebhookRes() {}
+
 // GetPetByIdNotFound is response for GetPetById operation.
 type GetPetByIdNotFound struct{}
 
 func (*GetPetByIdNotFound) getPetByIdRes() {}
 
+// GetWebhookNotFound is response for GetWebhook operation.
+type GetWebhookNotFound struct{}
+
+func (*GetWebhookNotFound) getWebhookRes() {}
+
+// ListWebhookDeliveriesNotFound is response for ListWebhookDeliveries operation.
+type ListWebhookDeliveriesNotFound struct{}
+
+func (*ListWebhookDeliveriesNotFound) listWebhookDeliveriesRes() {}
+
+type ListWebhookDeliveriesOKApplicationJSON []WebhookDelivery
+
+func (*ListWebhookDeliveriesOKApplicationJSON) listWebhookDeliveriesRes() {}
+
+// NewOptDateTime returns new OptDateTime with value set to v.
+func NewOptDateTime(v time.Time) OptDateTime {
+	return OptDateTime{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptDateTime is optional time.Time.
+type OptDateTime struct {
+	Value time.Time
+	Set   bool
+}
+
+// IsSet returns true if OptDateTime was set.
+func (o OptDateTime) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptDateTime) Reset() {
+	var v time.Time
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptDateTime) SetTo(v time.Time) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptDateTime) Get() (v time.Time, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptDateTime) Or(d time.Time) time.Time {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptInt returns new OptInt with value set to v.
+func NewOptInt(v int) OptInt {
+	return OptInt{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptInt is optional int.
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+// IsSet returns true if OptInt was set.
+func (o OptInt) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptInt) Reset() {
+	var v int
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptInt) SetTo(v int) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptInt) Get() (v int, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptInt) Or(d int) int {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
 // NewOptInt64 returns new OptInt64 with value set to v.
 func NewOptInt64(v int64) OptInt64 {
 	return OptInt64{
@@ -152,6 +293,52 @@ func (o OptString) Or(d string) string {
 	return d
 }
 
+// NewOptWebhookContentMode returns new OptWebhookContentMode with value set to v.
+func NewOptWebhookContentMode(v WebhookContentMode) OptWebhookContentMode {
+	return OptWebhookContentMode{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptWebhookContentMode is optional WebhookContentMode.
+type OptWebhookContentMode struct {
+	Value WebhookContentMode
+	Set   bool
+}
+
+// IsSet returns true if OptWebhookContentMode was set.
+func (o OptWebhookContentMode) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptWebhookContentMode) Reset() {
+	var v WebhookContentMode
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptWebhookContentMode) SetTo(v WebhookContentMode) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptWebhookContentMode) Get() (v WebhookContentMode, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptWebhookContentMode) Or(d WebhookContentMode) WebhookContentMode {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
 // Ref: #/components/schemas/Pet
 type Pet struct {
 	ID        OptInt64     `json:"id"`
@@ -200,6 +387,7 @@ func (s *Pet) SetStatus(val OptPetStatus) {
 	s.Status = val
 }
 
+func (*Pet) addPetRes()     {}
 func (*Pet) getPetByIdRes() {}
 
 // Pet status in the store.
@@ -252,5 +440,463 @@ func (s *PetStatus) UnmarshalText(data []byte) error {
 	}
 }
 
+// UpdatePetNotFound is response for UpdatePet operation.
+type UpdatePetNotFound struct{}
+
+func (*UpdatePetNotFound) updatePetRes() {}
+
 // UpdatePetOK is response for UpdatePet operation.
 type UpdatePetOK struct{}
+
+func (*UpdatePetOK) updatePetRes() {}
+
+// Ref: #/components/schemas/Webhook
+type Webhook struct {
+	ID          int64              `json:"id"`
+	URL         url.URL            `json:"url"`
+	ContentMode WebhookContentMode `json:"content_mode"`
+	Events      []WebhookEventType `json:"events"`
+	Statuses    []PetStatus        `json:"statuses"`
+	// Disabled webhook is not notified.
+	Enabled bool `json:"enabled"`
+	// Number of consecutive failed attempts, webhook is disabled on limit.
+	Failures       int       `json:"failures"`
+	DisabledReason OptString `json:"disabled_reason"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GetID returns the value of ID.
+func (s *Webhook) GetID() int64 {
+	return s.ID
+}
+
+// GetURL returns the value of URL.
+func (s *Webhook) GetURL() url.URL {
+	return s.URL
+}
+
+// GetContentMode returns the value of ContentMode.
+func (s *Webhook) GetContentMode() WebhookContentMode {
+	return s.ContentMode
+}
+
+// GetEvents returns the value of Events.
+func (s *Webhook) GetEvents() []WebhookEventType {
+	return s.Events
+}
+
+// GetStatuses returns the value of Statuses.
+func (s *Webhook) GetStatuses() []PetStatus {
+	return s.Statuses
+}
+
+// GetEnabled returns the value of Enabled.
+func (s *Webhook) GetEnabled() bool {
+	return s.Enabled
+}
+
+// GetFailures returns the value of Failures.
+func (s *Webhook) GetFailures() int {
+	return s.Failures
+}
+
+// GetDisabledReason returns the value of DisabledReason.
+func (s *Webhook) GetDisabledReason() OptString {
+	return s.DisabledReason
+}
+
+// GetCreatedAt returns the value of CreatedAt.
+func (s *Webhook) GetCreatedAt() time.Time {
+	return s.CreatedAt
+}
+
+// SetID sets the value of ID.
+func (s *Webhook) SetID(val int64) {
+	s.ID = val
+}
+
+// SetURL sets the value of URL.
+func (s *Webhook) SetURL(val url.URL) {
+	s.URL = val
+}
+
+// SetContentMode sets the value of ContentMode.
+func (s *Webhook) SetContentMode(val WebhookContentMode) {
+	s.ContentMode = val
+}
+
+// SetEvents sets the value of Events.
+func (s *Webhook) SetEvents(val []WebhookEventType) {
+	s.Events = val
+}
+
+// SetStatuses sets the value of Statuses.
+func (s *Webhook) SetStatuses(val []PetStatus) {
+	s.Statuses = val
+}
+
+// SetEnabled sets the value of Enabled.
+func (s *Webhook) SetEnabled(val bool) {
+	s.Enabled = val
+}
+
+// SetFailures sets the value of Failures.
+func (s *Webhook) SetFailures(val int) {
+	s.Failures = val
+}
+
+// SetDisabledReason sets the value of DisabledReason.
+func (s *Webhook) SetDisabledReason(val OptString) {
+	s.DisabledReason = val
+}
+
+// SetCreatedAt sets the value of CreatedAt.
+func (s *Webhook) SetCreatedAt(val time.Time) {
+	s.CreatedAt = val
+}
+
+func (*Webhook) enableWebhookRes() {}
+func (*Webhook) getWebhookRes()    {}
+
+// Ref: #/components/schemas/WebhookAttempt
+type WebhookAttempt struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// Response status, not set if request failed.
+	StatusCode OptInt    `json:"status_code"`
+	Error      OptString `json:"error"`
+}
+
+// GetStartedAt returns the value of StartedAt.
+func (s *WebhookAttempt) GetStartedAt() time.Time {
+	return s.StartedAt
+}
+
+// GetDurationMs returns the value of DurationMs.
+func (s *WebhookAttempt) GetDurationMs() int64 {
+	return s.DurationMs
+}
+
+// GetStatusCode returns the value of StatusCode.
+func (s *WebhookAttempt) GetStatusCode() OptInt {
+	return s.StatusCode
+}
+
+// GetError returns the value of Error.
+func (s *WebhookAttempt) GetError() OptString {
+	return s.Error
+}
+
+// SetStartedAt sets the value of StartedAt.
+func (s *WebhookAttempt) SetStartedAt(val time.Time) {
+	s.StartedAt = val
+}
+
+// SetDurationMs sets the value of DurationMs.
+func (s *WebhookAttempt) SetDurationMs(val int64) {
+	s.DurationMs = val
+}
+
+// SetStatusCode sets the value of StatusCode.
+func (s *WebhookAttempt) SetStatusCode(val OptInt) {
+	s.StatusCode = val
+}
+
+// SetError sets the value of Error.
+func (s *WebhookAttempt) SetError(val OptString) {
+	s.Error = val
+}
+
+// CloudEvents HTTP content mode of requests: structured puts event with its attributes to JSON body,
+// binary puts attributes to ce-* headers and pet to body.
+// Ref: #/components/schemas/WebhookContentMode
+type WebhookContentMode string
+
+const (
+	WebhookContentModeStructured WebhookContentMode = "structured"
+	WebhookContentModeBinary     WebhookContentMode = "binary"
+)
+
+// AllValues returns all WebhookContentMode values.
+func (WebhookContentMode) AllValues() []WebhookContentMode {
+	return []WebhookContentMode{
+		WebhookContentModeStructured,
+		WebhookContentModeBinary,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s WebhookContentMode) MarshalText() ([]byte, error) {
+	switch s {
+	case WebhookContentModeStructured:
+		return []byte(s), nil
+	case WebhookContentModeBinary:
+		return []byte(s), nil
+	default:
+		return nil, errors.Errorf("invalid value: %q", s)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *WebhookContentMode) UnmarshalText(data []byte) error {
+	switch WebhookContentMode(data) {
+	case WebhookContentModeStructured:
+		*s = WebhookContentModeStructured
+		return nil
+	case WebhookContentModeBinary:
+		*s = WebhookContentModeBinary
+		return nil
+	default:
+		return errors.Errorf("invalid value: %q", data)
+	}
+}
+
+// Ref: #/components/schemas/WebhookDelivery
+type WebhookDelivery struct {
+	ID        int64                 `json:"id"`
+	EventID   int64                 `json:"event_id"`
+	EventType WebhookEventType      `json:"event_type"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  []WebhookAttempt      `json:"attempts"`
+	// Time of next attempt of pending delivery.
+	NextAttemptAt OptDateTime `json:"next_attempt_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// GetID returns the value of ID.
+func (s *WebhookDelivery) GetID() int64 {
+	return s.ID
+}
+
+// GetEventID returns the value of EventID.
+func (s *WebhookDelivery) GetEventID() int64 {
+	return s.EventID
+}
+
+// GetEventType returns the value of EventType.
+func (s *WebhookDelivery) GetEventType() WebhookEventType {
+	return s.EventType
+}
+
+// GetStatus returns the value of Status.
+func (s *WebhookDelivery) GetStatus() WebhookDeliveryStatus {
+	return s.Status
+}
+
+// GetAttempts returns the value of Attempts.
+func (s *WebhookDelivery) GetAttempts() []WebhookAttempt {
+	return s.Attempts
+}
+
+// GetNextAttemptAt returns the value of NextAttemptAt.
+func (s *WebhookDelivery) GetNextAttemptAt() OptDateTime {
+	return s.NextAttemptAt
+}
+
+// GetCreatedAt returns the value of CreatedAt.
+func (s *WebhookDelivery) GetCreatedAt() time.Time {
+	return s.CreatedAt
+}
+
+// SetID sets the value of ID.
+func (s *WebhookDelivery) SetID(val int64) {
+	s.ID = val
+}
+
+// SetEventID sets the value of EventID.
+func (s *WebhookDelivery) SetEventID(val int64) {
+	s.EventID = val
+}
+
+// SetEventType sets the value of EventType.
+func (s *WebhookDelivery) SetEventType(val WebhookEventType) {
+	s.EventType = val
+}
+
+// SetStatus sets the value of Status.
+func (s *WebhookDelivery) SetStatus(val WebhookDeliveryStatus) {
+	s.Status = val
+}
+
+// SetAttempts sets the value of Attempts.
+func (s *WebhookDelivery) SetAttempts(val []WebhookAttempt) {
+	s.Attempts = val
+}
+
+// SetNextAttemptAt sets the value of NextAttemptAt.
+func (s *WebhookDelivery) SetNextAttemptAt(val OptDateTime) {
+	s.NextAttemptAt = val
+}
+
+// SetCreatedAt sets the value of CreatedAt.
+func (s *WebhookDelivery) SetCreatedAt(val time.Time) {
+	s.CreatedAt = val
+}
+
+// Ref: #/components/schemas/WebhookDeliveryStatus
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+// AllValues returns all WebhookDeliveryStatus values.
+func (WebhookDeliveryStatus) AllValues() []WebhookDeliveryStatus {
+	return []WebhookDeliveryStatus{
+		WebhookDeliveryStatusPending,
+		WebhookDeliveryStatusSucceeded,
+		WebhookDeliveryStatusFailed,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s WebhookDeliveryStatus) MarshalText() ([]byte, error) {
+	switch s {
+	case WebhookDeliveryStatusPending:
+		return []byte(s), nil
+	case WebhookDeliveryStatusSucceeded:
+		return []byte(s), nil
+	case WebhookDeliveryStatusFailed:
+		return []byte(s), nil
+	default:
+		return nil, errors.Errorf("invalid value: %q", s)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *WebhookDeliveryStatus) UnmarshalText(data []byte) error {
+	switch WebhookDeliveryStatus(data) {
+	case WebhookDeliveryStatusPending:
+		*s = WebhookDeliveryStatusPending
+		return nil
+	case WebhookDeliveryStatusSucceeded:
+		*s = WebhookDeliveryStatusSucceeded
+		return nil
+	case WebhookDeliveryStatusFailed:
+		*s = WebhookDeliveryStatusFailed
+		return nil
+	default:
+		return errors.Errorf("invalid value: %q", data)
+	}
+}
+
+// Type of pet change event.
+// Ref: #/components/schemas/WebhookEventType
+type WebhookEventType string
+
+const (
+	WebhookEventTypeCreated       WebhookEventType = "created"
+	WebhookEventTypeUpdated       WebhookEventType = "updated"
+	WebhookEventTypeDeleted       WebhookEventType = "deleted"
+	WebhookEventTypeStatusChanged WebhookEventType = "status_changed"
+)
+
+// AllValues returns all WebhookEventType values.
+func (WebhookEventType) AllValues() []WebhookEventType {
+	return []WebhookEventType{
+		WebhookEventTypeCreated,
+		WebhookEventTypeUpdated,
+		WebhookEventTypeDeleted,
+		WebhookEventTypeStatusChanged,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s WebhookEventType) MarshalText() ([]byte, error) {
+	switch s {
+	case WebhookEventTypeCreated:
+		return []byte(s), nil
+	case WebhookEventTypeUpdated:
+		return []byte(s), nil
+	case WebhookEventTypeDeleted:
+		return []byte(s), nil
+	case WebhookEventTypeStatusChanged:
+		return []byte(s), nil
+	default:
+		return nil, errors.Errorf("invalid value: %q", s)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *WebhookEventType) UnmarshalText(data []byte) error {
+	switch WebhookEventType(data) {
+	case WebhookEventTypeCreated:
+		*s = WebhookEventTypeCreated
+		return nil
+	case WebhookEventTypeUpdated:
+		*s = WebhookEventTypeUpdated
+		return nil
+	case WebhookEventTypeDeleted:
+		*s = WebhookEventTypeDeleted
+		return nil
+	case WebhookEventTypeStatusChanged:
+		*s = WebhookEventTypeStatusChanged
+		return nil
+	default:
+		return errors.Errorf("invalid value: %q", data)
+	}
+}
+
+// Ref: #/components/schemas/WebhookRequest
+type WebhookRequest struct {
+	// Absolute http or https URL receiving POST requests.
+	URL    url.URL            `json:"url"`
+	Events []WebhookEventType `json:"events"`
+	// Only events of pets with one of these statuses are delivered.
+	Statuses []PetStatus `json:"statuses"`
+	// Key of request signature, never returned.
+	Secret      string                `json:"secret"`
+	ContentMode OptWebhookContentMode `json:"content_mode"`
+}
+
+// GetURL returns the value of URL.
+func (s *WebhookRequest) GetURL() url.URL {
+	return s.URL
+}
+
+// GetEvents returns the value of Events.
+func (s *WebhookRequest) GetEvents() []WebhookEventType {
+	return s.Events
+}
+
+// GetStatuses returns the value of Statuses.
+func (s *WebhookRequest) GetStatuses() []PetStatus {
+	return s.Statuses
+}
+
+// GetSecret returns the value of Secret.
+func (s *WebhookRequest) GetSecret() string {
+	return s.Secret
+}
+
+// GetContentMode returns the value of ContentMode.
+func (s *WebhookRequest) GetContentMode() OptWebhookContentMode {
+	return s.ContentMode
+}
+
+// SetURL sets the value of URL.
+func (s *WebhookRequest) SetURL(val url.URL) {
+	s.URL = val
+}
+
+// SetEvents sets the value of Events.
+func (s *WebhookRequest) SetEvents(val []WebhookEventType) {
+	s.Events = val
+}
+
+// SetStatuses sets the value of Statuses.
+func (s *WebhookRequest) SetStatuses(val []PetStatus) {
+	s.Statuses = val
+}
+
+// SetSecret sets the value of Secret.
+func (s *WebhookRequest) SetSecret(val string) {
+	s.Secret = val
+}
+
+// SetContentMode sets the value of ContentMode.
+func (s *WebhookRequest) SetContentMode(val OptWebhookContentMode) {
+	s.ContentMode = val
+}
@@ -13,25 +13,62 @@ type Handler interface {
 	// Add a new pet to the store.
 	//
 	// POST /pet
-	AddPet(ctx context.Context, req *Pet) (*Pet, error)
+	AddPet(ctx context.Context, req *Pet, params AddPetParams) (AddPetRes, error)
+	// CreateWebhook implements createWebhook operation.
+	//
+	// Registers URL notified about pet change events in CloudEvents format. Requests are signed with
+	// HMAC-SHA256 of secret, see Webhook-Signature header.
+	//
+	// POST /webhooks
+	CreateWebhook(ctx context.Context, req *WebhookRequest, params CreateWebhookParams) (*Webhook, error)
 	// DeletePet implements deletePet operation.
 	//
 	// Deletes a pet.
 	//
 	// DELETE /pet/{petId}
-	DeletePet(ctx context.Context, params DeletePetParams) error
+	DeletePet(ctx context.Context, params DeletePetParams) (DeletePetRes, error)
+	// DeleteWebhook implements deleteWebhook operation.
+	//
+	// Deletes a webhook with its deliveries.
+	//
+	// DELETE /webhooks/{webhookId}
+	DeleteWebhook(ctx context.Context, params DeleteWebhookParams) (DeleteWebhookRes, error)
+	// EnableWebhook implements enableWebhook operation.
+	//
+	// Enables webhook disabled after repeated failures.
+	//
+	// POST /webhooks/{webhookId}/enable
+	EnableWebhook(ctx context.Context, params EnableWebhookParams) (EnableWebhookRes, error)
 	// GetPetById implements getPetById operation.
 	//
 	// Returns a single pet.
 	//
 	// GET /pet/{petId}
 	GetPetById(ctx context.Context, params GetPetByIdParams) (GetPetByIdRes, error)
+	// GetWebhook implements getWebhook operation.
+	//
+	// Find webhook by ID.
+	//
+	// GET /webhooks/{webhookId}
+	GetWebhook(ctx context.Context, params GetWebhookParams) (GetWebhookRes, error)
+	// ListWebhookDeliveries implements listWebhookDeliveries operation.
+	//
+	// List deliveries of webhook with their attempts.
+	//
+	// GET /webhooks/{webhookId}/deliveries
+	ListWebhookDeliveries(ctx context.Context, params ListWebhookDeliveriesParams) (ListWebhookDeliveriesRes, error)
+	// ListWebhooks implements listWebhooks operation.
+	//
+	// List webhooks.
+	//
+	// GET /webhooks
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
 	// UpdatePet implements updatePet operation.
 	//
 	// Updates a pet in the store.
 	//
 	// POST /pet/{petId}
-	UpdatePet(ctx context.Context, params UpdatePetParams) error
+	UpdatePet(ctx context.Context, params UpdatePetParams) (UpdatePetRes, error)
 }
 
 // Server implements http server based on OpenAPI v3 specification and
@@ -18,7 +18,17 @@ var _ Handler = UnimplementedHandler{}
 // Add a new pet to the store.
 //
 // POST /pet
-func (UnimplementedHandler) AddPet(ctx context.Context, req *Pet) (r *Pet, _ error) {
+func (UnimplementedHandler) AddPet(ctx context.Context, req *Pet, params AddPetParams) (r AddPetRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// CreateWebhook implements createWebhook operation.
+//
+// Registers URL notified about pet change events in CloudEvents format. Requests are signed with
+// HMAC-SHA256 of secret, see Webhook-Signature header.
+//
+// POST /webhooks
+func (UnimplementedHandler) CreateWebhook(ctx context.Context, req *WebhookRequest, params CreateWebhookParams) (r *Webhook, _ error) {
 	return r, ht.ErrNotImplemented
 }
 
@@ -27,8 +37,26 @@ func (UnimplementedHandler) AddPet(ctx context.Context, req *Pet) (r *Pet, _ err
 // Deletes a pet.
 //
 // DELETE /pet/{petId}
-func (UnimplementedHandler) DeletePet(ctx context.Context, params DeletePetParams) error {
-	return ht.ErrNotImplemented
+func (UnimplementedHandler) DeletePet(ctx context.Context, params DeletePetParams) (r DeletePetRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// DeleteWebhook implements deleteWebhook operation.
+//
+// Deletes a webhook with its deliveries.
+//
+// DELETE /webhooks/{webhookId}
+func (UnimplementedHandler) DeleteWebhook(ctx context.Context, params DeleteWebhookParams) (r DeleteWebhookRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// EnableWebhook implements enableWebhook operation.
+//
+// Enables webhook disabled after repeated failures.
+//
+// POST /webhooks/{webhookId}/enable
+func (UnimplementedHandler) EnableWebhook(ctx context.Context, params EnableWebhookParams) (r EnableWebhookRes, _ error) {
+	return r, ht.ErrNotImplemented
 }
 
 // GetPetById implements getPetById operation.
@@ -40,11 +68,38 @@ func (UnimplementedHandler) GetPetById(ctx context.Context, params GetPetByIdPar
 	return r, ht.ErrNotImplemented
 }
 
+// GetWebhook implements getWebhook operation.
+//
+// Find webhook by ID.
+//
+// GET /webhooks/{webhookId}
+func (UnimplementedHandler) GetWebhook(ctx context.Context, params GetWebhookParams) (r GetWebhookRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// ListWebhookDeliveries implements listWebhookDeliveries operation.
+//
+// List deliveries of webhook with their attempts.
+//
+// GET /webhooks/{webhookId}/deliveries
+func (UnimplementedHandler) ListWebhookDeliveries(ctx context.Context, params ListWebhookDeliveriesParams) (r ListWebhookDeliveriesRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// ListWebhooks implements listWebhooks operation.
+//
+// List webhooks.
+//
+// GET /webhooks
+func (UnimplementedHandler) ListWebhooks(ctx context.Context) (r []Webhook, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // UpdatePet implements updatePet operation.
 //
 // Updates a pet in the store.
 //
 // POST /pet/{petId}
-func (UnimplementedHandler) UpdatePet(ctx context.Context, params UpdatePetParams) error {
-	return ht.ErrNotImplemented
+func (UnimplementedHandler) UpdatePet(ctx context.Context, params UpdatePetParams) (r UpdatePetRes, _ error) {
+	return r, ht.ErrNotImplemented
 }
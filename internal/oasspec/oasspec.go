@@ -0,0 +1,181 @@
+// Package oasspec describes operations of OpenAPI spec and validates
+// JSON values against its schemas.
+package oasspec
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen"
+)
+
+// Spec is parsed OpenAPI spec.
+type Spec struct {
+	schemas    map[string]*ogen.Schema
+	parameters map[string]*ogen.Parameter
+	operations []*Operation
+}
+
+// Operation is an operation of spec.
+type Operation struct {
+	ID     string
+	Method string
+	// Path is a path template, e.g. "/pet/{petId}".
+	Path       string
+	Parameters []*Parameter
+	// RequestBody is nil if operation has no JSON request body.
+	RequestBody *Body
+	// Responses by status code, "4XX"-like range or "default".
+	Responses map[string]*Response
+}
+
+// Parameter is an operation parameter.
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   *ogen.Schema
+}
+
+// Body is a JSON request body.
+type Body struct {
+	Required bool
+	Schema   *ogen.Schema
+}
+
+// Response is a declared response.
+type Response struct {
+	// ContentType is empty if response has no content.
+	ContentType string
+	// Schema is nil if response has no content.
+	Schema *ogen.Schema
+}
+
+// Parse parses spec.
+func Parse(data []byte) (*Spec, error) {
+	raw, err := ogen.Parse(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse spec")
+	}
+	s := &Spec{
+		schemas:    map[string]*ogen.Schema{},
+		parameters: map[string]*ogen.Parameter{},
+	}
+	if c := raw.Components; c != nil {
+		for name, schema := range c.Schemas {
+			s.schemas["#/components/schemas/"+name] = schema
+		}
+		for name, p := range c.Parameters {
+			s.parameters["#/components/parameters/"+name] = p
+		}
+	}
+	for path, item := range raw.Paths {
+		for method, op := range map[string]*ogen.Operation{
+			http.MethodGet:     item.Get,
+			http.MethodPut:     item.Put,
+			http.MethodPost:    item.Post,
+			http.MethodDelete:  item.Delete,
+			http.MethodOptions: item.Options,
+			http.MethodHead:    item.Head,
+			http.MethodPatch:   item.Patch,
+		} {
+			if op == nil {
+				continue
+			}
+			o, err := s.operation(method, path, item.Parameters, op)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s %s", method, path)
+			}
+			s.operations = append(s.operations, o)
+		}
+	}
+	slices.SortFunc(s.operations, func(a, b *Operation) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return s, nil
+}
+
+func (s *Spec) operation(method, path string, common []*ogen.Parameter, op *ogen.Operation) (*Operation, error) {
+	o := &Operation{
+		ID:        op.OperationID,
+		Method:    method,
+		Path:      path,
+		Responses: map[string]*Response{},
+	}
+	for _, p := range append(slices.Clone(common), op.Parameters...) {
+		if p.Ref != "" {
+			resolved, ok := s.parameters[p.Ref]
+			if !ok {
+				return nil, errors.Errorf("unknown parameter %q", p.Ref)
+			}
+			p = resolved
+		}
+		o.Parameters = append(o.Parameters, &Parameter{
+			Name:     p.Name,
+			In:       p.In,
+			Required: p.Required,
+			Schema:   p.Schema,
+		})
+	}
+	if body := op.RequestBody; body != nil {
+		media, ok := body.Content["application/json"]
+		if !ok {
+			return nil, errors.New("only application/json request body is supported")
+		}
+		o.RequestBody = &Body{
+			Required: body.Required,
+			Schema:   media.Schema,
+		}
+	}
+	for code, resp := range op.Responses {
+		r := &Response{}
+		for contentType, media := range resp.Content {
+			r.ContentType = contentType
+			r.Schema = media.Schema
+		}
+		o.Responses[code] = r
+	}
+	return o, nil
+}
+
+// Operations returns operations sorted by path and method.
+func (s *Spec) Operations() []*Operation {
+	return s.operations
+}
+
+// Operation returns operation by ID.
+func (s *Spec) Operation(id string) (*Operation, bool) {
+	idx := slices.IndexFunc(s.operations, func(o *Operation) bool { return o.ID == id })
+	if idx < 0 {
+		return nil, false
+	}
+	return s.operations[idx], true
+}
+
+// Resolve returns schema referenced by given one, if any.
+func (s *Spec) Resolve(schema *ogen.Schema) *ogen.Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.schemas[schema.Ref]
+	}
+	return schema
+}
+
+// Response returns declared response of status code.
+func (o *Operation) Response(status int) (*Response, bool) {
+	for _, key := range []string{
+		strconv.Itoa(status),
+		strconv.Itoa(status/100) + "XX",
+		"default",
+	} {
+		if r, ok := o.Responses[key]; ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
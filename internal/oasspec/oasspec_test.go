@@ -0,0 +1,83 @@
+package oasspec
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example"
+)
+
+func TestSpec(t *testing.T) {
+	s, err := Parse(example.OpenAPISpec)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, op := range s.Operations() {
+		ids = append(ids, op.ID)
+	}
+	require.Equal(t, []string{
+		"addPet",
+		"deletePet",
+		"getPetById",
+		"updatePet",
+		"listWebhooks",
+		"createWebhook",
+		"deleteWebhook",
+		"getWebhook",
+		"listWebhookDeliveries",
+		"enableWebhook",
+	}, ids)
+
+	op, ok := s.Operation("updatePet")
+	require.True(t, ok)
+	require.Equal(t, http.MethodPost, op.Method)
+	require.Equal(t, "/pet/{petId}", op.Path)
+	require.Len(t, op.Parameters, 4)
+	require.Equal(t, "Idempotency-Key", op.Parameters[0].Name)
+	require.Equal(t, "header", op.Parameters[0].In)
+
+	op, ok = s.Operation("getPetById")
+	require.True(t, ok)
+	resp, ok := op.Response(http.StatusOK)
+	require.True(t, ok)
+	require.Equal(t, "application/json", resp.ContentType)
+	require.Equal(t, "object", s.Resolve(resp.Schema).Type)
+	_, ok = op.Response(http.StatusBadRequest)
+	require.False(t, ok)
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Parse(example.OpenAPISpec)
+	require.NoError(t, err)
+	op, ok := s.Operation("getPetById")
+	require.True(t, ok)
+	resp, _ := op.Response(http.StatusOK)
+
+	for _, tt := range []struct {
+		Input string
+		Error string
+	}{
+		{`{"name":"Tom"}`, ""},
+		{`{"id":10,"name":"Tom","photoUrls":["a"],"status":"sold"}`, ""},
+		{`{}`, `$: required field "name" is missing`},
+		{`[]`, `$: expected object`},
+		{`{"name":null}`, `$.name: null is not allowed`},
+		{`{"name":"Tom","id":1.5}`, `$.id: expected integer, got 1.5`},
+		{`{"name":"Tom","status":"lost"}`, `$.status: value lost is not one of enum values`},
+		{`{"name":"Tom","photoUrls":[1]}`, `$.photoUrls[0]: expected string`},
+	} {
+		v, err := DecodeJSON([]byte(tt.Input))
+		require.NoError(t, err)
+		err = s.Validate(resp.Schema, v)
+		if tt.Error == "" {
+			require.NoError(t, err, tt.Input)
+			continue
+		}
+		require.EqualError(t, err, tt.Error, tt.Input)
+	}
+
+	_, err = DecodeJSON([]byte(`{} {}`))
+	require.Error(t, err)
+}
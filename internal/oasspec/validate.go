@@ -0,0 +1,202 @@
+package oasspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen"
+)
+
+// ValidationError is a violation of schema.
+type ValidationError struct {
+	// Path is a JSON path of value, e.g. "$.photoUrls[0]".
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// DecodeJSON decodes JSON value, keeping numbers as json.Number.
+func DecodeJSON(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after value")
+	}
+	return v, nil
+}
+
+// Validate validates value decoded by DecodeJSON against schema.
+//
+// Supported keywords are type, int32 format, enum, properties, required,
+// items, min/max items, min/max length, pattern, minimum, maximum, nullable,
+// allOf, anyOf and oneOf. Returned error joins every *ValidationError.
+func (s *Spec) Validate(schema *ogen.Schema, v any) error {
+	var errs []error
+	s.validate("$", schema, v, &errs)
+	return errors.Join(errs...)
+}
+
+func (s *Spec) validate(path string, schema *ogen.Schema, v any, errs *[]error) {
+	schema = s.Resolve(schema)
+	if schema == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &ValidationError{Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+	if v == nil {
+		if !schema.Nullable {
+			fail("null is not allowed")
+		}
+		return
+	}
+
+	for _, sub := range schema.AllOf {
+		s.validate(path, sub, v, errs)
+	}
+	if len(schema.AnyOf) > 0 && s.matches(schema.AnyOf, v) == 0 {
+		fail("does not match any schema")
+	}
+	if len(schema.OneOf) > 0 {
+		if n := s.matches(schema.OneOf, v); n != 1 {
+			fail("matches %d schemas, expected exactly one", n)
+		}
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, v) {
+		fail("value %v is not one of enum values", v)
+	}
+
+	typ := schema.Type
+	if typ == "" && len(schema.Properties) > 0 {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("expected object")
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				fail("required field %q is missing", name)
+			}
+		}
+		for _, p := range schema.Properties {
+			if field, ok := obj[p.Name]; ok {
+				s.validate(path+"."+p.Name, p.Schema, field, errs)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("expected array")
+			return
+		}
+		if m := schema.MinItems; m != nil && uint64(len(arr)) < *m {
+			fail("expected at least %d items", *m)
+		}
+		if m := schema.MaxItems; m != nil && uint64(len(arr)) > *m {
+			fail("expected at most %d items", *m)
+		}
+		if schema.Items != nil && schema.Items.Item != nil {
+			for i, elem := range arr {
+				s.validate(fmt.Sprintf("%s[%d]", path, i), schema.Items.Item, elem, errs)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected string")
+			return
+		}
+		n := uint64(utf8.RuneCountInString(str))
+		if m := schema.MinLength; m != nil && n < *m {
+			fail("expected at least %d characters", *m)
+		}
+		if m := schema.MaxLength; m != nil && n > *m {
+			fail("expected at most %d characters", *m)
+		}
+		if schema.Pattern != "" {
+			re, err := regexp.Compile(schema.Pattern)
+			if err == nil && !re.MatchString(str) {
+				fail("does not match pattern %q", schema.Pattern)
+			}
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			fail("expected %s", typ)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("invalid number %s", num)
+			return
+		}
+		if typ == "integer" {
+			i, err := num.Int64()
+			switch {
+			case err != nil:
+				fail("expected integer, got %s", num)
+				return
+			case schema.Format == "int32" && (i < math.MinInt32 || i > math.MaxInt32):
+				fail("%d overflows int32", i)
+			}
+		}
+		if m, ok := parseNum(schema.Minimum); ok && (f < m || schema.ExclusiveMinimum && f == m) {
+			fail("%s is less than minimum %v", num, m)
+		}
+		if m, ok := parseNum(schema.Maximum); ok && (f > m || schema.ExclusiveMaximum && f == m) {
+			fail("%s is greater than maximum %v", num, m)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected boolean")
+		}
+	}
+}
+
+// matches returns number of schemas matching value.
+func (s *Spec) matches(schemas []*ogen.Schema, v any) (n int) {
+	for _, sub := range schemas {
+		var errs []error
+		s.validate("$", sub, v, &errs)
+		if len(errs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func inEnum(enum []json.RawMessage, v any) bool {
+	return slices.ContainsFunc(enum, func(raw json.RawMessage) bool {
+		e, err := DecodeJSON(raw)
+		return err == nil && reflect.DeepEqual(e, v)
+	})
+}
+
+func parseNum[N ~[]byte](n N) (float64, bool) {
+	if len(n) == 0 {
+		return 0, false
+	}
+	var f float64
+	if err := json.Unmarshal(n, &f); err != nil {
+		return 0, false
+	}
+	return f, true
+}
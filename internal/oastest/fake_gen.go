@@ -0,0 +1,355 @@
+// Code generated by oastest/gen, DO NOT EDIT.
+
+package oastest
+
+import (
+	"context"
+
+	"example/internal/oas"
+)
+
+var (
+	_ oas.Invoker = (*Fake)(nil)
+	_ oas.Handler = (*Fake)(nil)
+)
+
+// AddPetCall is a recorded call of addPet operation.
+type AddPetCall struct {
+	Request *oas.Pet
+	Params  oas.AddPetParams
+}
+
+// OnAddPet sets handler of addPet operation.
+func (f *Fake) OnAddPet(h func(ctx context.Context, req *oas.Pet, params oas.AddPetParams) (oas.AddPetRes, error)) *Fake {
+	f.setHandler("addPet", h)
+	return f
+}
+
+// ReturnAddPet sets canned response of addPet operation.
+func (f *Fake) ReturnAddPet(r0 oas.AddPetRes, err error) *Fake {
+	return f.OnAddPet(func(ctx context.Context, req *oas.Pet, params oas.AddPetParams) (oas.AddPetRes, error) {
+		return r0, err
+	})
+}
+
+// AddPetCalls returns recorded calls of addPet operation.
+func (f *Fake) AddPetCalls() []AddPetCall {
+	return recorded[AddPetCall](f, "addPet")
+}
+
+// AddPet implements addPet operation.
+func (f *Fake) AddPet(ctx context.Context, req *oas.Pet, params oas.AddPetParams) (r0 oas.AddPetRes, err error) {
+	h, err := f.call("addPet", AddPetCall{
+		Request: req,
+		Params:  params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, req *oas.Pet, params oas.AddPetParams) (oas.AddPetRes, error))(ctx, req, params)
+}
+
+// CreateWebhookCall is a recorded call of createWebhook operation.
+type CreateWebhookCall struct {
+	Request *oas.WebhookRequest
+	Params  oas.CreateWebhookParams
+}
+
+// OnCreateWebhook sets handler of createWebhook operation.
+func (f *Fake) OnCreateWebhook(h func(ctx context.Context, req *oas.WebhookRequest, params oas.CreateWebhookParams) (*oas.Webhook, error)) *Fake {
+	f.setHandler("createWebhook", h)
+	return f
+}
+
+// ReturnCreateWebhook sets canned response of createWebhook operation.
+func (f *Fake) ReturnCreateWebhook(r0 *oas.Webhook, err error) *Fake {
+	return f.OnCreateWebhook(func(ctx context.Context, req *oas.WebhookRequest, params oas.CreateWebhookParams) (*oas.Webhook, error) {
+		return r0, err
+	})
+}
+
+// CreateWebhookCalls returns recorded calls of createWebhook operation.
+func (f *Fake) CreateWebhookCalls() []CreateWebhookCall {
+	return recorded[CreateWebhookCall](f, "createWebhook")
+}
+
+// CreateWebhook implements createWebhook operation.
+func (f *Fake) CreateWebhook(ctx context.Context, req *oas.WebhookRequest, params oas.CreateWebhookParams) (r0 *oas.Webhook, err error) {
+	h, err := f.call("createWebhook", CreateWebhookCall{
+		Request: req,
+		Params:  params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, req *oas.WebhookRequest, params oas.CreateWebhookParams) (*oas.Webhook, error))(ctx, req, params)
+}
+
+// DeletePetCall is a recorded call of deletePet operation.
+type DeletePetCall struct {
+	Params oas.DeletePetParams
+}
+
+// OnDeletePet sets handler of deletePet operation.
+func (f *Fake) OnDeletePet(h func(ctx context.Context, params oas.DeletePetParams) (oas.DeletePetRes, error)) *Fake {
+	f.setHandler("deletePet", h)
+	return f
+}
+
+// ReturnDeletePet sets canned response of deletePet operation.
+func (f *Fake) ReturnDeletePet(r0 oas.DeletePetRes, err error) *Fake {
+	return f.OnDeletePet(func(ctx context.Context, params oas.DeletePetParams) (oas.DeletePetRes, error) {
+		return r0, err
+	})
+}
+
+// DeletePetCalls returns recorded calls of deletePet operation.
+func (f *Fake) DeletePetCalls() []DeletePetCall {
+	return recorded[DeletePetCall](f, "deletePet")
+}
+
+// DeletePet implements deletePet operation.
+func (f *Fake) DeletePet(ctx context.Context, params oas.DeletePetParams) (r0 oas.DeletePetRes, err error) {
+	h, err := f.call("deletePet", DeletePetCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.DeletePetParams) (oas.DeletePetRes, error))(ctx, params)
+}
+
+// DeleteWebhookCall is a recorded call of deleteWebhook operation.
+type DeleteWebhookCall struct {
+	Params oas.DeleteWebhookParams
+}
+
+// OnDeleteWebhook sets handler of deleteWebhook operation.
+func (f *Fake) OnDeleteWebhook(h func(ctx context.Context, params oas.DeleteWebhookParams) (oas.DeleteWebhookRes, error)) *Fake {
+	f.setHandler("deleteWebhook", h)
+	return f
+}
+
+// ReturnDeleteWebhook sets canned response of deleteWebhook operation.
+func (f *Fake) ReturnDeleteWebhook(r0 oas.DeleteWebhookRes, err error) *Fake {
+	return f.OnDeleteWebhook(func(ctx context.Context, params oas.DeleteWebhookParams) (oas.DeleteWebhookRes, error) {
+		return r0, err
+	})
+}
+
+// DeleteWebhookCalls returns recorded calls of deleteWebhook operation.
+func (f *Fake) DeleteWebhookCalls() []DeleteWebhookCall {
+	return recorded[DeleteWebhookCall](f, "deleteWebhook")
+}
+
+// DeleteWebhook implements deleteWebhook operation.
+func (f *Fake) DeleteWebhook(ctx context.Context, params oas.DeleteWebhookParams) (r0 oas.DeleteWebhookRes, err error) {
+	h, err := f.call("deleteWebhook", DeleteWebhookCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.DeleteWebhookParams) (oas.DeleteWebhookRes, error))(ctx, params)
+}
+
+// EnableWebhookCall is a recorded call of enableWebhook operation.
+type EnableWebhookCall struct {
+	Params oas.EnableWebhookParams
+}
+
+// OnEnableWebhook sets handler of enableWebhook operation.
+func (f *Fake) OnEnableWebhook(h func(ctx context.Context, params oas.EnableWebhookParams) (oas.EnableWebhookRes, error)) *Fake {
+	f.setHandler("enableWebhook", h)
+	return f
+}
+
+// ReturnEnableWebhook sets canned response of enableWebhook operation.
+func (f *Fake) ReturnEnableWebhook(r0 oas.EnableWebhookRes, err error) *Fake {
+	return f.OnEnableWebhook(func(ctx context.Context, params oas.EnableWebhookParams) (oas.EnableWebhookRes, error) {
+		return r0, err
+	})
+}
+
+// EnableWebhookCalls returns recorded calls of enableWebhook operation.
+func (f *Fake) EnableWebhookCalls() []EnableWebhookCall {
+	return recorded[EnableWebhookCall](f, "enableWebhook")
+}
+
+// EnableWebhook implements enableWebhook operation.
+func (f *Fake) EnableWebhook(ctx context.Context, params oas.EnableWebhookParams) (r0 oas.EnableWebhookRes, err error) {
+	h, err := f.call("enableWebhook", EnableWebhookCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.EnableWebhookParams) (oas.EnableWebhookRes, error))(ctx, params)
+}
+
+// GetPetByIdCall is a recorded call of getPetById operation.
+type GetPetByIdCall struct {
+	Params oas.GetPetByIdParams
+}
+
+// OnGetPetById sets handler of getPetById operation.
+func (f *Fake) OnGetPetById(h func(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error)) *Fake {
+	f.setHandler("getPetById", h)
+	return f
+}
+
+// ReturnGetPetById sets canned response of getPetById operation.
+func (f *Fake) ReturnGetPetById(r0 oas.GetPetByIdRes, err error) *Fake {
+	return f.OnGetPetById(func(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error) {
+		return r0, err
+	})
+}
+
+// GetPetByIdCalls returns recorded calls of getPetById operation.
+func (f *Fake) GetPetByIdCalls() []GetPetByIdCall {
+	return recorded[GetPetByIdCall](f, "getPetById")
+}
+
+// GetPetById implements getPetById operation.
+func (f *Fake) GetPetById(ctx context.Context, params oas.GetPetByIdParams) (r0 oas.GetPetByIdRes, err error) {
+	h, err := f.call("getPetById", GetPetByIdCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error))(ctx, params)
+}
+
+// GetWebhookCall is a recorded call of getWebhook operation.
+type GetWebhookCall struct {
+	Params oas.GetWebhookParams
+}
+
+// OnGetWebhook sets handler of getWebhook operation.
+func (f *Fake) OnGetWebhook(h func(ctx context.Context, params oas.GetWebhookParams) (oas.GetWebhookRes, error)) *Fake {
+	f.setHandler("getWebhook", h)
+	return f
+}
+
+// ReturnGetWebhook sets canned response of getWebhook operation.
+func (f *Fake) ReturnGetWebhook(r0 oas.GetWebhookRes, err error) *Fake {
+	return f.OnGetWebhook(func(ctx context.Context, params oas.GetWebhookParams) (oas.GetWebhookRes, error) {
+		return r0, err
+	})
+}
+
+// GetWebhookCalls returns recorded calls of getWebhook operation.
+func (f *Fake) GetWebhookCalls() []GetWebhookCall {
+	return recorded[GetWebhookCall](f, "getWebhook")
+}
+
+// GetWebhook implements getWebhook operation.
+func (f *Fake) GetWebhook(ctx context.Context, params oas.GetWebhookParams) (r0 oas.GetWebhookRes, err error) {
+	h, err := f.call("getWebhook", GetWebhookCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.GetWebhookParams) (oas.GetWebhookRes, error))(ctx, params)
+}
+
+// ListWebhookDeliveriesCall is a recorded call of listWebhookDeliveries operation.
+type ListWebhookDeliveriesCall struct {
+	Params oas.ListWebhookDeliveriesParams
+}
+
+// OnListWebhookDeliveries sets handler of listWebhookDeliveries operation.
+func (f *Fake) OnListWebhookDeliveries(h func(ctx context.Context, params oas.ListWebhookDeliveriesParams) (oas.ListWebhookDeliveriesRes, error)) *Fake {
+	f.setHandler("listWebhookDeliveries", h)
+	return f
+}
+
+// ReturnListWebhookDeliveries sets canned response of listWebhookDeliveries operation.
+func (f *Fake) ReturnListWebhookDeliveries(r0 oas.ListWebhookDeliveriesRes, err error) *Fake {
+	return f.OnListWebhookDeliveries(func(ctx context.Context, params oas.ListWebhookDeliveriesParams) (oas.ListWebhookDeliveriesRes, error) {
+		return r0, err
+	})
+}
+
+// ListWebhookDeliveriesCalls returns recorded calls of listWebhookDeliveries operation.
+func (f *Fake) ListWebhookDeliveriesCalls() []ListWebhookDeliveriesCall {
+	return recorded[ListWebhookDeliveriesCall](f, "listWebhookDeliveries")
+}
+
+// ListWebhookDeliveries implements listWebhookDeliveries operation.
+func (f *Fake) ListWebhookDeliveries(ctx context.Context, params oas.ListWebhookDeliveriesParams) (r0 oas.ListWebhookDeliveriesRes, err error) {
+	h, err := f.call("listWebhookDeliveries", ListWebhookDeliveriesCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.ListWebhookDeliveriesParams) (oas.ListWebhookDeliveriesRes, error))(ctx, params)
+}
+
+// ListWebhooksCall is a recorded call of listWebhooks operation.
+type ListWebhooksCall struct {
+}
+
+// OnListWebhooks sets handler of listWebhooks operation.
+func (f *Fake) OnListWebhooks(h func(ctx context.Context) ([]oas.Webhook, error)) *Fake {
+	f.setHandler("listWebhooks", h)
+	return f
+}
+
+// ReturnListWebhooks sets canned response of listWebhooks operation.
+func (f *Fake) ReturnListWebhooks(r0 []oas.Webhook, err error) *Fake {
+	return f.OnListWebhooks(func(ctx context.Context) ([]oas.Webhook, error) {
+		return r0, err
+	})
+}
+
+// ListWebhooksCalls returns recorded calls of listWebhooks operation.
+func (f *Fake) ListWebhooksCalls() []ListWebhooksCall {
+	return recorded[ListWebhooksCall](f, "listWebhooks")
+}
+
+// ListWebhooks implements listWebhooks operation.
+func (f *Fake) ListWebhooks(ctx context.Context) (r0 []oas.Webhook, err error) {
+	h, err := f.call("listWebhooks", ListWebhooksCall{})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context) ([]oas.Webhook, error))(ctx)
+}
+
+// UpdatePetCall is a recorded call of updatePet operation.
+type UpdatePetCall struct {
+	Params oas.UpdatePetParams
+}
+
+// OnUpdatePet sets handler of updatePet operation.
+func (f *Fake) OnUpdatePet(h func(ctx context.Context, params oas.UpdatePetParams) (oas.UpdatePetRes, error)) *Fake {
+	f.setHandler("updatePet", h)
+	return f
+}
+
+// ReturnUpdatePet sets canned response of updatePet operation.
+func (f *Fake) ReturnUpdatePet(r0 oas.UpdatePetRes, err error) *Fake {
+	return f.OnUpdatePet(func(ctx context.Context, params oas.UpdatePetParams) (oas.UpdatePetRes, error) {
+		return r0, err
+	})
+}
+
+// UpdatePetCalls returns recorded calls of updatePet operation.
+func (f *Fake) UpdatePetCalls() []UpdatePetCall {
+	return recorded[UpdatePetCall](f, "updatePet")
+}
+
+// UpdatePet implements updatePet operation.
+func (f *Fake) UpdatePet(ctx context.Context, params oas.UpdatePetParams) (r0 oas.UpdatePetRes, err error) {
+	h, err := f.call("updatePet", UpdatePetCall{
+		Params: params,
+	})
+	if err != nil {
+		return r0, err
+	}
+	return h.(func(ctx context.Context, params oas.UpdatePetParams) (oas.UpdatePetRes, error))(ctx, params)
+}
@@ -0,0 +1,242 @@
+// Command gen generates typed methods of oastest.Fake from oas.Handler.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/go-faster/errors"
+)
+
+// operation is a method of oas.Handler.
+type operation struct {
+	Name string
+	ID   string
+	// Params are parameters except context.
+	Params []param
+	// Results are result types except error.
+	Results []string
+}
+
+type param struct {
+	Name  string
+	Field string
+	Type  string
+}
+
+// Signature returns parameters of operation method.
+func (o operation) Signature() string {
+	parts := []string{"ctx context.Context"}
+	for _, p := range o.Params {
+		parts = append(parts, p.Name+" "+p.Type)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FuncType returns type of operation handler.
+func (o operation) FuncType() string {
+	return "func(" + o.Signature() + ") " + o.ResultTypes()
+}
+
+// ResultTypes returns result list of operation method.
+func (o operation) ResultTypes() string {
+	if len(o.Results) == 0 {
+		return "error"
+	}
+	return "(" + strings.Join(o.Results, ", ") + ", error)"
+}
+
+// Args returns call arguments of operation method.
+func (o operation) Args() string {
+	parts := []string{"ctx"}
+	for _, p := range o.Params {
+		parts = append(parts, p.Name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Zero returns zero results on error.
+func (o operation) Zero() string {
+	parts := make([]string, 0, len(o.Results)+1)
+	for i := range o.Results {
+		parts = append(parts, fmt.Sprintf("r%d", i))
+	}
+	return strings.Join(append(parts, "err"), ", ")
+}
+
+var implementsRe = regexp.MustCompile(`implements (\w+) operation`)
+
+// parseHandler parses oas.Handler interface from package directory.
+func parseHandler(dir string) ([]operation, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(dir, "oas_server_gen.go"), nil, parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse")
+	}
+	obj := f.Scope.Lookup("Handler")
+	if obj == nil {
+		return nil, errors.New("no Handler type")
+	}
+	iface, ok := obj.Decl.(*ast.TypeSpec).Type.(*ast.InterfaceType)
+	if !ok {
+		return nil, errors.New("Handler is not an interface")
+	}
+
+	var ops []operation
+	for _, m := range iface.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) != 1 {
+			continue
+		}
+		op := operation{Name: m.Names[0].Name}
+		match := implementsRe.FindStringSubmatch(m.Doc.Text())
+		if match == nil {
+			return nil, errors.Errorf("%s: no operation ID in comment", op.Name)
+		}
+		op.ID = match[1]
+
+		for i, field := range fn.Params.List {
+			if i == 0 {
+				// Context.
+				continue
+			}
+			for _, name := range field.Names {
+				op.Params = append(op.Params, param{
+					Name:  name.Name,
+					Field: exported(name.Name),
+					Type:  qualify(field.Type),
+				})
+			}
+		}
+		results := fn.Results.List
+		for _, field := range results[:len(results)-1] {
+			op.Results = append(op.Results, qualify(field.Type))
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// qualify returns type expression with exported identifiers qualified by oas package.
+func qualify(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if ast.IsExported(e.Name) {
+			return "oas." + e.Name
+		}
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + qualify(e.X)
+	case *ast.ArrayType:
+		return "[]" + qualify(e.Elt)
+	case *ast.MapType:
+		return "map[" + qualify(e.Key) + "]" + qualify(e.Value)
+	case *ast.SelectorExpr:
+		return qualify(e.X) + "." + e.Sel.Name
+	default:
+		panic(fmt.Sprintf("unsupported type %T", expr))
+	}
+}
+
+func exported(name string) string {
+	switch name {
+	case "req", "request":
+		return "Request"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+var fakeTemplate = template.Must(template.New("fake").Parse(`// Code generated by oastest/gen, DO NOT EDIT.
+
+package oastest
+
+import (
+	"context"
+
+	"example/internal/oas"
+)
+
+var (
+	_ oas.Invoker = (*Fake)(nil)
+	_ oas.Handler = (*Fake)(nil)
+)
+{{ range $op := . }}
+// {{ $op.Name }}Call is a recorded call of {{ $op.ID }} operation.
+type {{ $op.Name }}Call struct {
+{{- range $op.Params }}
+	{{ .Field }} {{ .Type }}
+{{- end }}
+}
+
+// On{{ $op.Name }} sets handler of {{ $op.ID }} operation.
+func (f *Fake) On{{ $op.Name }}(h {{ $op.FuncType }}) *Fake {
+	f.setHandler("{{ $op.ID }}", h)
+	return f
+}
+
+// Return{{ $op.Name }} sets canned response of {{ $op.ID }} operation.
+func (f *Fake) Return{{ $op.Name }}({{ range $i, $r := $op.Results }}r{{ $i }} {{ $r }}, {{ end }}err error) *Fake {
+	return f.On{{ $op.Name }}(func({{ $op.Signature }}) {{ $op.ResultTypes }} {
+		return {{ $op.Zero }}
+	})
+}
+
+// {{ $op.Name }}Calls returns recorded calls of {{ $op.ID }} operation.
+func (f *Fake) {{ $op.Name }}Calls() []{{ $op.Name }}Call {
+	return recorded[{{ $op.Name }}Call](f, "{{ $op.ID }}")
+}
+
+// {{ $op.Name }} implements {{ $op.ID }} operation.
+func (f *Fake) {{ $op.Name }}({{ $op.Signature }}) ({{ range $i, $r := $op.Results }}r{{ $i }} {{ $r }}, {{ end }}err error) {
+	h, err := f.call("{{ $op.ID }}", {{ $op.Name }}Call{
+	{{- range $op.Params }}
+		{{ .Field }}: {{ .Name }},
+	{{- end }}
+	})
+	if err != nil {
+		return {{ $op.Zero }}
+	}
+	return h.({{ $op.FuncType }})({{ $op.Args }})
+}
+{{ end }}`))
+
+func run(src, out string) error {
+	ops, err := parseHandler(src)
+	if err != nil {
+		return errors.Wrap(err, "parse handler")
+	}
+	var buf bytes.Buffer
+	if err := fakeTemplate.Execute(&buf, ops); err != nil {
+		return errors.Wrap(err, "execute template")
+	}
+	data, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "format:\n%s", buf.Bytes())
+	}
+	return os.WriteFile(out, data, 0o644)
+}
+
+func main() {
+	var arg struct {
+		Src string
+		Out string
+	}
+	flag.StringVar(&arg.Src, "src", "internal/oas", "directory of ogen-generated package")
+	flag.StringVar(&arg.Out, "out", "fake_gen.go", "output file")
+	flag.Parse()
+
+	if err := run(arg.Src, arg.Out); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerated(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "fake_gen.go")
+	require.NoError(t, run("../../oas", out))
+
+	want, err := os.ReadFile("../fake_gen.go")
+	require.NoError(t, err)
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "fake_gen.go is outdated, run go generate ./...")
+}
@@ -0,0 +1,93 @@
+package oastest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-faster/jx"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+// Golden tests pin JSON wire format of generated oas types, so ogen upgrades
+// changing it are caught on regeneration. Run with -update to rewrite golden
+// files and review the diff.
+var update = flag.Bool("update", false, "update golden files")
+
+// requireGolden compares data with golden file.
+func requireGolden(t *testing.T, name string, data []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run with -update to create golden file")
+	require.Equal(t, string(want), string(data), "%s differs, run with -update to accept", path)
+}
+
+func encodeJSON(v interface{ Encode(*jx.Encoder) }) []byte {
+	var e jx.Encoder
+	v.Encode(&e)
+	return append(e.Bytes(), '\n')
+}
+
+func TestGoldenEncode(t *testing.T) {
+	cases := map[string]interface{ Encode(*jx.Encoder) }{
+		"pet_required":         &oas.Pet{Name: "doggie"},
+		"pet_empty_photo_urls": &oas.Pet{Name: "doggie", PhotoUrls: []string{}},
+		"pet_photo_urls":       &oas.Pet{Name: "doggie", PhotoUrls: []string{"a.jpg", "b.jpg"}},
+		"pet_zero_id":          &oas.Pet{ID: oas.NewOptInt64(0), Name: "doggie"},
+		"pet_empty_name":       &oas.Pet{},
+		"pet_escaped_name":     &oas.Pet{Name: "\"dog\"\n<gie> é "},
+		"pet_full": &oas.Pet{
+			ID:        oas.NewOptInt64(9223372036854775807),
+			Name:      "doggie",
+			PhotoUrls: []string{"a.jpg"},
+			Status:    oas.NewOptPetStatus(oas.PetStatusSold),
+		},
+	}
+	for _, status := range oas.PetStatus("").AllValues() {
+		cases["status_"+string(status)] = status
+		cases["pet_status_"+string(status)] = &oas.Pet{Name: "doggie", Status: oas.NewOptPetStatus(status)}
+	}
+	for name, v := range cases {
+		t.Run(name, func(t *testing.T) {
+			requireGolden(t, filepath.Join("encode", name+".json"), encodeJSON(v))
+		})
+	}
+}
+
+func TestGoldenDecode(t *testing.T) {
+	// Each input is decoded into oas.Pet and validated, the result is
+	// either re-encoded value or error.
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "decode", "*.input.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			require.NoError(t, err)
+
+			var (
+				pet    oas.Pet
+				result []byte
+			)
+			if err := pet.Decode(jx.DecodeBytes(data)); err != nil {
+				result = []byte("decode error: " + err.Error() + "\n")
+			} else if err := pet.Validate(); err != nil {
+				result = []byte("validate error: " + err.Error() + "\n")
+			} else {
+				result = encodeJSON(&pet)
+			}
+			requireGolden(t, filepath.Join("decode", name+".golden"), result)
+		})
+	}
+}
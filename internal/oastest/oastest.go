@@ -0,0 +1,153 @@
+// Package oastest provides programmable fake of the API for consumers' tests.
+//
+// Fake implements both oas.Invoker and oas.Handler, so it replaces client in
+// unit tests and is served by NewFakeServer for tests at the HTTP level.
+package oastest
+
+//go:generate go run ./gen -src ../oas -out fake_gen.go
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-faster/errors"
+
+	"example/internal/oas"
+)
+
+// UnexpectedCallError is returned for call of operation without handler.
+type UnexpectedCallError struct {
+	Operation string
+}
+
+func (e *UnexpectedCallError) Error() string {
+	return fmt.Sprintf("unexpected call of %s", e.Operation)
+}
+
+// Fake is a programmable fake of the API.
+//
+// Handlers are set per operation by On<Operation> or Return<Operation>,
+// calls are recorded and returned by <Operation>Calls. Calling operation
+// without handler fails test and returns *UnexpectedCallError.
+type Fake struct {
+	t testing.TB
+
+	mux      sync.Mutex
+	handlers map[string]any
+	calls    map[string][]any
+	expected map[string]int
+}
+
+// New creates new Fake.
+//
+// Expectations set by Expect are checked on test cleanup.
+func New(t testing.TB) *Fake {
+	f := &Fake{
+		t:        t,
+		handlers: map[string]any{},
+		calls:    map[string][]any{},
+		expected: map[string]int{},
+	}
+	t.Cleanup(func() {
+		if err := f.Verify(); err != nil {
+			t.Error(err)
+		}
+	})
+	return f
+}
+
+// Expect expects operation to be called given number of times.
+func (f *Fake) Expect(operationID string, times int) *Fake {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.expected[operationID] = times
+	return f
+}
+
+// Verify checks that expected operations were called expected number of times.
+func (f *Fake) Verify() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	ops := make([]string, 0, len(f.expected))
+	for op := range f.expected {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	var errs []error
+	for _, op := range ops {
+		if want, got := f.expected[op], len(f.calls[op]); want != got {
+			errs = append(errs, errors.Errorf("%s: called %d times, expected %d", op, got, want))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Operations returns sorted IDs of called operations.
+func (f *Fake) Operations() []string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	ops := make([]string, 0, len(f.calls))
+	for op := range f.calls {
+		ops = append(ops, op)
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+// Reset removes handlers, recorded calls and expectations.
+func (f *Fake) Reset() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	clear(f.handlers)
+	clear(f.calls)
+	clear(f.expected)
+}
+
+func (f *Fake) setHandler(op string, h any) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.handlers[op] = h
+}
+
+// call records call of operation and returns its handler, if any.
+func (f *Fake) call(op string, call any) (any, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.calls[op] = append(f.calls[op], call)
+	h, ok := f.handlers[op]
+	if !ok {
+		err := &UnexpectedCallError{Operation: op}
+		f.t.Errorf("oastest: %v", err)
+		return nil, err
+	}
+	return h, nil
+}
+
+// recorded returns recorded calls of operation.
+func recorded[C any](f *Fake, op string) []C {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	calls := make([]C, 0, len(f.calls[op]))
+	for _, c := range f.calls[op] {
+		calls = append(calls, c.(C))
+	}
+	return calls
+}
+
+// NewFakeServer serves fake over HTTP until test cleanup.
+func NewFakeServer(t testing.TB, f *Fake, opts ...oas.ServerOption) *httptest.Server {
+	t.Helper()
+	srv, err := oas.NewServer(f, opts...)
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	s := httptest.NewServer(srv)
+	t.Cleanup(s.Close)
+	return s
+}
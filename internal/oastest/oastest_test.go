@@ -0,0 +1,98 @@
+package oastest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ogen-go/ogen/validate"
+	"github.com/stretchr/testify/require"
+
+	"example/internal/oas"
+)
+
+// errorTB records errors instead of failing test.
+type errorTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *errorTB) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *errorTB) Error(args ...any) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	f := New(t).
+		ReturnAddPet(&oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"}, nil).
+		OnGetPetById(func(ctx context.Context, params oas.GetPetByIdParams) (oas.GetPetByIdRes, error) {
+			if params.PetId != 1 {
+				return &oas.GetPetByIdNotFound{}, nil
+			}
+			return &oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"}, nil
+		}).
+		Expect("addPet", 1).
+		Expect("getPetById", 2)
+
+	// Fake is used as client.
+	var client oas.Invoker = f
+	added, err := client.AddPet(ctx, &oas.Pet{Name: "Tom"}, oas.AddPetParams{
+		IdempotencyKey: oas.NewOptString("key"),
+	})
+	require.NoError(t, err)
+	pet := added.(*oas.Pet)
+	require.Equal(t, "Tom", pet.Name)
+
+	// Fake is served over HTTP.
+	srv := NewFakeServer(t, f)
+	c, err := oas.NewClient(srv.URL)
+	require.NoError(t, err)
+	res, err := c.GetPetById(ctx, oas.GetPetByIdParams{PetId: 1})
+	require.NoError(t, err)
+	require.Equal(t, &oas.Pet{ID: oas.NewOptInt64(1), Name: "Tom"}, res)
+	res, err = c.GetPetById(ctx, oas.GetPetByIdParams{PetId: 2})
+	require.NoError(t, err)
+	require.IsType(t, &oas.GetPetByIdNotFound{}, res)
+
+	require.Equal(t, []AddPetCall{{
+		Request: &oas.Pet{Name: "Tom"},
+		Params:  oas.AddPetParams{IdempotencyKey: oas.NewOptString("key")},
+	}}, f.AddPetCalls())
+	require.Equal(t, []GetPetByIdCall{
+		{Params: oas.GetPetByIdParams{PetId: 1}},
+		{Params: oas.GetPetByIdParams{PetId: 2}},
+	}, f.GetPetByIdCalls())
+	require.Equal(t, []string{"addPet", "getPetById"}, f.Operations())
+	require.NoError(t, f.Verify())
+}
+
+func TestFakeUnexpected(t *testing.T) {
+	ctx := context.Background()
+	tb := &errorTB{TB: t}
+	f := New(tb).ReturnDeletePet(&oas.DeletePetOK{}, nil).Expect("deletePet", 1)
+
+	srv := NewFakeServer(t, f)
+	c, err := oas.NewClient(srv.URL)
+	require.NoError(t, err)
+	_, err = c.UpdatePet(ctx, oas.UpdatePetParams{PetId: 1})
+	var statusErr *validate.UnexpectedStatusCodeError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+	require.Equal(t, []string{"oastest: unexpected call of updatePet"}, tb.errors)
+
+	_, err = f.UpdatePet(ctx, oas.UpdatePetParams{PetId: 1})
+	var unexpected *UnexpectedCallError
+	require.ErrorAs(t, err, &unexpected)
+	require.Equal(t, "updatePet", unexpected.Operation)
+	require.Len(t, f.UpdatePetCalls(), 2)
+
+	require.EqualError(t, f.Verify(), "deletePet: called 0 times, expected 1")
+	f.Reset()
+	require.NoError(t, f.Verify())
+	require.Empty(t, f.UpdatePetCalls())
+}
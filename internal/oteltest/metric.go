@@ -0,0 +1,136 @@
+package oteltest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Point is a data point of metric.
+type Point struct {
+	Attributes attribute.Set
+	// Value is a value of sum or gauge, or count of histogram.
+	Value float64
+}
+
+func (p Point) String() string {
+	var attrs []string
+	for _, kv := range p.Attributes.ToSlice() {
+		attrs = append(attrs, fmt.Sprintf("%s=%s", kv.Key, formatValue(kv.Value.AsInterface())))
+	}
+	return fmt.Sprintf("{%s} %v", strings.Join(attrs, " "), p.Value)
+}
+
+// Metrics are metrics collected from reader.
+type Metrics struct {
+	rm metricdata.ResourceMetrics
+}
+
+// Collect collects metrics from reader.
+func Collect(t testing.TB, reader *sdkmetric.ManualReader) Metrics {
+	t.Helper()
+	var m Metrics
+	if err := reader.Collect(context.Background(), &m.rm); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	return m
+}
+
+// Points returns data points of metric with given name.
+func (m Metrics) Points(name string) ([]Point, bool) {
+	for _, sm := range m.rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			if metric.Name == name {
+				return points(metric.Data), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func points(data metricdata.Aggregation) (r []Point) {
+	switch data := data.(type) {
+	case metricdata.Sum[int64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, float64(dp.Value)})
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, dp.Value})
+		}
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, float64(dp.Value)})
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, dp.Value})
+		}
+	case metricdata.Histogram[int64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, float64(dp.Count)})
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			r = append(r, Point{dp.Attributes, float64(dp.Count)})
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].String() < r[j].String()
+	})
+	return r
+}
+
+// Value returns sum of values of data points having given attributes.
+func (m Metrics) Value(name string, attrs map[string]any) (float64, error) {
+	points, ok := m.Points(name)
+	if !ok {
+		return 0, errors.Errorf("no metric %q", name)
+	}
+	var sum float64
+	for _, p := range points {
+		if matchAttributes(attrs, p.Attributes.ToSlice()) == nil {
+			sum += p.Value
+		}
+	}
+	return sum, nil
+}
+
+// Require fails test if sum of values of data points of metric having given
+// attributes is not equal to expected, printing all data points.
+func (m Metrics) Require(t testing.TB, name string, attrs map[string]any, want float64) {
+	t.Helper()
+	got, err := m.Value(name, attrs)
+	if err == nil && got == want {
+		return
+	}
+	if err == nil {
+		err = errors.Errorf("%s%s is %v, expected %v", name, formatAttributes(attrs), got, want)
+	}
+	var b strings.Builder
+	points, _ := m.Points(name)
+	for _, p := range points {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+	t.Fatalf("%v\n\npoints:\n%s", err, b.String())
+}
+
+func formatAttributes(attrs map[string]any) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, formatValue(normalize(attrs[k]))))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
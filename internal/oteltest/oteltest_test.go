@@ -0,0 +1,143 @@
+package oteltest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"example/internal/httpmiddleware"
+)
+
+// fatalTB records Fatalf message instead of failing test.
+type fatalTB struct {
+	testing.TB
+	msg string
+}
+
+func (t *fatalTB) Helper() {}
+
+func (t *fatalTB) Fatalf(format string, args ...any) {
+	t.msg = fmt.Sprintf(format, args...)
+}
+
+func TestSpans(t *testing.T) {
+	provider := httpmiddleware.NewProvider()
+	tracer := provider.Tracer("test")
+	ctx, server := tracer.Start(context.Background(), "api.getPetById",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.route", "/pet/{petId}"),
+			attribute.Int("http.status_code", 404),
+		),
+	)
+	_, first := tracer.Start(ctx, "db")
+	first.End()
+	_, second := tracer.Start(ctx, "db", trace.WithAttributes(attribute.Bool("db.cached", true)))
+	second.AddEvent("hit")
+	second.End()
+	server.SetStatus(codes.Error, "not found")
+	server.End()
+	provider.Flush()
+	spans := provider.Exporter.GetSpans()
+
+	want := Span{
+		Name:   "api.getPetById",
+		Kind:   trace.SpanKindServer,
+		Status: codes.Error,
+		Attributes: map[string]any{
+			"http.route":       "/pet/{petId}",
+			"http.status_code": 404,
+		},
+		Children: []Span{
+			// Matched to second span after backtracking.
+			{Name: "db"},
+			{Name: "db", Attributes: map[string]any{"db.cached": true}, Events: []string{"hit"}},
+		},
+	}
+	RequireSpans(t, spans, want)
+	// Child matches span anywhere in tree.
+	RequireSpans(t, spans, Span{Name: "db", Events: []string{"hit"}})
+
+	for _, tt := range []struct {
+		Want  Span
+		Error string
+	}{
+		{Span{Name: "api.addPet"}, `no span "api.addPet"`},
+		{Span{Name: "api.getPetById", Kind: trace.SpanKindClient}, "api.getPetById: kind is server, expected client"},
+		{Span{Name: "api.getPetById", Status: codes.Ok}, "api.getPetById: status is Error, expected Ok"},
+		{
+			Span{Name: "api.getPetById", Attributes: map[string]any{"http.route": "/pet"}},
+			`api.getPetById: attribute http.route is "/pet/{petId}", expected "/pet"`,
+		},
+		{
+			Span{Name: "api.getPetById", Attributes: map[string]any{"http.status_code": "404"}},
+			`api.getPetById: attribute http.status_code is 404, expected "404"`,
+		},
+		{Span{Name: "api.getPetById", Attributes: map[string]any{"oas.operation": "x"}}, "api.getPetById: attribute oas.operation is missing"},
+		{Span{Name: "db", Events: []string{"miss"}}, `db: events are [], expected ["miss"]`},
+		{
+			Span{Name: "api.getPetById", Children: []Span{{Name: "db"}, {Name: "db"}, {Name: "db"}}},
+			`api.getPetById: no child span "db"`,
+		},
+	} {
+		err := MatchSpans(spans, tt.Want)
+		require.EqualError(t, err, tt.Error)
+	}
+
+	tb := &fatalTB{TB: t}
+	RequireSpans(tb, spans, Span{
+		Name:     "api.getPetById",
+		Children: []Span{{Name: "cache"}},
+	})
+	require.Equal(t, `api.getPetById: no child span "cache"
+
+expected:
+api.getPetById
+  cache
+
+actual:
+api.getPetById [server] status=Error
+  db [internal]
+  db [internal] events=hit
+`, tb.msg)
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	counter, err := meter.Int64Counter("requests")
+	require.NoError(t, err)
+	counter.Add(ctx, 2, metric.WithAttributes(attribute.String("oas.operation", "addPet"), attribute.Int("status", 200)))
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("oas.operation", "getPetById"), attribute.Int("status", 404)))
+	histogram, err := meter.Float64Histogram("duration")
+	require.NoError(t, err)
+	histogram.Record(ctx, 0.5)
+	histogram.Record(ctx, 1.5)
+
+	m := Collect(t, reader)
+	m.Require(t, "requests", nil, 3)
+	m.Require(t, "requests", map[string]any{"oas.operation": "addPet"}, 2)
+	m.Require(t, "requests", map[string]any{"status": 404}, 1)
+	m.Require(t, "requests", map[string]any{"oas.operation": "deletePet"}, 0)
+	m.Require(t, "duration", nil, 2)
+
+	tb := &fatalTB{TB: t}
+	m.Require(tb, "requests", map[string]any{"oas.operation": "addPet"}, 1)
+	require.Equal(t, `requests{oas.operation="addPet"} is 2, expected 1
+
+points:
+  {oas.operation="addPet" status=200} 2
+  {oas.operation="getPetById" status=404} 1
+`, tb.msg)
+
+	_, err = m.Value("unknown", nil)
+	require.EqualError(t, err, `no metric "unknown"`)
+}
@@ -0,0 +1,328 @@
+// Package oteltest asserts spans and metrics collected by in-memory exporters.
+package oteltest
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-faster/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span is an expected span tree.
+//
+// Zero fields match anything, so only asserted properties are set.
+type Span struct {
+	Name string
+	Kind trace.SpanKind
+	// Status is a status code, Unset matches any status.
+	Status codes.Code
+	// Attributes must be present with equal values, other attributes are
+	// ignored. Values are Go values like "/pet/{petId}", 200 or true.
+	Attributes map[string]any
+	// Events are names of events in order, other events are ignored.
+	Events []string
+	// Children must match distinct child spans, other children are ignored.
+	Children []Span
+}
+
+// RequireSpans fails test if spans do not contain every expected tree,
+// printing expected and actual trees.
+func RequireSpans(t testing.TB, spans tracetest.SpanStubs, want ...Span) {
+	t.Helper()
+	if err := MatchSpans(spans, want...); err != nil {
+		t.Fatalf("%v\n\nexpected:\n%s\nactual:\n%s", err, FormatExpected(want...), FormatSpans(spans, attributeKeys(want)...))
+	}
+}
+
+// MatchSpans checks that every expected tree matches spans, with expected
+// root matching any span, not only root one.
+func MatchSpans(spans tracetest.SpanStubs, want ...Span) error {
+	f := newForest(spans)
+	for _, w := range want {
+		var first error
+		found := false
+		for i := range spans {
+			err := f.match(w, i)
+			if err == nil {
+				found = true
+				break
+			}
+			if first == nil && spans[i].Name == w.Name {
+				first = err
+			}
+		}
+		switch {
+		case found:
+		case first != nil:
+			return first
+		default:
+			return errors.Errorf("no span %q", w.Name)
+		}
+	}
+	return nil
+}
+
+// forest indexes spans by parent.
+type forest struct {
+	spans    tracetest.SpanStubs
+	children map[trace.SpanID][]int
+}
+
+func newForest(spans tracetest.SpanStubs) *forest {
+	f := &forest{
+		spans:    spans,
+		children: map[trace.SpanID][]int{},
+	}
+	for i, s := range spans {
+		if s.Parent.IsValid() {
+			f.children[s.Parent.SpanID()] = append(f.children[s.Parent.SpanID()], i)
+		}
+	}
+	for _, c := range f.children {
+		sort.SliceStable(c, func(a, b int) bool {
+			return spans[c[a]].StartTime.Before(spans[c[b]].StartTime)
+		})
+	}
+	return f
+}
+
+// roots returns indexes of spans without recorded parent.
+func (f *forest) roots() (r []int) {
+	ids := map[trace.SpanID]bool{}
+	for _, s := range f.spans {
+		ids[s.SpanContext.SpanID()] = true
+	}
+	for i, s := range f.spans {
+		if !s.Parent.IsValid() || !ids[s.Parent.SpanID()] {
+			r = append(r, i)
+		}
+	}
+	sort.SliceStable(r, func(a, b int) bool {
+		return f.spans[r[a]].StartTime.Before(f.spans[r[b]].StartTime)
+	})
+	return r
+}
+
+// match checks span i against expected tree.
+func (f *forest) match(want Span, i int) error {
+	s := f.spans[i]
+	if want.Name != s.Name {
+		return errors.Errorf("name is %q, expected %q", s.Name, want.Name)
+	}
+	if err := matchSpan(want, s); err != nil {
+		return errors.Wrap(err, s.Name)
+	}
+	if err := f.matchChildren(want.Children, f.children[s.SpanContext.SpanID()], map[int]bool{}); err != nil {
+		return errors.Wrap(err, s.Name)
+	}
+	return nil
+}
+
+// matchChildren assigns every expected child to distinct actual one,
+// backtracking if greedy choice fails.
+func (f *forest) matchChildren(want []Span, children []int, used map[int]bool) error {
+	if len(want) == 0 {
+		return nil
+	}
+	w := want[0]
+	var first error
+	for _, c := range children {
+		if used[c] {
+			continue
+		}
+		err := f.match(w, c)
+		if err == nil {
+			used[c] = true
+			if err = f.matchChildren(want[1:], children, used); err == nil {
+				return nil
+			}
+			used[c] = false
+		}
+		if first == nil && f.spans[c].Name == w.Name {
+			first = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+	return errors.Errorf("no child span %q", w.Name)
+}
+
+func matchSpan(want Span, s tracetest.SpanStub) error {
+	if want.Kind != trace.SpanKindUnspecified && want.Kind != s.SpanKind {
+		return errors.Errorf("kind is %s, expected %s", s.SpanKind, want.Kind)
+	}
+	if want.Status != codes.Unset && want.Status != s.Status.Code {
+		return errors.Errorf("status is %s, expected %s", s.Status.Code, want.Status)
+	}
+	if err := matchAttributes(want.Attributes, s.Attributes); err != nil {
+		return err
+	}
+	events := make([]string, 0, len(s.Events))
+	for _, e := range s.Events {
+		events = append(events, e.Name)
+	}
+	if !isSubsequence(want.Events, events) {
+		return errors.Errorf("events are %q, expected %q", events, want.Events)
+	}
+	return nil
+}
+
+// matchAttributes checks that attrs contain every expected attribute.
+func matchAttributes(want map[string]any, attrs []attribute.KeyValue) error {
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	set := attribute.NewSet(attrs...)
+	for _, k := range keys {
+		v, ok := set.Value(attribute.Key(k))
+		if !ok {
+			return errors.Errorf("attribute %s is missing", k)
+		}
+		expected := normalize(want[k])
+		if got := v.AsInterface(); fmt.Sprint(got) != fmt.Sprint(expected) || fmt.Sprintf("%T", got) != fmt.Sprintf("%T", expected) {
+			return errors.Errorf("attribute %s is %s, expected %s", k, formatValue(got), formatValue(expected))
+		}
+	}
+	return nil
+}
+
+// normalize converts Go value to type of attribute.Value.AsInterface.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []int:
+		r := make([]int64, len(v))
+		for i, e := range v {
+			r[i] = int64(e)
+		}
+		return r
+	}
+	return v
+}
+
+func isSubsequence(want, got []string) bool {
+	for _, g := range got {
+		if len(want) == 0 {
+			break
+		}
+		if g == want[0] {
+			want = want[1:]
+		}
+	}
+	return len(want) == 0
+}
+
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// FormatExpected formats expected span trees.
+func FormatExpected(want ...Span) string {
+	var b strings.Builder
+	var walk func(s Span, depth int)
+	walk = func(s Span, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString(s.Name)
+		if s.Kind != trace.SpanKindUnspecified {
+			fmt.Fprintf(&b, " [%s]", s.Kind)
+		}
+		if s.Status != codes.Unset {
+			fmt.Fprintf(&b, " status=%s", s.Status)
+		}
+		keys := make([]string, 0, len(s.Attributes))
+		for k := range s.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%s", k, formatValue(normalize(s.Attributes[k])))
+		}
+		if len(s.Events) > 0 {
+			fmt.Fprintf(&b, " events=%s", strings.Join(s.Events, ","))
+		}
+		b.WriteString("\n")
+		for _, c := range s.Children {
+			walk(c, depth+1)
+		}
+	}
+	for _, s := range want {
+		walk(s, 0)
+	}
+	return b.String()
+}
+
+// FormatSpans formats span trees, printing only attributes with given keys.
+func FormatSpans(spans tracetest.SpanStubs, keys ...string) string {
+	f := newForest(spans)
+	var b strings.Builder
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		s := f.spans[i]
+		b.WriteString(strings.Repeat("  ", depth))
+		fmt.Fprintf(&b, "%s [%s]", s.Name, s.SpanKind)
+		if s.Status.Code != codes.Unset {
+			fmt.Fprintf(&b, " status=%s", s.Status.Code)
+		}
+		set := attribute.NewSet(s.Attributes...)
+		for _, k := range keys {
+			if v, ok := set.Value(attribute.Key(k)); ok {
+				fmt.Fprintf(&b, " %s=%s", k, formatValue(v.AsInterface()))
+			}
+		}
+		if len(s.Events) > 0 {
+			names := make([]string, 0, len(s.Events))
+			for _, e := range s.Events {
+				names = append(names, e.Name)
+			}
+			fmt.Fprintf(&b, " events=%s", strings.Join(names, ","))
+		}
+		b.WriteString("\n")
+		for _, c := range f.children[s.SpanContext.SpanID()] {
+			walk(c, depth+1)
+		}
+	}
+	for _, r := range f.roots() {
+		walk(r, 0)
+	}
+	return b.String()
+}
+
+// attributeKeys returns sorted attribute keys used by expected trees.
+func attributeKeys(want []Span) []string {
+	var keys []string
+	var walk func(s Span)
+	walk = func(s Span) {
+		for k := range s.Attributes {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+		for _, c := range s.Children {
+			walk(c)
+		}
+	}
+	for _, s := range want {
+		walk(s)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,8 @@
+package example
+
+import _ "embed"
+
+// OpenAPISpec is OpenAPI specification used to generate internal/oas.
+//
+//go:embed _oas/openapi.yml
+var OpenAPISpec []byte